@@ -67,44 +67,69 @@ func (c Verifier) VerifyInboundKitContext(ctx context.Context) (bool, error) {
 
 // Verify will accept an opaque JWT token, decode it and verify it.
 func (c Verifier) Verify(ctx context.Context, token string) (bool, error) {
-	hdr, rawPayload, err := decodeToken(token)
+	clmstr, err := c.verify(ctx, token)
 	if err != nil {
 		return false, err
 	}
+	return c.vf(ctx, clmstr), nil
+}
+
+// ErrUnverified is returned by VerifyClaims when the VerifyFunc rejects a
+// token's claims.
+var ErrUnverified = errors.New("token claims failed verification")
+
+// VerifyClaims will accept an opaque JWT token, decode it and verify it
+// like Verify but return the decoded claims if the token is valid.
+func (c Verifier) VerifyClaims(ctx context.Context, token string) (ClaimSetter, error) {
+	clmstr, err := c.verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !c.vf(ctx, clmstr) {
+		return nil, ErrUnverified
+	}
+	return clmstr, nil
+}
+
+func (c Verifier) verify(ctx context.Context, token string) (ClaimSetter, error) {
+	hdr, rawPayload, err := decodeToken(token)
+	if err != nil {
+		return nil, err
+	}
 
 	keys, err := c.ks.Get(ctx)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	key, err := keys.GetKey(hdr.KeyID)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	err = jws.Verify(token, key)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	// use claims decoder func
 	clmstr, err := c.df(ctx, rawPayload)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	claims := clmstr.BaseClaims()
 	nowUnix := timeNow().Unix()
 
 	if nowUnix < (claims.Iat - c.skewAllowance) {
-		return false, errors.New("invalid issue time")
+		return nil, errors.New("invalid issue time")
 	}
 
 	if nowUnix > (claims.Exp + c.skewAllowance) {
-		return false, errors.New("invalid expiration time")
+		return nil, errors.New("invalid expiration time")
 	}
 
-	return c.vf(ctx, clmstr), nil
+	return clmstr, nil
 }
 
 func decodeToken(token string) (*jws.Header, []byte, error) {
@@ -288,6 +288,14 @@ func TestVerify(t *testing.T) {
 			if verified != test.wantVerified {
 				t.Errorf("wanted verified? %t, got %t", test.wantVerified, verified)
 			}
+
+			claims, err := vrfy.VerifyClaims(context.Background(), token)
+			if (err != nil) != test.wantErr {
+				t.Errorf("unexpected claims error? %t, got %s", test.wantErr, err)
+			}
+			if (claims != nil) != test.wantVerified {
+				t.Errorf("wanted claims? %t, got %v", test.wantVerified, claims)
+			}
 		})
 	}
 
@@ -26,8 +26,8 @@ type (
 		MFASerialNumber string `envconfig:"AWS_MFA_SERIAL_NUMBER"`
 		Region          string `envconfig:"AWS_REGION"`
 		RoleARN         string `envconfig:"AWS_ROLE_ARN"`
-		SecretKey       string `envconfig:"AWS_SECRET_KEY"`
-		SessionToken    string `envconfig:"AWS_SESSION_TOKEN"`
+		SecretKey       string `envconfig:"AWS_SECRET_KEY" secret:"true"`
+		SessionToken    string `envconfig:"AWS_SESSION_TOKEN" secret:"true"`
 		// Endpoint is an optional endpoint URL (hostname only or fully qualified URI)
 		// that overrides the default endpoint for a client. Leave the value as "nil"
 		// to use the default endpoint. Currently, only the gizmo SNS Publisher is
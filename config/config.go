@@ -2,8 +2,11 @@ package config // import "github.com/NYTimes/gizmo/config"
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
+
+	"github.com/kelseyhightower/envconfig"
 )
 
 // EnvAppName is used as a prefix for environment variable
@@ -24,3 +27,23 @@ func LoadJSONFile(fileName string, cfg interface{}) {
 		log.Fatalf("Unable to parse JSON in config file '%s': %s", fileName, err)
 	}
 }
+
+// LoadWithEnv will read the config file at the given path into cfg with
+// LoadFile and then override its values with any environment variables set
+// for it, using envconfig with EnvAppName as the prefix. Values already set
+// in cfg act as defaults, so env vars take precedence over the file and the
+// file over those defaults. The file is skipped if path is empty.
+//
+// Fields with an envconfig `default` tag are always set by the env
+// overlay, so set defaults on cfg before calling LoadWithEnv instead.
+func LoadWithEnv(path string, cfg interface{}) error {
+	if path != "" {
+		if err := LoadFile(path, cfg); err != nil {
+			return err
+		}
+	}
+	if err := envconfig.Process(EnvAppName, cfg); err != nil {
+		return fmt.Errorf("unable to load config from env: %s", err)
+	}
+	return nil
+}
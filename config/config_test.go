@@ -0,0 +1,262 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLoadWithEnv(t *testing.T) {
+	type testConfig struct {
+		Host    string `envconfig:"TEST_HOST"`
+		Port    int    `envconfig:"TEST_PORT"`
+		Debug   bool   `envconfig:"TEST_DEBUG"`
+		Timeout string `envconfig:"TEST_TIMEOUT"`
+	}
+
+	dir, err := ioutil.TempDir("", "gizmo-config")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "conf.json")
+	err = ioutil.WriteFile(path, []byte(`{"Host":"file.example.com","Port":8080}`), 0600)
+	if err != nil {
+		t.Fatalf("unable to write config file: %s", err)
+	}
+
+	os.Setenv("TEST_PORT", "9090")
+	os.Unsetenv("TEST_HOST")
+	os.Unsetenv("TEST_DEBUG")
+	os.Unsetenv("TEST_TIMEOUT")
+	defer os.Unsetenv("TEST_PORT")
+
+	cfg := testConfig{Timeout: "5s", Debug: true}
+	if err := LoadWithEnv(path, &cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := testConfig{
+		Host:    "file.example.com", // file
+		Port:    9090,               // env over file
+		Debug:   true,               // default
+		Timeout: "5s",               // default
+	}
+	if cfg != want {
+		t.Errorf("expected config %+v, got %+v", want, cfg)
+	}
+
+	if err := LoadWithEnv(filepath.Join(dir, "missing.json"), &cfg); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+	os.Setenv("TEST_PORT", "not-a-port")
+	if err := LoadWithEnv("", &cfg); err == nil {
+		t.Error("expected an error for an invalid env var")
+	}
+}
+
+type testProvider map[string]string
+
+func (p testProvider) Get(key string) ([]byte, error) {
+	v, ok := p[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return []byte(v), nil
+}
+
+func TestLoadFromProvider(t *testing.T) {
+	type testConfig struct {
+		Host string
+		Port int
+	}
+	p := testProvider{
+		"myapp/server": `{"Host":"remote.example.com","Port":8080}`,
+		"myapp/bad":    `{"Host":`,
+	}
+
+	var cfg testConfig
+	if err := LoadFromProvider(p, "myapp/server", &cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := testConfig{Host: "remote.example.com", Port: 8080}
+	if cfg != want {
+		t.Errorf("expected config %+v, got %+v", want, cfg)
+	}
+
+	if err := LoadFromProvider(p, "myapp/missing", &cfg); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound for a missing key, got %v", err)
+	}
+	if err := LoadFromProvider(p, "myapp/bad", &cfg); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+type watchConfig struct {
+	LogLevel  string
+	RateLimit int
+}
+
+func (c *watchConfig) Validate() error {
+	if c.RateLimit < 0 {
+		return errors.New("rate limit must not be negative")
+	}
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizmo-config")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "conf.json")
+	write := func(cfg string) {
+		if err := ioutil.WriteFile(path, []byte(cfg), 0600); err != nil {
+			t.Fatalf("unable to write config file: %s", err)
+		}
+	}
+	write(`{"LogLevel":"info","RateLimit":10}`)
+
+	var cfg watchConfig
+	changed := make(chan watchConfig, 1)
+	stop, err := Watch(path, &cfg, func() { changed <- cfg })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer stop()
+	if want := (watchConfig{LogLevel: "info", RateLimit: 10}); cfg != want {
+		t.Errorf("expected initial config %+v, got %+v", want, cfg)
+	}
+
+	write(`{"LogLevel":"debug","RateLimit":20}`)
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	select {
+	case got := <-changed:
+		if want := (watchConfig{LogLevel: "debug", RateLimit: 20}); got != want {
+			t.Errorf("expected reloaded config %+v, got %+v", want, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected onChange to be called after SIGHUP")
+	}
+
+	for _, bad := range []string{`{"LogLevel":"warn","RateLimit":-1}`, `{"LogLevel":`} {
+		write(bad)
+		if err := reload(path, &cfg); err == nil {
+			t.Errorf("expected an error reloading %s", bad)
+		}
+		if want := (watchConfig{LogLevel: "debug", RateLimit: 20}); cfg != want {
+			t.Errorf("expected config to be unchanged %+v, got %+v", want, cfg)
+		}
+	}
+}
+
+type watchLimitsConfig struct {
+	Limits map[string]int
+	Hosts  []string
+}
+
+func (c *watchLimitsConfig) Validate() error {
+	for name, limit := range c.Limits {
+		if limit > 100 {
+			return fmt.Errorf("limit %s must not be over 100", name)
+		}
+	}
+	return nil
+}
+
+func TestWatchInvalidReloadKeepsMaps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizmo-config")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "conf.json")
+	if err := ioutil.WriteFile(path, []byte(`{"Limits":{"api":1},"Hosts":["a"]}`), 0600); err != nil {
+		t.Fatalf("unable to write config file: %s", err)
+	}
+
+	var cfg watchLimitsConfig
+	if err := reload(path, &cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	limits, hosts := cfg.Limits, cfg.Hosts
+
+	if err := ioutil.WriteFile(path, []byte(`{"Limits":{"api":999},"Hosts":["b"]}`), 0600); err != nil {
+		t.Fatalf("unable to write config file: %s", err)
+	}
+	if err := reload(path, &cfg); err == nil {
+		t.Fatal("expected an error reloading an invalid config")
+	}
+
+	want := watchLimitsConfig{Limits: map[string]int{"api": 1}, Hosts: []string{"a"}}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("expected config to be unchanged %+v, got %+v", want, cfg)
+	}
+	if limits["api"] != 1 || hosts[0] != "a" {
+		t.Errorf("expected the previous maps and slices to be unchanged, got %v and %v", limits, hosts)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	type testConfig struct {
+		Host     string
+		Port     int
+		Tags     []string
+		Backends map[string]int `json:"backends"`
+	}
+
+	dir, err := ioutil.TempDir("", "gizmo-config")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"conf.json": `{"Host":"example.com","Port":8080,"Tags":["a","b"],"backends":{"one":1,"two":2}}`,
+		"conf.toml": "Host = \"example.com\"\nPort = 8080\nTags = [\"a\", \"b\"]\n\n[backends]\none = 1\ntwo = 2\n",
+		"conf.yaml": "host: example.com\nport: 8080\ntags:\n  - a\n  - b\nbackends:\n  one: 1\n  two: 2\n",
+		"conf.yml":  "Host: example.com\nPort: 8080\nTags: [a, b]\nbackends: {one: 1, two: 2}\n",
+		"yaml.conf": "host: example.com\nport: 8080\ntags: [a, b]\nbackends: {one: 1, two: 2}\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatalf("unable to write config file: %s", err)
+		}
+	}
+
+	want := testConfig{
+		Host:     "example.com",
+		Port:     8080,
+		Tags:     []string{"a", "b"},
+		Backends: map[string]int{"one": 1, "two": 2},
+	}
+	for _, name := range []string{"conf.json", "conf.toml", "conf.yaml", "conf.yml"} {
+		var cfg testConfig
+		if err := LoadFile(filepath.Join(dir, name), &cfg); err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s: expected config %+v, got %+v", name, want, cfg)
+		}
+	}
+
+	var cfg testConfig
+	path := filepath.Join(dir, "yaml.conf")
+	if err := LoadFile(path, &cfg); err == nil {
+		t.Error("expected an error loading YAML as JSON")
+	}
+	if err := LoadFileAs(path, FormatYAML, &cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("expected config %+v, got %+v", want, cfg)
+	}
+}
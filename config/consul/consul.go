@@ -0,0 +1,93 @@
+package consul // import "github.com/NYTimes/gizmo/config/consul"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/kelseyhightower/envconfig"
+)
+
+// DefaultAddr is the default address of the Consul HTTP API.
+const DefaultAddr = "http://127.0.0.1:8500"
+
+// Config holds everything you need to read
+// values from a Consul KV store.
+type Config struct {
+	Addr       string `envconfig:"CONSUL_HTTP_ADDR"`
+	Token      string `envconfig:"CONSUL_HTTP_TOKEN" secret:"true"`
+	Datacenter string `envconfig:"CONSUL_DATACENTER"`
+	// Timeout is the timeout for each request to Consul.
+	// It defaults to 10 seconds.
+	Timeout time.Duration `envconfig:"CONSUL_TIMEOUT"`
+}
+
+// LoadConfigFromEnv will attempt to load a Consul Config
+// from environment variables.
+func LoadConfigFromEnv() Config {
+	var cfg Config
+	envconfig.Process("", &cfg)
+	return cfg
+}
+
+// Provider is a config.Provider that reads raw values
+// from the Consul KV HTTP API.
+type Provider struct {
+	cfg Config
+	hc  *http.Client
+}
+
+var _ config.Provider = &Provider{}
+
+// NewProvider will return a Provider for the given Config. If no
+// address is set, DefaultAddr is used.
+func NewProvider(cfg Config) *Provider {
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultAddr
+	}
+	if !strings.Contains(cfg.Addr, "://") {
+		cfg.Addr = "http://" + cfg.Addr
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Provider{cfg: cfg, hc: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// Get will return the raw value stored at the given key. If the key does
+// not exist, config.ErrKeyNotFound is returned.
+func (p *Provider) Get(key string) ([]byte, error) {
+	q := url.Values{"raw": {""}}
+	if p.cfg.Datacenter != "" {
+		q.Set("dc", p.cfg.Datacenter)
+	}
+	u := strings.TrimSuffix(p.cfg.Addr, "/") + "/v1/kv/" + strings.TrimPrefix(key, "/") + "?" + q.Encode()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", p.cfg.Token)
+	}
+
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, config.ErrKeyNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("consul returned %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
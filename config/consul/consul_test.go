@@ -0,0 +1,43 @@
+package consul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestProviderGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/myapp/server" {
+			http.NotFound(w, r)
+			return
+		}
+		if _, ok := r.URL.Query()["raw"]; !ok {
+			t.Errorf("expected the raw query param to be set")
+		}
+		if got := r.URL.Query().Get("dc"); got != "dc1" {
+			t.Errorf("expected datacenter %q, got %q", "dc1", got)
+		}
+		if got := r.Header.Get("X-Consul-Token"); got != "secret" {
+			t.Errorf("expected token %q, got %q", "secret", got)
+		}
+		w.Write([]byte(`{"HTTPPort":8080}`))
+	}))
+	defer srv.Close()
+
+	p := NewProvider(Config{Addr: srv.URL, Token: "secret", Datacenter: "dc1"})
+
+	b, err := p.Get("myapp/server")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := string(b); got != `{"HTTPPort":8080}` {
+		t.Errorf("expected value %q, got %q", `{"HTTPPort":8080}`, got)
+	}
+
+	if _, err := p.Get("missing"); err != config.ErrKeyNotFound {
+		t.Errorf("expected config.ErrKeyNotFound, got %v", err)
+	}
+}
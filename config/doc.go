@@ -1,11 +1,12 @@
 /*
-Package config contains a handful of useful functions to load to configuration structs from JSON files or environment variables.
+Package config contains a handful of useful functions to load configuration structs from JSON, TOML or YAML files, environment variables or remote Providers.
 
 The subpackages contain structs meant for managing common configuration options and credentials. There are currently configs for:
 
 * Go Kit Metrics
 * MySQL
 * PostgreSQL
+* Consul (a remote config Provider)
 * AWS (S3, DynamoDB, ElastiCache)
 */
 package config
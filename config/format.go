@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Format is the format of a config file.
+type Format string
+
+// Supported config file formats.
+const (
+	// FormatAuto will pick the format from the file extension,
+	// falling back to JSON for unknown extensions.
+	FormatAuto Format = ""
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+	FormatYAML Format = "yaml"
+)
+
+// FormatFromPath returns the Format for the extension of the given
+// path: '.toml' for TOML, '.yaml' or '.yml' for YAML and JSON for
+// anything else.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// LoadFile will read the config file at the given path into cfg, picking
+// the format from the file extension. See LoadFileAs for details.
+func LoadFile(path string, cfg interface{}) error {
+	return LoadFileAs(path, FormatAuto, cfg)
+}
+
+// LoadFileAs will read the config file at the given path into cfg using the
+// given format, or the one from the file extension if it is FormatAuto.
+//
+// TOML and YAML files are converted to JSON before they are unmarshaled,
+// so all formats match field names the same way and honor `json` tags,
+// letting any config struct that can be loaded from JSON, like
+// server.Config, be loaded from TOML or YAML as well.
+func LoadFileAs(path string, format Format, cfg interface{}) error {
+	cb, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file '%s': %s", path, err)
+	}
+	if format == FormatAuto {
+		format = FormatFromPath(path)
+	}
+	if err = unmarshal(format, cb, cfg); err != nil {
+		return fmt.Errorf("unable to parse %s in config file '%s': %s", strings.ToUpper(string(format)), path, err)
+	}
+	return nil
+}
+
+func unmarshal(format Format, b []byte, cfg interface{}) error {
+	switch format {
+	case FormatJSON:
+	case FormatTOML:
+		var v map[string]interface{}
+		if _, err := toml.DecodeReader(bytes.NewReader(b), &v); err != nil {
+			return err
+		}
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return err
+		}
+	case FormatYAML:
+		var v interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		var err error
+		if b, err = json.Marshal(yamlToJSON(v)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported config format %q", format)
+	}
+	return json.Unmarshal(b, cfg)
+}
+
+// yamlToJSON will convert the map[interface{}]interface{} values decoded
+// by the yaml package into map[string]interface{} values encoding/json
+// can marshal.
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = yamlToJSON(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = yamlToJSON(val)
+		}
+	}
+	return v
+}
@@ -11,7 +11,7 @@ import (
 // Config holds everything you need to
 // connect and interact with a MySQL DB.
 type Config struct {
-	Pw              string `envconfig:"MYSQL_PW"`
+	Pw              string `envconfig:"MYSQL_PW" secret:"true"`
 	User            string `envconfig:"MYSQL_USER"`
 	Port            int    `envconfig:"MYSQL_PORT"`
 	DBName          string `envconfig:"MYSQL_DB_NAME"`
@@ -12,7 +12,7 @@ import (
 // connect and interact with a PostgreSQL DB.
 type Config struct {
 	User    string `envconfig:"POSTGRESQL_USER"`
-	Pw      string `envconfig:"POSTGRESQL_PW"`
+	Pw      string `envconfig:"POSTGRESQL_PW" secret:"true"`
 	Host    string `envconfig:"POSTGRESQL_HOST_NAME"`
 	Port    int    `envconfig:"POSTGRESQL_PORT"`
 	DBName  string `envconfig:"POSTGRESQL_DB_NAME"`
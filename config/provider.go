@@ -0,0 +1,37 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrKeyNotFound should be returned by a Provider when the
+// requested key does not exist.
+var ErrKeyNotFound = errors.New("config: key not found")
+
+// Provider is a remote source of configuration, like a key/value store.
+// See the config/consul package for a Consul implementation.
+type Provider interface {
+	// Get returns the raw value stored at the given key.
+	Get(key string) ([]byte, error)
+}
+
+// LoadFromProvider will fetch the JSON value stored at the given key
+// from the Provider and unmarshal it into dst. Errors from the Provider
+// are wrapped, so a missing key can be checked for with
+// errors.Is(err, ErrKeyNotFound). For example, a
+// server.Config can be loaded from Consul with:
+//
+//	var cfg server.Config
+//	err := config.LoadFromProvider(consul.NewProvider(consul.LoadConfigFromEnv()), "myapp/server", &cfg)
+func LoadFromProvider(p Provider, key string, dst interface{}) error {
+	b, err := p.Get(key)
+	if err != nil {
+		return fmt.Errorf("unable to get config key '%s': %w", key, err)
+	}
+	if err = json.Unmarshal(b, dst); err != nil {
+		return fmt.Errorf("unable to parse JSON in config key '%s': %s", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+)
+
+// Validator can be implemented by config structs to be checked before
+// Watch swaps in reloaded values.
+type Validator interface {
+	Validate() error
+}
+
+// Watch will load the config file at the given path into dst, in any format
+// supported by LoadFile, and then reload it every time the process receives
+// a SIGHUP, calling onChange after each successful reload so the new values
+// can be applied. dst must be a pointer to a struct.
+//
+// Reloads are decoded into a deep copy of dst, so values missing from the
+// file keep their current ones and maps and slices shared with dst are
+// never written to, and, if dst implements Validator,
+// validated before they are swapped in, so a partial or invalid file never
+// changes dst. Failed reloads are logged. dst is written from the signal
+// handling goroutine, so reads of dst that race with a reload must be
+// synchronized by the caller, for example by copying the values they need
+// in onChange. Call the returned func to stop watching.
+func Watch(path string, dst interface{}, onChange func()) (stop func(), err error) {
+	if v := reflect.ValueOf(dst); v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, errors.New("config: Watch requires a non-nil pointer")
+	}
+	if err := reload(path, dst); err != nil {
+		return nil, err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigs:
+				if err := reload(path, dst); err != nil {
+					log.Printf("unable to reload config: %s", err)
+					continue
+				}
+				if onChange != nil {
+					onChange()
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}, nil
+}
+
+// reload will read and validate the config file into a deep copy of
+// dst and only then overwrite dst with it.
+func reload(path string, dst interface{}) error {
+	cur := reflect.ValueOf(dst).Elem()
+	next := reflect.New(cur.Type())
+	next.Elem().Set(deepCopy(cur))
+	if err := LoadFile(path, next.Interface()); err != nil {
+		return err
+	}
+	if v, ok := next.Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid config in file '%s': %s", path, err)
+		}
+	}
+	cur.Set(next.Elem())
+	return nil
+}
+
+// deepCopy returns a copy of v that shares no pointers, maps or slices with
+// it. Unexported struct fields are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	}
+	return v
+}
@@ -3,6 +3,7 @@ package aws // import "github.com/NYTimes/gizmo/pubsub/aws"
 import (
 	"encoding/base64"
 	"errors"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -109,6 +110,11 @@ var (
 	defaultSQSDeleteBufferSize = 0
 
 	defaultSQSConsumeBase64 = true
+
+	// defaultSQSMaxVisibilityExtension is the default maximum time the
+	// subscriber will keep extending the visibility timeout of a message.
+	// It is the longest time SQS allows a message to stay invisible.
+	defaultSQSMaxVisibilityExtension = 12 * time.Hour
 )
 
 func defaultSQSConfig(cfg *SQSConfig) {
@@ -131,6 +137,10 @@ func defaultSQSConfig(cfg *SQSConfig) {
 	if cfg.ConsumeBase64 == nil {
 		cfg.ConsumeBase64 = &defaultSQSConsumeBase64
 	}
+
+	if cfg.MaxVisibilityExtension == nil {
+		cfg.MaxVisibilityExtension = &defaultSQSMaxVisibilityExtension
+	}
 }
 
 type (
@@ -150,12 +160,20 @@ type (
 
 		stop   chan chan error
 		sqsErr error
+		// done is closed by Stop to stop extending the visibility
+		// timeout of messages still in flight.
+		done chan struct{}
 	}
 
 	// SQSMessage is the SQS implementation of `SubscriberMessage`.
 	subscriberMessage struct {
 		sub     *subscriber
 		message *sqs.Message
+
+		// extending is closed to stop the visibility
+		// timeout extension, if there is one.
+		extending chan struct{}
+		once      sync.Once
 	}
 
 	deleteRequest struct {
@@ -190,12 +208,17 @@ func NewSubscriber(cfg SQSConfig) (pubsub.Subscriber, error) {
 		cfg:      cfg,
 		toDelete: make(chan *deleteRequest),
 		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
 	}
 
 	if (len(cfg.QueueName) == 0) && (len(cfg.QueueURL) == 0) {
 		return s, errors.New("sqs queue name or url is required")
 	}
 
+	if cfg.VisibilityTimeout != nil && *cfg.VisibilityTimeout < time.Second {
+		return s, errors.New("sqs visibility timeout must be at least 1s")
+	}
+
 	sess, err := session.NewSession()
 	if err != nil {
 		return s, err
@@ -267,6 +290,7 @@ func (m *subscriberMessage) ExtendDoneDeadline(d time.Duration) error {
 // the `SQSDeleteBufferSize` will be 0, so this will block until the
 // message has been deleted.
 func (m *subscriberMessage) Done() error {
+	m.stopExtending()
 	defer m.sub.decrementInFlight()
 	receipt := make(chan error)
 	m.sub.toDelete <- &deleteRequest{
@@ -279,6 +303,51 @@ func (m *subscriberMessage) Done() error {
 	return <-receipt
 }
 
+// Nack will stop extending the visibility timeout of the message and make
+// it visible again right away, so it is redelivered.
+func (m *subscriberMessage) Nack() error {
+	m.stopExtending()
+	defer m.sub.decrementInFlight()
+	return m.ExtendDoneDeadline(0)
+}
+
+// extendVisibility will extend the visibility timeout of the message
+// before it runs out until the message is done or nacked, the subscriber
+// is stopped or it hits the max visibility extension.
+func (m *subscriberMessage) extendVisibility(timeout, max time.Duration) {
+	stop := make(chan struct{})
+	m.extending = stop
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		deadline := time.After(max)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-m.sub.done:
+				return
+			case <-deadline:
+				pubsub.Log.Warnf("message %s hit the max visibility extension of %s",
+					aws.StringValue(m.message.MessageId), max)
+				return
+			case <-ticker.C:
+				if err := m.ExtendDoneDeadline(timeout); err != nil {
+					pubsub.Log.Warnf("unable to extend message visibility: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+func (m *subscriberMessage) stopExtending() {
+	m.once.Do(func() {
+		if m.extending != nil {
+			close(m.extending)
+		}
+	})
+}
+
 // Start will start consuming messages on the SQS queue
 // and emit any messages to the returned channel.
 // If it encounters any issues, it will populate the Err() error
@@ -300,11 +369,15 @@ func (s *subscriber) Start() <-chan pubsub.SubscriberMessage {
 			default:
 				// get messages
 				pubsub.Log.Debugf("receiving messages")
-				resp, err = s.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{
+				input := &sqs.ReceiveMessageInput{
 					MaxNumberOfMessages: s.cfg.MaxMessages,
 					QueueUrl:            s.queueURL,
 					WaitTimeSeconds:     s.cfg.TimeoutSeconds,
-				})
+				}
+				if s.cfg.VisibilityTimeout != nil {
+					input.VisibilityTimeout = aws.Int64(int64(s.cfg.VisibilityTimeout.Seconds()))
+				}
+				resp, err = s.sqs.ReceiveMessage(input)
 				if err != nil {
 					// we've encountered a major error
 					// this will set the error value and close the channel
@@ -325,10 +398,14 @@ func (s *subscriber) Start() <-chan pubsub.SubscriberMessage {
 
 				// for each message, pass to output
 				for _, msg := range resp.Messages {
-					output <- &subscriberMessage{
+					m := &subscriberMessage{
 						sub:     s,
 						message: msg,
 					}
+					if s.cfg.VisibilityTimeout != nil && *s.cfg.VisibilityTimeout > 0 {
+						m.extendVisibility(*s.cfg.VisibilityTimeout, *s.cfg.MaxVisibilityExtension)
+					}
+					output <- m
 					s.incrementInFlight()
 				}
 			}
@@ -383,7 +460,9 @@ func (s *subscriber) Stop() error {
 	}
 	exit := make(chan error)
 	s.stop <- exit
-	atomic.SwapUint32(&s.stopped, uint32(1))
+	if atomic.SwapUint32(&s.stopped, uint32(1)) == 0 {
+		close(s.done)
+	}
 	return <-exit
 }
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -54,6 +55,7 @@ func TestSubscriberNoBase64(t *testing.T) {
 		cfg:      cfg,
 		toDelete: make(chan *deleteRequest),
 		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
 	}
 
 	queue := sub.Start()
@@ -78,6 +80,7 @@ func TestSQSReceiveError(t *testing.T) {
 		cfg:      cfg,
 		toDelete: make(chan *deleteRequest),
 		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
 	}
 
 	queue := sub.Start()
@@ -115,6 +118,7 @@ func TestSQSDoneAfterStop(t *testing.T) {
 		cfg:      cfg,
 		toDelete: make(chan *deleteRequest),
 		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
 	}
 
 	queue := sub.Start()
@@ -154,6 +158,7 @@ func TestExtendDoneTimeout(t *testing.T) {
 		cfg:      cfg,
 		toDelete: make(chan *deleteRequest),
 		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
 	}
 
 	queue := sub.Start()
@@ -169,6 +174,122 @@ func TestExtendDoneTimeout(t *testing.T) {
 	}
 }
 
+func TestVisibilityExtension(t *testing.T) {
+	slow, failed := "slow handler", "failed handler"
+	sqstest := &TestSQSAPI{
+		Messages: [][]*sqs.Message{
+			{
+				{
+					Body:          &slow,
+					ReceiptHandle: &slow,
+				},
+				{
+					Body:          &failed,
+					ReceiptHandle: &failed,
+				},
+			},
+		},
+	}
+
+	fals := false
+	timeout := 20 * time.Millisecond
+	cfg := SQSConfig{ConsumeBase64: &fals, VisibilityTimeout: &timeout}
+	defaultSQSConfig(&cfg)
+	sub := &subscriber{
+		sqs:      sqstest,
+		cfg:      cfg,
+		toDelete: make(chan *deleteRequest),
+		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
+	}
+
+	queue := sub.Start()
+	defer sub.Stop()
+
+	// a slow handler that succeeds
+	msg := <-queue
+	time.Sleep(5 * timeout)
+	if extended, deleted := sqstest.counts(slow); extended < 2 || deleted != 0 {
+		t.Errorf("expected the visibility to be extended while the handler runs and nothing deleted, got %d extensions and %d deletes",
+			extended, deleted)
+	}
+	msg.Done()
+	extended, deleted := sqstest.counts(slow)
+	if deleted != 1 || *sqstest.Deleted[0].ReceiptHandle != slow {
+		t.Errorf("expected the successful message to be deleted, got %d deletes", deleted)
+	}
+	time.Sleep(2 * timeout)
+	if got, _ := sqstest.counts(slow); got > extended+1 {
+		t.Errorf("expected the visibility extension to stop after Done, got %d more extensions", got-extended)
+	}
+
+	// a handler that fails
+	msg = <-queue
+	nacker, ok := msg.(interface{ Nack() error })
+	if !ok {
+		t.Fatal("expected SQS messages to have a Nack method")
+	}
+	if err := nacker.Nack(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sqstest.mu.Lock()
+	last := sqstest.Extended[len(sqstest.Extended)-1]
+	sqstest.mu.Unlock()
+	if *last.ReceiptHandle != failed || *last.VisibilityTimeout != 0 {
+		t.Errorf("expected the failed message to be made visible again, got timeout %d for %q",
+			*last.VisibilityTimeout, *last.ReceiptHandle)
+	}
+	if _, deleted := sqstest.counts(slow); deleted != 1 {
+		t.Errorf("expected the failed message not to be deleted, got %d deletes", deleted)
+	}
+}
+
+func TestVisibilityExtensionStop(t *testing.T) {
+	test := "in flight"
+	sqstest := &TestSQSAPI{
+		Messages: [][]*sqs.Message{
+			{
+				{
+					Body:          &test,
+					ReceiptHandle: &test,
+				},
+			},
+		},
+	}
+
+	fals := false
+	timeout := 20 * time.Millisecond
+	sleep := time.Millisecond
+	cfg := SQSConfig{ConsumeBase64: &fals, VisibilityTimeout: &timeout, SleepInterval: &sleep}
+	defaultSQSConfig(&cfg)
+	sub := &subscriber{
+		sqs:      sqstest,
+		cfg:      cfg,
+		toDelete: make(chan *deleteRequest),
+		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
+	}
+
+	queue := sub.Start()
+	<-queue
+	if err := sub.Stop(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	extended, _ := sqstest.counts(test)
+	time.Sleep(3 * timeout)
+	if got, _ := sqstest.counts(test); got > extended+1 {
+		t.Errorf("expected the visibility extension to stop after Stop, got %d more extensions", got-extended)
+	}
+}
+
+func TestNewSubscriberVisibilityTimeout(t *testing.T) {
+	timeout := 500 * time.Millisecond
+	_, err := NewSubscriber(SQSConfig{QueueName: "test", VisibilityTimeout: &timeout})
+	if err == nil {
+		t.Error("expected an error for a visibility timeout below a second")
+	}
+}
+
 func verifySQSSub(t *testing.T, queue <-chan pubsub.SubscriberMessage, testsqs *TestSQSAPI, want string, index int) {
 	gotRaw := <-queue
 	got := string(gotRaw.Message())
@@ -225,6 +346,7 @@ func TestSubscriber(t *testing.T) {
 		cfg:      cfg,
 		toDelete: make(chan *deleteRequest),
 		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
 	}
 
 	queue := sub.Start()
@@ -310,6 +432,7 @@ func BenchmarkSubscriber_Proto(b *testing.B) {
 		cfg:      cfg,
 		toDelete: make(chan *deleteRequest),
 		stop:     make(chan chan error, 1),
+		done:     make(chan struct{}),
 	}
 	queue := sub.Start()
 	for i := 0; i < b.N; i++ {
@@ -323,6 +446,7 @@ func BenchmarkSubscriber_Proto(b *testing.B) {
 }
 
 type TestSQSAPI struct {
+	mu       sync.Mutex
 	Offset   int
 	Messages [][]*sqs.Message
 	Deleted  []*sqs.DeleteMessageBatchRequestEntry
@@ -342,15 +466,30 @@ func (s *TestSQSAPI) ReceiveMessage(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessa
 }
 
 func (s *TestSQSAPI) DeleteMessageBatch(i *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.Deleted = append(s.Deleted, i.Entries...)
 	return nil, errNotImpl
 }
 
 func (s *TestSQSAPI) ChangeMessageVisibility(i *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.Extended = append(s.Extended, i)
 	return nil, nil
 }
 
+func (s *TestSQSAPI) counts(receipt string) (extended, deleted int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.Extended {
+		if *e.ReceiptHandle == receipt {
+			extended++
+		}
+	}
+	return extended, len(s.Deleted)
+}
+
 ///////////
 // ALL METHODS BELOW HERE ARE EMPTY AND JUST SATISFYING THE SQSAPI interface
 ///////////
@@ -28,6 +28,15 @@ type (
 		// before returning it. If it is not set in the config, the flag will default
 		// to 'true'.
 		ConsumeBase64 *bool `envconfig:"AWS_SQS_CONSUME_BASE64"`
+		// VisibilityTimeout will make the subscriber receive messages with
+		// this visibility timeout and keep extending it, at half this interval,
+		// until the message is done, nacked or has been in flight for
+		// MaxVisibilityExtension. This keeps long-running handlers from having
+		// their messages redelivered. If not set, the queue's visibility
+		// timeout is used and never extended. It must be at least a second.
+		VisibilityTimeout *time.Duration `envconfig:"AWS_SQS_VISIBILITY_TIMEOUT"`
+		// MaxVisibilityExtension will override the DefaultSQSMaxVisibilityExtension.
+		MaxVisibilityExtension *time.Duration `envconfig:"AWS_SQS_MAX_VISIBILITY_EXTENSION"`
 	}
 
 	// SNSConfig holds the info required to work with Amazon SNS.
@@ -0,0 +1,123 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// BatchPublisher is a Publisher that buffers messages and publishes them
+// with an underlying Publisher in batches, once a batch reaches its max size
+// or has been waiting for the flush interval, whichever comes first. If the
+// underlying Publisher is a MultiPublisher, each batch is published with a
+// single PublishMultiRaw call.
+//
+// Messages of a batch that fails to publish are dropped. The error is
+// returned by the Publish call that filled the batch or, for batches flushed
+// by the interval, by the next call to Publish, PublishRaw, Flush or Stop.
+// Call Stop to publish any buffered messages before shutting down.
+type BatchPublisher struct {
+	pub      Publisher
+	size     int
+	interval time.Duration
+
+	mu    sync.Mutex
+	keys  []string
+	msgs  [][]byte
+	timer *time.Timer
+	err   error
+}
+
+var _ Publisher = &BatchPublisher{}
+
+// NewBatchPublisher will return a BatchPublisher that publishes batches of up
+// to size messages with the given Publisher and flushes partial batches after
+// interval. If interval is 0, partial batches are only flushed by Flush or Stop.
+func NewBatchPublisher(pub Publisher, size int, interval time.Duration) *BatchPublisher {
+	if size < 1 {
+		size = 1
+	}
+	return &BatchPublisher{pub: pub, size: size, interval: interval}
+}
+
+// Publish will marshal the proto message and buffer it for the next batch.
+func (p *BatchPublisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(ctx, key, mb)
+}
+
+// PublishRaw will buffer the byte array for the next batch and publish
+// the batch if it is full.
+func (p *BatchPublisher) PublishRaw(ctx context.Context, key string, m []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.takeErr(); err != nil {
+		return err
+	}
+	p.keys = append(p.keys, key)
+	p.msgs = append(p.msgs, m)
+	if len(p.msgs) >= p.size {
+		return p.flush(ctx)
+	}
+	if p.timer == nil && p.interval > 0 {
+		p.timer = time.AfterFunc(p.interval, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err := p.flush(context.Background()); err != nil && p.err == nil {
+				p.err = err
+			}
+		})
+	}
+	return nil
+}
+
+// Flush will publish any buffered messages.
+func (p *BatchPublisher) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.takeErr(); err != nil {
+		return err
+	}
+	return p.flush(ctx)
+}
+
+// Stop will publish any buffered messages and stop the flush timer.
+func (p *BatchPublisher) Stop() error {
+	return p.Flush(context.Background())
+}
+
+// takeErr returns and clears the error of the last batch flushed by the
+// interval. The lock must be held.
+func (p *BatchPublisher) takeErr() error {
+	err := p.err
+	p.err = nil
+	return err
+}
+
+// flush will publish the current batch. The lock must be held.
+func (p *BatchPublisher) flush(ctx context.Context) error {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if len(p.msgs) == 0 {
+		return nil
+	}
+	keys, msgs := p.keys, p.msgs
+	p.keys, p.msgs = nil, nil
+
+	if mp, ok := p.pub.(MultiPublisher); ok {
+		return mp.PublishMultiRaw(ctx, keys, msgs)
+	}
+	for i := range msgs {
+		if err := p.pub.PublishRaw(ctx, keys[i], msgs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
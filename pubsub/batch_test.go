@@ -0,0 +1,110 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+func TestBatchPublisherSize(t *testing.T) {
+	pub := &testMultiPublisher{}
+	bp := NewBatchPublisher(pub, 3, 0)
+	ctx := context.Background()
+
+	for i, key := range []string{"a", "b", "c", "d"} {
+		if err := bp.PublishRaw(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if i < 2 && pub.count() != 0 {
+			t.Fatalf("expected no messages to be published before the batch is full, got %d", pub.count())
+		}
+	}
+	if !reflect.DeepEqual(pub.batches, []int{3}) {
+		t.Errorf("expected 1 batch of 3 messages, got %v", pub.batches)
+	}
+
+	if err := bp.Stop(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(pub.batches, []int{3, 1}) {
+		t.Errorf("expected Stop to flush the remaining message, got batches %v", pub.batches)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(pub.keys, want) {
+		t.Errorf("expected keys %v in order, got %v", want, pub.keys)
+	}
+}
+
+func TestBatchPublisherInterval(t *testing.T) {
+	pub := &testPublisher{}
+	bp := NewBatchPublisher(pub, 10, 10*time.Millisecond)
+	ctx := context.Background()
+
+	bp.PublishRaw(ctx, "a", []byte("a"))
+	bp.PublishRaw(ctx, "b", []byte("b"))
+	if got := pub.count(); got != 0 {
+		t.Fatalf("expected no messages to be published right away, got %d", got)
+	}
+	waitFor(t, func() bool { return pub.count() == 2 })
+}
+
+func TestBatchPublisherErrors(t *testing.T) {
+	boom := errors.New("broker is gone")
+	pub := &testPublisher{err: boom}
+	ctx := context.Background()
+
+	bp := NewBatchPublisher(pub, 2, 0)
+	if err := bp.PublishRaw(ctx, "a", []byte("a")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := bp.PublishRaw(ctx, "b", []byte("b")); err != boom {
+		t.Errorf("expected the flush error to be returned, got %v", err)
+	}
+
+	bp = NewBatchPublisher(pub, 10, time.Millisecond)
+	bp.PublishRaw(ctx, "a", []byte("a"))
+	waitFor(t, func() bool {
+		bp.mu.Lock()
+		defer bp.mu.Unlock()
+		return bp.err != nil
+	})
+	if err := bp.Flush(ctx); err != boom {
+		t.Errorf("expected the interval flush error to be returned, got %v", err)
+	}
+	if err := bp.Flush(ctx); err != nil {
+		t.Errorf("expected the error to be returned once, got %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type testMultiPublisher struct {
+	testPublisher
+	batches []int
+}
+
+func (p *testMultiPublisher) PublishMulti(context.Context, []string, []proto.Message) error {
+	return errors.New("not implemented")
+}
+
+func (p *testMultiPublisher) PublishMultiRaw(ctx context.Context, keys []string, msgs [][]byte) error {
+	p.batches = append(p.batches, len(msgs))
+	for i := range msgs {
+		if err := p.PublishRaw(ctx, keys[i], msgs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
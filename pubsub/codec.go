@@ -0,0 +1,205 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// Codec marshals values into message payloads and back.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// ProtoCodec is a Codec for proto.Message values.
+	ProtoCodec Codec = protoCodec{}
+	// JSONCodec is a Codec for JSON values, using encoding/json.
+	JSONCodec Codec = jsonCodec{}
+)
+
+// CodecByName returns the Codec for the given name, 'proto' or 'json', so
+// it can be selected by config. If name is empty, ProtoCodec is returned.
+func CodecByName(name string) (Codec, error) {
+	switch name {
+	case "", "proto", "protobuf":
+		return ProtoCodec, nil
+	case "json":
+		return JSONCodec, nil
+	}
+	return nil, fmt.Errorf("unknown pubsub codec %q", name)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("proto codec: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("proto codec: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// CodecPublisher is a Publisher that marshals messages with its
+// Codec before publishing them with an underlying Publisher.
+type CodecPublisher struct {
+	Publisher
+	codec Codec
+}
+
+// WithCodec will wrap the given Publisher so messages are marshaled with
+// the given Codec, like JSONCodec, instead of as protobufs.
+func WithCodec(pub Publisher, codec Codec) *CodecPublisher {
+	return &CodecPublisher{Publisher: pub, codec: codec}
+}
+
+// Publish will marshal the proto message with the Codec and publish it.
+func (p *CodecPublisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	return p.PublishValue(ctx, key, m)
+}
+
+// PublishValue will marshal any value supported by the Codec and publish it.
+func (p *CodecPublisher) PublishValue(ctx context.Context, key string, v interface{}) error {
+	b, err := p.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(ctx, key, b)
+}
+
+// CodecMessage is a SubscriberMessage emitted by a Subscriber wrapped with
+// SubscriberWithCodec. Message returns the raw payload and Decode unmarshals
+// it with the Subscriber's Codec. If the underlying message is a RetryMessage
+// or has a Nack method, so does the CodecMessage.
+type CodecMessage interface {
+	SubscriberMessage
+	Decode(v interface{}) error
+}
+
+type (
+	codecSubscriber struct {
+		Subscriber
+		codec Codec
+
+		stop chan struct{}
+		once sync.Once
+	}
+
+	codecMessage struct {
+		SubscriberMessage
+		codec Codec
+	}
+
+	// codecRetryMessage is a codecMessage for a RetryMessage.
+	codecRetryMessage struct {
+		*codecMessage
+		retry RetryMessage
+	}
+
+	// codecNackMessage is a codecMessage for a message with a Nack method.
+	codecNackMessage struct {
+		*codecMessage
+		nack func() error
+	}
+
+	// codecRetryNackMessage is a codecMessage for a RetryMessage with a
+	// Nack method.
+	codecRetryNackMessage struct {
+		codecRetryMessage
+		nack func() error
+	}
+)
+
+// SubscriberWithCodec will wrap the given Subscriber so the messages it
+// emits are CodecMessages that decode their payload with the given Codec.
+func SubscriberWithCodec(sub Subscriber, codec Codec) Subscriber {
+	return &codecSubscriber{Subscriber: sub, codec: codec, stop: make(chan struct{})}
+}
+
+// Start will start the underlying Subscriber and emit
+// its messages as CodecMessages. Once Stop is called, messages
+// that are not received are dropped for the backend to redeliver.
+func (s *codecSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	msgs := s.Subscriber.Start()
+	go func() {
+		defer close(output)
+		for m := range msgs {
+			select {
+			case output <- newCodecMessage(m, s.codec):
+			case <-s.stop:
+			}
+		}
+	}()
+	return output
+}
+
+// Stop will stop emitting messages and stop the underlying Subscriber.
+func (s *codecSubscriber) Stop() error {
+	s.once.Do(func() { close(s.stop) })
+	return s.Subscriber.Stop()
+}
+
+// newCodecMessage will wrap the message in a CodecMessage that
+// forwards the RetryMessage and Nack methods it has.
+func newCodecMessage(m SubscriberMessage, codec Codec) SubscriberMessage {
+	cm := &codecMessage{SubscriberMessage: m, codec: codec}
+	nacker, canNack := m.(interface{ Nack() error })
+	rm, canRetry := m.(RetryMessage)
+	switch {
+	case canRetry && canNack:
+		return codecRetryNackMessage{codecRetryMessage: codecRetryMessage{codecMessage: cm, retry: rm}, nack: nacker.Nack}
+	case canRetry:
+		return codecRetryMessage{codecMessage: cm, retry: rm}
+	case canNack:
+		return codecNackMessage{codecMessage: cm, nack: nacker.Nack}
+	}
+	return cm
+}
+
+// Decode will unmarshal the message payload into v.
+func (m *codecMessage) Decode(v interface{}) error {
+	return m.codec.Unmarshal(m.Message(), v)
+}
+
+// Attempt returns the delivery attempt of the underlying RetryMessage.
+func (m codecRetryMessage) Attempt() int {
+	return m.retry.Attempt()
+}
+
+// Fail will fail the underlying RetryMessage.
+func (m codecRetryMessage) Fail(err error) error {
+	return m.retry.Fail(err)
+}
+
+// Nack will nack the underlying message.
+func (m codecNackMessage) Nack() error {
+	return m.nack()
+}
+
+// Nack will nack the underlying message.
+func (m codecRetryNackMessage) Nack() error {
+	return m.nack()
+}
@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCodecMessageForwarding(t *testing.T) {
+	plain := &testMessage{body: []byte(`"plain"`)}
+	m := newCodecMessage(plain, JSONCodec)
+	if _, ok := m.(RetryMessage); ok {
+		t.Error("expected a plain message not to be a RetryMessage")
+	}
+	if _, ok := m.(interface{ Nack() error }); ok {
+		t.Error("expected a plain message not to have a Nack method")
+	}
+
+	nacked := &testNackMessage{testMessage: testMessage{body: []byte(`"nack"`)}}
+	m = newCodecMessage(nacked, JSONCodec)
+	nacker, ok := m.(interface{ Nack() error })
+	if !ok {
+		t.Fatal("expected the message to have a Nack method")
+	}
+	if err := nacker.Nack(); err != nil || nacked.nacked != 1 {
+		t.Errorf("expected the underlying message to be nacked, got %d nacks and %v", nacked.nacked, err)
+	}
+	var got string
+	if err := m.(CodecMessage).Decode(&got); err != nil || got != "nack" {
+		t.Errorf("expected the message to decode to %q, got %q and %v", "nack", got, err)
+	}
+
+	retried := &testMessage{body: []byte(`"retry"`)}
+	sub := SubscriberWithCodec(NewRetrySubscriber(&testSubscriber{msgs: []*testMessage{retried}},
+		RetryConfig{MaxRetries: 1}), JSONCodec)
+	msgs := sub.Start()
+	rm, ok := (<-msgs).(RetryMessage)
+	if !ok {
+		t.Fatal("expected the message to be a RetryMessage")
+	}
+	if rm.Attempt() != 1 {
+		t.Errorf("expected the first attempt, got %d", rm.Attempt())
+	}
+	if err := rm.Fail(errors.New("boom")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rm, ok = (<-msgs).(RetryMessage)
+	if !ok || rm.Attempt() != 2 {
+		t.Fatal("expected the failed message to be retried")
+	}
+	if _, ok := rm.(CodecMessage); !ok {
+		t.Error("expected the retried message to be a CodecMessage")
+	}
+	rm.Done()
+	if _, ok := <-msgs; ok {
+		t.Error("expected the channel to be closed")
+	}
+	if retried.done != 1 {
+		t.Errorf("expected the message to be done once, got %d", retried.done)
+	}
+}
+
+func TestCodecSubscriberStop(t *testing.T) {
+	sub := SubscriberWithCodec(&testSubscriber{msgs: []*testMessage{{}, {}}}, JSONCodec)
+	msgs := sub.Start()
+	if err := sub.Stop(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-msgs:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("expected the channel to be closed after Stop")
+		}
+	}
+}
+
+type testNackMessage struct {
+	testMessage
+	nacked int
+}
+
+func (m *testNackMessage) Nack() error {
+	m.nacked++
+	return nil
+}
@@ -0,0 +1,55 @@
+package pubsub
+
+import (
+	"golang.org/x/net/context"
+)
+
+// StartContext will start the Subscriber and emit its messages to the
+// returned channel until the context is done. Once it is, no more messages
+// are emitted, the Subscriber is stopped and the returned channel is closed.
+// Messages that were already emitted can still be marked as done, as far as
+// the backend allows it, while any the Subscriber produces after the context
+// is done are left for the backend to redeliver.
+func StartContext(ctx context.Context, sub Subscriber) <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	msgs := sub.Start()
+	go func() {
+		defer close(output)
+		for {
+			select {
+			case <-ctx.Done():
+				stopContext(sub, msgs)
+				return
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+				// both cases may be ready, so check the context again
+				// before emitting the message.
+				if ctx.Err() != nil {
+					stopContext(sub, msgs)
+					return
+				}
+				select {
+				case output <- m:
+				case <-ctx.Done():
+					stopContext(sub, msgs)
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// stopContext will stop the Subscriber while draining its channel so it
+// cannot block on emitting messages nobody will receive.
+func stopContext(sub Subscriber, msgs <-chan SubscriberMessage) {
+	go func() {
+		for range msgs {
+		}
+	}()
+	if err := sub.Stop(); err != nil {
+		Log.WithError(err).Error("unable to stop subscriber")
+	}
+}
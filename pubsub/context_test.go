@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestStartContext(t *testing.T) {
+	sub := &chanSubscriber{msgs: make(chan SubscriberMessage), stop: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := StartContext(ctx, sub)
+
+	sent := &testMessage{body: []byte("first")}
+	sub.msgs <- sent
+	select {
+	case m := <-msgs:
+		if string(m.Message()) != "first" {
+			t.Errorf("expected message %q, got %q", "first", m.Message())
+		}
+		cancel()
+		go func() {
+			select {
+			case sub.msgs <- &testMessage{body: []byte("late")}:
+			case <-sub.stop:
+			}
+		}()
+		// delivered messages can still be done after cancellation
+		if err := m.Done(); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a message")
+	}
+
+	select {
+	case m, ok := <-msgs:
+		if ok {
+			t.Errorf("expected no messages after cancellation, got %q", m.Message())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the message channel to be closed after cancellation")
+	}
+	select {
+	case <-sub.stop:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the subscriber to be stopped")
+	}
+	if sent.done != 1 {
+		t.Errorf("expected the delivered message to be done, got %d", sent.done)
+	}
+}
+
+// chanSubscriber is a Subscriber that emits the messages sent on its
+// channel and blocks on them until it is stopped, like most backends.
+type chanSubscriber struct {
+	msgs chan SubscriberMessage
+	stop chan struct{}
+}
+
+func (s *chanSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	go func() {
+		defer close(output)
+		for {
+			select {
+			case m := <-s.msgs:
+				select {
+				case output <- m:
+				case <-s.stop:
+					return
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return output
+}
+
+func (s *chanSubscriber) Err() error { return nil }
+
+func (s *chanSubscriber) Stop() error {
+	close(s.stop)
+	return nil
+}
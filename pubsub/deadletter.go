@@ -0,0 +1,50 @@
+package pubsub
+
+import (
+	"encoding/json"
+
+	"golang.org/x/net/context"
+)
+
+// DeadLetter is a message that could not be processed, along
+// with the details of its failure.
+type DeadLetter struct {
+	// Topic is the topic or queue the message was consumed from.
+	Topic string `json:"topic"`
+	// Error is the error of the message's last attempt.
+	Error string `json:"error"`
+	// Attempts is the number of times the message was attempted.
+	Attempts int `json:"attempts"`
+	// Message is the original message payload.
+	Message []byte `json:"message"`
+}
+
+// DeadLetterPublisher is a generic interface for sending messages that
+// failed on every attempt somewhere they can be inspected, like a dead-letter
+// topic or queue.
+type DeadLetterPublisher interface {
+	PublishDeadLetter(context.Context, *DeadLetter) error
+}
+
+// DeadLetterFunc is an adapter to allow the use of ordinary
+// functions as a DeadLetterPublisher.
+type DeadLetterFunc func(context.Context, *DeadLetter) error
+
+// PublishDeadLetter calls f(ctx, dl).
+func (f DeadLetterFunc) PublishDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	return f(ctx, dl)
+}
+
+// NewDeadLetterPublisher will return a DeadLetterPublisher that emits dead
+// letters as JSON to the topic or queue of the given Publisher, using their
+// original topic as the key. So any Publisher implementation can be used for
+// a dead-letter queue.
+func NewDeadLetterPublisher(pub Publisher) DeadLetterPublisher {
+	return DeadLetterFunc(func(ctx context.Context, dl *DeadLetter) error {
+		b, err := json.Marshal(dl)
+		if err != nil {
+			return err
+		}
+		return pub.PublishRaw(ctx, dl.Topic, b)
+	})
+}
@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+func TestDeadLetterPublisher(t *testing.T) {
+	pub := &testPublisher{}
+	msg := &testMessage{body: []byte(`{"id":1}`)}
+	sub := NewRetrySubscriber(&testSubscriber{msgs: []*testMessage{msg}}, RetryConfig{
+		MaxRetries: 1,
+		Topic:      "orders",
+		DeadLetter: NewDeadLetterPublisher(pub),
+	})
+
+	for m := range sub.Start() {
+		// the handler fails every attempt
+		m.(RetryMessage).Fail(errors.New("unable to save order"))
+	}
+
+	if len(pub.published) != 1 {
+		t.Fatalf("expected 1 dead letter to be published, got %d", len(pub.published))
+	}
+	if got := pub.keys[0]; got != "orders" {
+		t.Errorf("expected the original topic as the key, got %q", got)
+	}
+	var got DeadLetter
+	if err := json.Unmarshal(pub.published[0], &got); err != nil {
+		t.Fatalf("unable to decode dead letter: %s", err)
+	}
+	want := DeadLetter{
+		Topic:    "orders",
+		Error:    "unable to save order",
+		Attempts: 2,
+		Message:  []byte(`{"id":1}`),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected dead letter %+v, got %+v", want, got)
+	}
+	if msg.done != 1 {
+		t.Errorf("expected the message to be done once it is dead-lettered, got %d", msg.done)
+	}
+}
+
+type testPublisher struct {
+	mu        sync.Mutex
+	keys      []string
+	published [][]byte
+	err       error
+}
+
+func (p *testPublisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(ctx, key, b)
+}
+
+func (p *testPublisher) PublishRaw(_ context.Context, key string, m []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.keys = append(p.keys, key)
+	p.published = append(p.published, m)
+	return nil
+}
+
+func (p *testPublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.published)
+}
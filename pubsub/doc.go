@@ -35,5 +35,15 @@ For pubsub via Google's Pubsub, you can use the `pubsub/gcp` package.
 For pubsub via Kafka topics, you can use the `pubsub/kafka` package.
 
 For publishing via HTTP, you can use the `pubsub/http` package.
+
+For in-memory pubsub in local development and tests, you can use the `pubsub/inmem` package.
+
+To publish and consume JSON instead of protobuf messages, wrap a `Publisher` with `WithCodec` and a `Subscriber` with `SubscriberWithCodec`.
+
+To stop a `Subscriber` when a context is done, like on graceful shutdown, start it with `StartContext`.
+
+To handle messages with cross-cutting concerns like tracing and metrics, pass a `Handler` and any `Middleware` to `Process`. `TracingMiddleware` and `MetricsMiddleware` are provided.
+
+To retry failed messages with a backoff before dead-lettering them, wrap any `Subscriber` with `NewRetrySubscriber`. Exhausted messages can be sent to any `Publisher` with `NewDeadLetterPublisher`.
 */
 package pubsub // import "github.com/NYTimes/gizmo/pubsub"
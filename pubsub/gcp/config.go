@@ -1,6 +1,11 @@
 package gcp
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"time"
+
+	gpubsub "cloud.google.com/go/pubsub"
+	"github.com/kelseyhightower/envconfig"
+)
 
 // Config holds common credentials and config values for
 // working with GCP PubSub.
@@ -11,6 +16,17 @@ type Config struct {
 	Topic string `envconfig:"GCP_PUBSUB_TOPIC"`
 	// For subscribing
 	Subscription string `envconfig:"GCP_PUBSUB_SUBSCRIPTION"`
+
+	// MaxOutstandingMessages is the maximum number of messages the
+	// Subscriber will hold without calling Done or Nack. Defaults to 10.
+	MaxOutstandingMessages int `envconfig:"GCP_PUBSUB_MAX_OUTSTANDING_MESSAGES"`
+	// MaxOutstandingBytes is the maximum size of the messages the
+	// Subscriber will hold without calling Done or Nack. If 0, the
+	// client library default is used.
+	MaxOutstandingBytes int `envconfig:"GCP_PUBSUB_MAX_OUTSTANDING_BYTES"`
+	// MaxExtension is the maximum duration the Subscriber will extend
+	// the ack deadline of a message. Defaults to 60 seconds.
+	MaxExtension time.Duration `envconfig:"GCP_PUBSUB_MAX_EXTENSION"`
 }
 
 // LoadConfigFromEnv will attempt to load a PubSub config
@@ -20,3 +36,18 @@ func LoadConfigFromEnv() Config {
 	envconfig.Process("", &ps)
 	return ps
 }
+
+func (c Config) receiveSettings() gpubsub.ReceiveSettings {
+	rs := gpubsub.ReceiveSettings{
+		MaxExtension:           c.MaxExtension,
+		MaxOutstandingMessages: c.MaxOutstandingMessages,
+		MaxOutstandingBytes:    c.MaxOutstandingBytes,
+	}
+	if rs.MaxExtension == 0 {
+		rs.MaxExtension = defaultMaxExtension
+	}
+	if rs.MaxOutstandingMessages == 0 {
+		rs.MaxOutstandingMessages = defaultMaxMessages
+	}
+	return rs
+}
@@ -43,6 +43,26 @@ func NewSubscriber(ctx context.Context, projID, subscription string, opts ...opt
 	}, nil
 }
 
+// NewSubscriberFromConfig will instantiate a new Subscriber for the
+// subscription in the given Config. Any flow control values set on the
+// Config will override the default ReceiveSettings. Cancelling the given
+// context will stop the Subscriber.
+func NewSubscriberFromConfig(ctx context.Context, cfg Config, opts ...option.ClientOption) (*Subscriber, error) {
+	if cfg.ProjectID == "" {
+		return nil, errors.New("project id is required")
+	}
+	if cfg.Subscription == "" {
+		return nil, errors.New("subscription name is required")
+	}
+
+	s, err := NewSubscriber(ctx, cfg.ProjectID, cfg.Subscription, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.SetReceiveSettings(cfg.receiveSettings())
+	return s, nil
+}
+
 var (
 	defaultMaxMessages  = 10
 	defaultMaxExtension = 60 * time.Second
@@ -62,7 +82,13 @@ func (s *Subscriber) Start() <-chan pubsub.SubscriberMessage {
 			if mi, ok := msg.(messageImpl); ok {
 				sm.Attributes = mi.Msg.Attributes
 			}
-			output <- sm
+			select {
+			case output <- sm:
+			case <-ctx.Done():
+				// nobody will handle the message, so hand it back
+				// to pubsub for redelivery.
+				msg.Nack()
+			}
 		})
 		if err != nil {
 			s.Stop()
@@ -120,6 +146,13 @@ func (m *SubMessage) Done() error {
 	return nil
 }
 
+// Nack will signal to pubsub that the Message was not processed and should
+// be redelivered.
+func (m *SubMessage) Nack() error {
+	m.msg.Nack()
+	return nil
+}
+
 // publisher is a Google Cloud Platform PubSub client that allows a user to
 // consume messages via the pubsub.MultiPublisher interface.
 type publisher struct {
@@ -209,6 +242,7 @@ type (
 		ID() string
 		MsgData() []byte
 		Done()
+		Nack()
 	}
 
 	messageImpl struct {
@@ -232,6 +266,10 @@ func (m messageImpl) Done() {
 	m.Msg.Ack()
 }
 
+func (m messageImpl) Nack() {
+	m.Msg.Nack()
+}
+
 func (s subscriptionImpl) Receive(ctx context.Context, f func(context.Context, message)) error {
 	return s.Sub.Receive(ctx, func(ctx context.Context, msg *gpubsub.Message) {
 		f(ctx, messageImpl{msg})
@@ -3,6 +3,7 @@ package gcp
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/NYTimes/gizmo/pubsub"
 	"golang.org/x/net/context"
@@ -41,6 +42,122 @@ func TestGCPSubscriber(t *testing.T) {
 	if ok {
 		t.Errorf("expected subscriber channel to be closed, but it wasn't. Msg: %s", msg)
 	}
+
+	for _, msg := range msgs {
+		if !msg.doned {
+			t.Errorf("expected message %q to be acknowledged", string(msg.data))
+		}
+	}
+}
+
+func TestGCPSubscriberNack(t *testing.T) {
+	msgs := []*testMessage{
+		&testMessage{data: []byte("1")},
+		&testMessage{data: []byte("2")},
+	}
+	gcpSub := &testSubscription{
+		msgs: msgs,
+	}
+
+	testSub := &Subscriber{sub: gcpSub, ctx: context.Background()}
+	pipe := testSub.Start()
+
+	first := <-pipe
+	first.Done()
+
+	second := <-pipe
+	nacker, ok := second.(interface {
+		Nack() error
+	})
+	if !ok {
+		t.Fatalf("expected subscriber message to support Nack, got %T", second)
+	}
+	if err := nacker.Nack(); err != nil {
+		t.Fatalf("unexpected error from Nack: %s", err)
+	}
+
+	testSub.Stop()
+	for range pipe {
+	}
+
+	if !msgs[0].doned || msgs[0].nacked {
+		t.Errorf("expected first message to be acknowledged only, got doned=%t nacked=%t",
+			msgs[0].doned, msgs[0].nacked)
+	}
+	if msgs[1].doned || !msgs[1].nacked {
+		t.Errorf("expected second message to be nacked only, got doned=%t nacked=%t",
+			msgs[1].doned, msgs[1].nacked)
+	}
+}
+
+func TestGCPSubscriberContextCancel(t *testing.T) {
+	msgs := []*testMessage{
+		&testMessage{data: []byte("1")},
+		&testMessage{data: []byte("2")},
+	}
+	gcpSub := &testSubscription{
+		msgs:     msgs,
+		received: make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	testSub := &Subscriber{sub: gcpSub, ctx: ctx}
+	pipe := testSub.Start()
+
+	(<-pipe).Done()
+
+	// the second message is never read, so cancelling should hand it back
+	cancel()
+
+	select {
+	case <-gcpSub.received:
+	case <-time.After(time.Second):
+		t.Fatal("expected subscription to stop receiving after cancellation")
+	}
+
+	if _, ok := <-pipe; ok {
+		t.Error("expected subscriber channel to be closed after cancellation")
+	}
+	if !msgs[1].nacked {
+		t.Error("expected undelivered message to be nacked")
+	}
+}
+
+func TestConfigReceiveSettings(t *testing.T) {
+	got := Config{}.receiveSettings()
+	if got.MaxOutstandingMessages != defaultMaxMessages {
+		t.Errorf("expected default max outstanding messages of %d, got %d",
+			defaultMaxMessages, got.MaxOutstandingMessages)
+	}
+	if got.MaxExtension != defaultMaxExtension {
+		t.Errorf("expected default max extension of %s, got %s",
+			defaultMaxExtension, got.MaxExtension)
+	}
+
+	got = Config{
+		MaxOutstandingMessages: 100,
+		MaxOutstandingBytes:    1 << 20,
+		MaxExtension:           time.Minute,
+	}.receiveSettings()
+	if got.MaxOutstandingMessages != 100 {
+		t.Errorf("expected max outstanding messages of 100, got %d", got.MaxOutstandingMessages)
+	}
+	if got.MaxOutstandingBytes != 1<<20 {
+		t.Errorf("expected max outstanding bytes of %d, got %d", 1<<20, got.MaxOutstandingBytes)
+	}
+	if got.MaxExtension != time.Minute {
+		t.Errorf("expected max extension of %s, got %s", time.Minute, got.MaxExtension)
+	}
+}
+
+func TestNewSubscriberFromConfigValidation(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewSubscriberFromConfig(ctx, Config{Subscription: "sub"}); err == nil {
+		t.Error("expected error for missing project id")
+	}
+	if _, err := NewSubscriberFromConfig(ctx, Config{ProjectID: "proj"}); err == nil {
+		t.Error("expected error for missing subscription")
+	}
 }
 
 func TestSubscriberWithErr(t *testing.T) {
@@ -66,12 +183,15 @@ func TestSubscriberWithErr(t *testing.T) {
 
 type (
 	testMessage struct {
-		data  []byte
-		doned bool
+		data   []byte
+		doned  bool
+		nacked bool
 	}
 
 	testSubscription struct {
 		msgs []*testMessage
+		// received, if set, is closed once Receive returns.
+		received chan struct{}
 
 		givenErr error
 	}
@@ -89,7 +209,14 @@ func (m *testMessage) Done() {
 	m.doned = true
 }
 
+func (m *testMessage) Nack() {
+	m.nacked = true
+}
+
 func (s *testSubscription) Receive(ctx context.Context, f func(context.Context, message)) error {
+	if s.received != nil {
+		defer close(s.received)
+	}
 	// iterate over messages and call f
 	for _, msg := range s.msgs {
 		f(ctx, msg)
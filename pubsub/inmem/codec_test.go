@@ -0,0 +1,86 @@
+package inmem
+
+import (
+	"testing"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"golang.org/x/net/context"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	type article struct {
+		ID    int      `json:"id"`
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+	}
+
+	tests := []struct {
+		name  string
+		codec pubsub.Codec
+		given interface{}
+		into  func() interface{}
+
+		wantEqual func(got interface{}) bool
+	}{
+		{
+			name:  "proto",
+			codec: pubsub.ProtoCodec,
+			given: &wrappers.StringValue{Value: "hello, gizmo"},
+			into:  func() interface{} { return &wrappers.StringValue{} },
+			wantEqual: func(got interface{}) bool {
+				return proto.Equal(got.(proto.Message), &wrappers.StringValue{Value: "hello, gizmo"})
+			},
+		},
+		{
+			name:  "json",
+			codec: pubsub.JSONCodec,
+			given: article{ID: 1, Title: "Gizmo", Tags: []string{"go"}},
+			into:  func() interface{} { return &article{} },
+			wantEqual: func(got interface{}) bool {
+				a := got.(*article)
+				return a.ID == 1 && a.Title == "Gizmo" && len(a.Tags) == 1 && a.Tags[0] == "go"
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			topic := NewTopic()
+			sub := pubsub.SubscriberWithCodec(topic.NewSubscriber(), test.codec)
+			defer sub.Stop()
+			msgs := sub.Start()
+
+			pub := pubsub.WithCodec(topic, test.codec)
+			if err := pub.PublishValue(context.Background(), "key", test.given); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			m := receive(t, msgs).(pubsub.CodecMessage)
+			if len(m.Message()) == 0 {
+				t.Error("expected the raw payload to be available")
+			}
+			got := test.into()
+			if err := m.Decode(got); err != nil {
+				t.Fatalf("unexpected error decoding: %s", err)
+			}
+			if !test.wantEqual(got) {
+				t.Errorf("expected decoded %+v to equal %+v", got, test.given)
+			}
+			if err := m.Done(); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+
+	if _, err := pubsub.ProtoCodec.Marshal(struct{}{}); err == nil {
+		t.Error("expected an error marshaling a non-proto value with the proto codec")
+	}
+	if c, err := pubsub.CodecByName("json"); err != nil || c != pubsub.JSONCodec {
+		t.Errorf("expected the JSON codec, got %v, %v", c, err)
+	}
+	if _, err := pubsub.CodecByName("xml"); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
@@ -0,0 +1,290 @@
+// Package inmem provides an in-memory implementation of the pubsub
+// Publisher and Subscriber interfaces, for wiring services together in local
+// development and tests without a message broker.
+package inmem // import "github.com/NYTimes/gizmo/pubsub/inmem"
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+type (
+	// Topic is an in-memory topic and Publisher. Every message published to
+	// it is delivered to all of its subscribers. Messages published while a
+	// topic has no subscribers are dropped.
+	Topic struct {
+		mu   sync.Mutex
+		subs map[*Subscriber]bool
+	}
+
+	// Subscriber is an in-memory Subscriber of a Topic. It delivers
+	// messages in the order they were published. Messages that are
+	// nacked are redelivered before any newer messages.
+	Subscriber struct {
+		topic *Topic
+
+		mu     sync.Mutex
+		queue  []*Message
+		notify chan struct{}
+		stop   chan struct{}
+		once   sync.Once
+	}
+
+	// Message is the pubsub.SubscriberMessage emitted by a Subscriber.
+	Message struct {
+		key  string
+		body []byte
+		sub  *Subscriber
+
+		mu        sync.Mutex
+		acked     bool
+		nacked    bool
+		delivered int
+	}
+)
+
+var (
+	_ pubsub.MultiPublisher = &Topic{}
+	_ pubsub.Subscriber     = &Subscriber{}
+)
+
+// ErrAcked is returned by Nack if the message is already done.
+var ErrAcked = errors.New("message is already done")
+
+// ErrNacked is returned by Done and Nack if the message is already
+// nacked and waiting to be redelivered.
+var ErrNacked = errors.New("message is already nacked")
+
+// NewTopic will return a new Topic without any subscribers.
+func NewTopic() *Topic {
+	return &Topic{subs: map[*Subscriber]bool{}}
+}
+
+// Publish will marshal the proto message and publish it to the topic.
+func (t *Topic) Publish(ctx context.Context, key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return t.PublishRaw(ctx, key, mb)
+}
+
+// PublishRaw will publish the byte array to every subscriber of the topic.
+func (t *Topic) PublishRaw(_ context.Context, key string, m []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for sub := range t.subs {
+		body := make([]byte, len(m))
+		copy(body, m)
+		sub.enqueue(&Message{key: key, body: body, sub: sub})
+	}
+	return nil
+}
+
+// PublishMulti will marshal and publish multiple proto messages in order.
+func (t *Topic) PublishMulti(ctx context.Context, keys []string, messages []proto.Message) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+	for i := range messages {
+		if err := t.Publish(ctx, keys[i], messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishMultiRaw will publish multiple byte arrays in order.
+func (t *Topic) PublishMultiRaw(ctx context.Context, keys []string, messages [][]byte) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+	for i := range messages {
+		if err := t.PublishRaw(ctx, keys[i], messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewSubscriber will return a new Subscriber that receives every
+// message published to the topic from now on.
+func (t *Topic) NewSubscriber() *Subscriber {
+	s := &Subscriber{
+		topic:  t,
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subs[s] = true
+	return s
+}
+
+// Start will emit the subscriber's messages to the returned channel,
+// one at a time and in order, until the subscriber is stopped.
+func (s *Subscriber) Start() <-chan pubsub.SubscriberMessage {
+	output := make(chan pubsub.SubscriberMessage)
+	go func() {
+		defer close(output)
+		for {
+			m := s.peek()
+			if m == nil {
+				select {
+				case <-s.notify:
+					continue
+				case <-s.stop:
+					return
+				}
+			}
+			nacked := m.deliver()
+			select {
+			case output <- m:
+				s.remove(m)
+			case <-s.notify:
+				// a nacked message may have been put in front
+				m.undeliver(nacked)
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Err always returns nil for in-memory subscribers.
+func (s *Subscriber) Err() error {
+	return nil
+}
+
+// Stop will unsubscribe from the topic and close the channel returned
+// by Start. Any messages that are not done are dropped.
+func (s *Subscriber) Stop() error {
+	s.once.Do(func() {
+		s.topic.mu.Lock()
+		delete(s.topic.subs, s)
+		s.topic.mu.Unlock()
+		close(s.stop)
+	})
+	return nil
+}
+
+func (s *Subscriber) enqueue(m *Message) {
+	s.mu.Lock()
+	s.queue = append(s.queue, m)
+	s.mu.Unlock()
+	s.wake()
+}
+
+// requeue will put the message back at the front of the queue.
+func (s *Subscriber) requeue(m *Message) {
+	s.mu.Lock()
+	s.queue = append([]*Message{m}, s.queue...)
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *Subscriber) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// peek will return the message at the front of the queue or nil
+// if it is empty.
+func (s *Subscriber) peek() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil
+	}
+	return s.queue[0]
+}
+
+// remove will take the delivered message off the queue.
+func (s *Subscriber) remove(m *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, qm := range s.queue {
+		if qm == m {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// deliver will count a delivery of the message before it is emitted and
+// return whether it was nacked, so an aborted delivery can be undone.
+func (m *Message) deliver() (nacked bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nacked = m.nacked
+	m.delivered++
+	m.nacked = false
+	return nacked
+}
+
+func (m *Message) undeliver(nacked bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delivered--
+	m.nacked = nacked
+}
+
+// Key returns the key the message was published with.
+func (m *Message) Key() string {
+	return m.key
+}
+
+// Message will return the message payload.
+func (m *Message) Message() []byte {
+	return m.body
+}
+
+// Deliveries returns the number of times the message has been delivered.
+func (m *Message) Deliveries() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.delivered
+}
+
+// ExtendDoneDeadline has no effect on in-memory messages.
+func (m *Message) ExtendDoneDeadline(time.Duration) error {
+	return nil
+}
+
+// Done will acknowledge the message.
+func (m *Message) Done() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nacked {
+		return ErrNacked
+	}
+	m.acked = true
+	return nil
+}
+
+// Nack will put the message back on its subscriber's queue to be
+// redelivered before any newer messages. Messages that are neither
+// done nor nacked are not redelivered.
+func (m *Message) Nack() error {
+	m.mu.Lock()
+	switch {
+	case m.acked:
+		m.mu.Unlock()
+		return ErrAcked
+	case m.nacked:
+		m.mu.Unlock()
+		return ErrNacked
+	}
+	m.nacked = true
+	m.mu.Unlock()
+	m.sub.requeue(m)
+	return nil
+}
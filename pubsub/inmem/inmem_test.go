@@ -0,0 +1,109 @@
+package inmem
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"golang.org/x/net/context"
+)
+
+func TestTopic(t *testing.T) {
+	topic := NewTopic()
+	subA, subB := topic.NewSubscriber(), topic.NewSubscriber()
+	msgsA, msgsB := subA.Start(), subB.Start()
+
+	var want []string
+	for i := 0; i < 5; i++ {
+		want = append(want, fmt.Sprintf("msg-%d", i))
+	}
+	ctx := context.Background()
+	for _, m := range want {
+		if err := topic.PublishRaw(ctx, "key", []byte(m)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	for name, msgs := range map[string]<-chan pubsub.SubscriberMessage{"A": msgsA, "B": msgsB} {
+		var got []string
+		for range want {
+			m := receive(t, msgs)
+			got = append(got, string(m.Message()))
+			if err := m.Done(); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("subscriber %s: expected messages %v in order, got %v", name, want, got)
+		}
+	}
+
+	subB.Stop()
+	if _, ok := <-msgsB; ok {
+		t.Error("expected the message channel to be closed after Stop")
+	}
+	topic.PublishRaw(ctx, "key", []byte("after stop"))
+	if got := string(receive(t, msgsA).Message()); got != "after stop" {
+		t.Errorf("expected the remaining subscriber to get %q, got %q", "after stop", got)
+	}
+	subA.Stop()
+}
+
+func TestNack(t *testing.T) {
+	topic := NewTopic()
+	sub := topic.NewSubscriber()
+	defer sub.Stop()
+	msgs := sub.Start()
+
+	ctx := context.Background()
+	topic.PublishRaw(ctx, "key", []byte("first"))
+	topic.PublishRaw(ctx, "key", []byte("second"))
+
+	first := receive(t, msgs).(*Message)
+	if err := first.Nack(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := first.Nack(); err != ErrNacked {
+		t.Errorf("expected ErrNacked for a second Nack, got %v", err)
+	}
+
+	redelivered := receive(t, msgs).(*Message)
+	if redelivered != first || redelivered.Deliveries() != 2 {
+		t.Errorf("expected the nacked message to be redelivered before newer ones, got %q after %d deliveries",
+			redelivered.Message(), redelivered.Deliveries())
+	}
+	if err := redelivered.Done(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := redelivered.Nack(); err != ErrAcked {
+		t.Errorf("expected ErrAcked for a done message, got %v", err)
+	}
+
+	second := receive(t, msgs).(*Message)
+	if got := string(second.Message()); got != "second" {
+		t.Errorf("expected %q, got %q", "second", got)
+	}
+	second.Done()
+
+	select {
+	case m := <-msgs:
+		t.Errorf("expected done messages not to be redelivered, got %q", m.Message())
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func receive(t *testing.T, msgs <-chan pubsub.SubscriberMessage) pubsub.SubscriberMessage {
+	t.Helper()
+	select {
+	case m, ok := <-msgs:
+		if !ok {
+			t.Fatal("expected a message, the channel is closed")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a message, got nothing")
+	}
+	return nil
+}
@@ -17,6 +17,9 @@ type Config struct {
 
 	Partition int32  `envconfig:"KAFKA_PARTITION"`
 	Topic     string `envconfig:"KAFKA_TOPIC"`
+	// GroupID is the consumer group used by the subscriber returned
+	// from NewGroupSubscriber.
+	GroupID string `envconfig:"KAFKA_GROUP_ID"`
 
 	MaxRetry int `envconfig:"KAFKA_MAX_RETRY"`
 
@@ -0,0 +1,232 @@
+package kafka
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/net/context"
+)
+
+// ErrSessionEnded is returned by the Done method of a group subscriber's
+// message if the consumer group session it was consumed in has ended, most
+// likely due to a partition rebalance. The message will be redelivered.
+var ErrSessionEnded = errors.New("consumer group session has ended")
+
+type (
+	// groupSubscriber is a subscriber implementation for Kafka consumer groups.
+	// Partitions of the topic are balanced across all the subscribers in the
+	// group and offsets are committed only after messages are done.
+	groupSubscriber struct {
+		group sarama.ConsumerGroup
+		topic string
+
+		cancel func()
+		done   chan struct{}
+
+		mu   sync.Mutex
+		kerr error
+	}
+
+	// groupHandler is the sarama.ConsumerGroupHandler emitting the
+	// messages of each claimed partition.
+	groupHandler struct {
+		output chan<- pubsub.SubscriberMessage
+	}
+
+	// groupMessage is a SubscriberMessage implementation that will mark
+	// its offset for commit on Done() once all earlier messages of the
+	// partition are done.
+	groupMessage struct {
+		message *sarama.ConsumerMessage
+		sess    sarama.ConsumerGroupSession
+		offsets *offsetTracker
+	}
+
+	// offsetTracker keeps the offsets of a partition's messages that
+	// are not yet done, in the order they were consumed.
+	offsetTracker struct {
+		mu      sync.Mutex
+		pending []int64
+		done    map[int64]bool
+	}
+)
+
+// NewGroupSubscriber will initiate a Kafka consumer that joins the consumer
+// group set in the Config's GroupID. Messages that are not done before the
+// subscriber stops or a rebalance moves their partition will be redelivered
+// to the group, so handlers should be idempotent.
+func NewGroupSubscriber(cfg *Config) (pubsub.Subscriber, error) {
+	if len(cfg.BrokerHosts) == 0 {
+		return nil, errors.New("at least 1 broker host is required")
+	}
+	if len(cfg.Topic) == 0 {
+		return nil, errors.New("topic name is required")
+	}
+	if len(cfg.GroupID) == 0 {
+		return nil, errors.New("group ID is required")
+	}
+
+	sconfig := cfg.Config
+	if sconfig == nil {
+		sconfig = sarama.NewConfig()
+		// consumer groups require at least Kafka 0.10.2
+		sconfig.Version = sarama.V0_10_2_0
+	}
+	// we always want to see errors, no matter what
+	sconfig.Consumer.Return.Errors = true
+	group, err := sarama.NewConsumerGroup(cfg.BrokerHosts, cfg.GroupID, sconfig)
+	if err != nil {
+		return nil, err
+	}
+	return newGroupSubscriber(group, cfg.Topic), nil
+}
+
+func newGroupSubscriber(group sarama.ConsumerGroup, topic string) *groupSubscriber {
+	return &groupSubscriber{group: group, topic: topic}
+}
+
+// Start will join the consumer group and emit any messages from the claimed
+// partitions to the returned channel. After a rebalance, it will rejoin the
+// group and resume each partition from its last committed offset.
+// If it encounters any issues, it will populate the Err() error
+// and close the returned channel.
+func (s *groupSubscriber) Start() <-chan pubsub.SubscriberMessage {
+	output := make(chan pubsub.SubscriberMessage)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		defer close(output)
+		defer cancel()
+
+		go func() {
+			select {
+			case <-ctx.Done():
+			case kerr, ok := <-s.group.Errors():
+				if ok {
+					s.setErr(kerr)
+					cancel()
+				}
+			}
+		}()
+
+		h := &groupHandler{output: output}
+		for {
+			err := s.group.Consume(ctx, []string{s.topic}, h)
+			if err != nil {
+				s.setErr(err)
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	return output
+}
+
+// Stop will block until the subscriber has left the consumer group
+// and return any errors seen on consumer close.
+func (s *groupSubscriber) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+	return s.group.Close()
+}
+
+// Err will contain any errors that occurred during
+// consumption. This method should be checked after
+// a user encounters a closed channel.
+func (s *groupSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.kerr
+}
+
+func (s *groupSubscriber) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kerr == nil {
+		s.kerr = err
+	}
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim.
+func (h *groupHandler) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited.
+func (h *groupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim will emit the messages of the claimed partition
+// until the session ends.
+func (h *groupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	offsets := &offsetTracker{done: map[int64]bool{}}
+	for {
+		select {
+		case <-sess.Context().Done():
+			return nil
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			offsets.add(msg.Offset)
+			select {
+			case h.output <- &groupMessage{message: msg, sess: sess, offsets: offsets}:
+			case <-sess.Context().Done():
+				return nil
+			}
+		}
+	}
+}
+
+// Message will return the message payload.
+func (m *groupMessage) Message() []byte {
+	return m.message.Value
+}
+
+// ExtendDoneDeadline has no effect on groupMessage.
+func (m *groupMessage) ExtendDoneDeadline(time.Duration) error {
+	return nil
+}
+
+// Done will acknowledge the message and mark the offset after the
+// latest message of the partition that is done along with all the
+// messages before it, so they are committed to the group.
+func (m *groupMessage) Done() error {
+	if m.sess.Context().Err() != nil {
+		return ErrSessionEnded
+	}
+	if offset, ok := m.offsets.markDone(m.message.Offset); ok {
+		m.sess.MarkOffset(m.message.Topic, m.message.Partition, offset+1, "")
+	}
+	return nil
+}
+
+func (t *offsetTracker) add(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, offset)
+}
+
+// markDone will mark the offset as done and return the highest offset
+// that is safe to commit, if that changed.
+func (t *offsetTracker) markDone(offset int64) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done[offset] = true
+	commit, ok := int64(0), false
+	for len(t.pending) > 0 && t.done[t.pending[0]] {
+		commit, ok = t.pending[0], true
+		delete(t.done, commit)
+		t.pending = t.pending[1:]
+	}
+	return commit, ok
+}
@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/net/context"
+)
+
+func TestGroupSubscriber(t *testing.T) {
+	g := newFakeGroup("test-topic", "a", "b", "c")
+	sub := newGroupSubscriber(g, "test-topic")
+	msgs := sub.Start()
+
+	m0, m1, m2 := receive(t, msgs, "a"), receive(t, msgs, "b"), receive(t, msgs, "c")
+	if got := g.committedOffset(); got != 0 {
+		t.Errorf("expected no offsets to be committed before Done, got %d", got)
+	}
+
+	// done out of order, the offset is only committed
+	// once all earlier messages are done
+	if err := m1.Done(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := g.committedOffset(); got != 0 {
+		t.Errorf("expected no offset to be committed while message 0 is not done, got %d", got)
+	}
+	if err := m0.Done(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := g.committedOffset(); got != 2 {
+		t.Errorf("expected offset 2 to be committed, got %d", got)
+	}
+
+	// message 2 failed and is not done before a rebalance,
+	// so the next session redelivers it
+	g.rebalance <- struct{}{}
+	redelivered := receive(t, msgs, "c")
+	if err := m2.Done(); err != ErrSessionEnded {
+		t.Errorf("expected ErrSessionEnded for a message of an ended session, got %v", err)
+	}
+	if err := redelivered.Done(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := g.committedOffset(); got != 3 {
+		t.Errorf("expected offset 3 to be committed, got %d", got)
+	}
+
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error stopping the subscriber: %s", err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Error("expected the message channel to be closed after Stop")
+	}
+	if !g.closed {
+		t.Error("expected the consumer group to be closed")
+	}
+}
+
+func TestGroupSubscriberError(t *testing.T) {
+	g := newFakeGroup("test-topic")
+	sub := newGroupSubscriber(g, "test-topic")
+	msgs := sub.Start()
+
+	kerr := errors.New("broker is gone")
+	g.errs <- kerr
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Fatal("expected no messages")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the message channel to be closed on error")
+	}
+	if err := sub.Err(); err != kerr {
+		t.Errorf("expected Err() to return %q, got %v", kerr, err)
+	}
+}
+
+func receive(t *testing.T, msgs <-chan pubsub.SubscriberMessage, want string) pubsub.SubscriberMessage {
+	t.Helper()
+	select {
+	case m := <-msgs:
+		if got := string(m.Message()); got != want {
+			t.Fatalf("expected message %q, got %q", want, got)
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected message %q, got nothing", want)
+	}
+	return nil
+}
+
+// fakeGroup is a sarama.ConsumerGroup for a single partition that starts
+// every session from the last committed offset.
+type fakeGroup struct {
+	topic string
+	log   []*sarama.ConsumerMessage
+
+	rebalance chan struct{}
+	errs      chan error
+	closed    bool
+
+	mu        sync.Mutex
+	committed int64
+}
+
+func newFakeGroup(topic string, values ...string) *fakeGroup {
+	g := &fakeGroup{topic: topic, rebalance: make(chan struct{}), errs: make(chan error, 1)}
+	for i, v := range values {
+		g.log = append(g.log, &sarama.ConsumerMessage{Topic: topic, Offset: int64(i), Value: []byte(v)})
+	}
+	return g
+}
+
+func (g *fakeGroup) Consume(ctx context.Context, topics []string, h sarama.ConsumerGroupHandler) error {
+	sessCtx, cancel := context.WithCancel(ctx)
+	sess := &fakeSession{ctx: sessCtx, group: g}
+	if err := h.Setup(sess); err != nil {
+		return err
+	}
+
+	claim := &fakeClaim{msgs: make(chan *sarama.ConsumerMessage, len(g.log))}
+	for _, msg := range g.log[g.committedOffset():] {
+		claim.msgs <- msg
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ConsumeClaim(sess, claim)
+	}()
+
+	select {
+	case <-g.rebalance:
+	case <-ctx.Done():
+	}
+	cancel()
+	<-done
+	return h.Cleanup(sess)
+}
+
+func (g *fakeGroup) Errors() <-chan error { return g.errs }
+
+func (g *fakeGroup) Close() error {
+	g.closed = true
+	return nil
+}
+
+func (g *fakeGroup) committedOffset() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.committed
+}
+
+type fakeSession struct {
+	ctx   context.Context
+	group *fakeGroup
+}
+
+func (s *fakeSession) Claims() map[string][]int32 { return map[string][]int32{s.group.topic: {0}} }
+func (s *fakeSession) MemberID() string           { return "member" }
+func (s *fakeSession) GenerationID() int32        { return 1 }
+func (s *fakeSession) Context() context.Context   { return s.ctx }
+
+func (s *fakeSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.group.mu.Lock()
+	defer s.group.mu.Unlock()
+	if offset > s.group.committed {
+		s.group.committed = offset
+	}
+}
+
+func (s *fakeSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+type fakeClaim struct {
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "test-topic" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.msgs }
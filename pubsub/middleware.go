@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/trace"
+	"golang.org/x/net/context"
+)
+
+// Handler processes a single message emitted by a Subscriber.
+type Handler interface {
+	Handle(context.Context, SubscriberMessage) error
+}
+
+// HandlerFunc is an adapter to allow the use of ordinary functions as a
+// Handler.
+type HandlerFunc func(context.Context, SubscriberMessage) error
+
+// Handle will call f(ctx, msg).
+func (f HandlerFunc) Handle(ctx context.Context, msg SubscriberMessage) error {
+	return f(ctx, msg)
+}
+
+// Middleware wraps a Handler to add behavior like tracing, metrics or
+// logging around the processing of each message.
+type Middleware func(Handler) Handler
+
+// Chain will wrap the Handler with the given Middleware. The first
+// Middleware is the outermost, so it runs first and sees the final result.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Process will start the Subscriber and pass each of its messages through
+// the Middleware to the Handler until the context is done or the Subscriber
+// stops, at which point the Subscriber's error is returned. Messages are
+// marked as done when the Handler succeeds. When it fails, a RetryMessage
+// is failed so it can be retried, a message that supports Nack is nacked
+// and any other message is left for the backend to redeliver.
+func Process(ctx context.Context, sub Subscriber, h Handler, mw ...Middleware) error {
+	h = Chain(h, mw...)
+	for msg := range StartContext(ctx, sub) {
+		err := h.Handle(ctx, msg)
+		if err == nil {
+			err = msg.Done()
+		} else {
+			Log.WithError(err).Error("unable to handle pubsub message")
+			switch m := msg.(type) {
+			case RetryMessage:
+				err = m.Fail(err)
+			case interface{ Nack() error }:
+				err = m.Nack()
+			default:
+				err = nil
+			}
+		}
+		if err != nil {
+			Log.WithError(err).Error("unable to acknowledge pubsub message")
+		}
+	}
+	return sub.Err()
+}
+
+// TracingMiddleware returns a Middleware that starts an OpenCensus span
+// with the given name around each message and marks it as failed when the
+// Handler returns an error.
+func TracingMiddleware(name string, opts ...trace.StartOption) Middleware {
+	opts = append([]trace.StartOption{trace.WithSpanKind(trace.SpanKindServer)}, opts...)
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, msg SubscriberMessage) error {
+			ctx, span := trace.StartSpan(ctx, name, opts...)
+			defer span.End()
+
+			span.AddAttributes(trace.Int64Attribute("pubsub.message_size", int64(len(msg.Message()))))
+			err := h.Handle(ctx, msg)
+			if err != nil {
+				span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+			}
+			return err
+		})
+	}
+}
+
+// MetricsMiddleware returns a Middleware that counts the handled messages
+// in the `pubsub_messages_handled_total` Prometheus metric, labeled with a
+// `result` of "success" or "failure", and observes how long the Handler took
+// in the `pubsub_message_handle_duration_seconds` metric.
+func MetricsMiddleware(namespace, subsystem string) Middleware {
+	handled := registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "pubsub_messages_handled_total",
+		Help:      "The number of pubsub messages handled, by result.",
+	}, []string{"result"})).(*prometheus.CounterVec)
+	duration := registerCollector(prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "pubsub_message_handle_duration_seconds",
+		Help:      "The time it took to handle pubsub messages.",
+	})).(prometheus.Histogram)
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, msg SubscriberMessage) error {
+			start := time.Now()
+			err := h.Handle(ctx, msg)
+			duration.Observe(time.Since(start).Seconds())
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			handled.WithLabelValues(result).Inc()
+			return err
+		})
+	}
+}
+
+// registerCollector will register the collector with the default registry,
+// returning the existing collector if an equal one was already registered.
+func registerCollector(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		Log.Warn("unable to register prometheus collector: ", err)
+	}
+	return c
+}
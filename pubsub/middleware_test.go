@@ -0,0 +1,125 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/trace"
+	"golang.org/x/net/context"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(h Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, msg SubscriberMessage) error {
+				calls = append(calls, name+" before")
+				err := h.Handle(ctx, msg)
+				calls = append(calls, name+" after")
+				return err
+			})
+		}
+	}
+	h := HandlerFunc(func(context.Context, SubscriberMessage) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	err := Chain(h, mw("first"), mw("second")).Handle(context.Background(), &testMessage{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{"first before", "second before", "handler", "second after", "first after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	good := &testMessage{body: []byte("good")}
+	bad := &nackMessage{testMessage: testMessage{body: []byte("bad")}}
+	sub := sliceSubscriber{good, bad}
+
+	var traced bool
+	h := HandlerFunc(func(ctx context.Context, msg SubscriberMessage) error {
+		traced = trace.FromContext(ctx) != nil
+		if string(msg.Message()) == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	})
+
+	before := handledMessages(t, "success")
+	beforeFailed := handledMessages(t, "failure")
+
+	err := Process(context.Background(), sub, h,
+		TracingMiddleware("test"), MetricsMiddleware("", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !traced {
+		t.Error("expected the handler context to carry a span")
+	}
+	if good.done != 1 {
+		t.Errorf("expected the good message to be done once, got %d", good.done)
+	}
+	if bad.done != 0 || bad.nacks != 1 {
+		t.Errorf("expected the bad message to be nacked only, got done=%d nacks=%d",
+			bad.done, bad.nacks)
+	}
+	if got := handledMessages(t, "success") - before; got != 1 {
+		t.Errorf("expected 1 successful message to be counted, got %v", got)
+	}
+	if got := handledMessages(t, "failure") - beforeFailed; got != 1 {
+		t.Errorf("expected 1 failed message to be counted, got %v", got)
+	}
+}
+
+func handledMessages(t *testing.T, result string) float64 {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("unable to gather metrics: %s", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "pubsub_messages_handled_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "result" && l.GetValue() == result {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// sliceSubscriber emits its messages and then closes its channel.
+type sliceSubscriber []SubscriberMessage
+
+func (s sliceSubscriber) Start() <-chan SubscriberMessage {
+	msgs := make(chan SubscriberMessage, len(s))
+	for _, m := range s {
+		msgs <- m
+	}
+	close(msgs)
+	return msgs
+}
+
+func (s sliceSubscriber) Err() error  { return nil }
+func (s sliceSubscriber) Stop() error { return nil }
+
+type nackMessage struct {
+	testMessage
+	nacks int
+}
+
+func (m *nackMessage) Nack() error {
+	m.nacks++
+	return nil
+}
@@ -0,0 +1,183 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RetryConfig holds the settings for a Subscriber wrapped with
+// NewRetrySubscriber.
+type RetryConfig struct {
+	// MaxRetries is the number of times a failed message will be
+	// redelivered before it is dead-lettered.
+	MaxRetries int
+	// RetryBackoff is the delay before the first redelivery of a failed
+	// message. It doubles with every further attempt.
+	RetryBackoff time.Duration
+	// Topic is the name of the topic or queue the Subscriber consumes. It
+	// is passed along with dead-lettered messages.
+	Topic string
+	// DeadLetter will receive messages that failed on every attempt, along
+	// with the error of their last attempt. Messages are marked as done once
+	// they are published. If it is not set, exhausted messages are logged
+	// and marked as done.
+	DeadLetter DeadLetterPublisher
+}
+
+// RetryMessage is a SubscriberMessage emitted by a Subscriber wrapped with
+// NewRetrySubscriber. Handlers call Done when a message is processed or
+// Fail when it should be retried.
+type RetryMessage interface {
+	SubscriberMessage
+	// Attempt returns the delivery attempt of the message, starting at 1.
+	Attempt() int
+	// Fail will schedule the message for redelivery after the retry
+	// backoff, or dead-letter it once it has hit the max retries. A nil
+	// err is reported as ErrMessageFailed.
+	Fail(err error) error
+}
+
+// ErrMessageFailed is the error dead-lettered messages are reported with
+// when they were failed with a nil error.
+var ErrMessageFailed = errors.New("pubsub message failed")
+
+type (
+	// retrySubscriber wraps a Subscriber to redeliver failed messages
+	// without acknowledging them to the underlying backend.
+	retrySubscriber struct {
+		Subscriber
+		cfg RetryConfig
+
+		output  chan SubscriberMessage
+		pending sync.WaitGroup
+		stop    chan struct{}
+		once    sync.Once
+	}
+
+	// retryMessage is a RetryMessage that keeps track of
+	// its delivery attempts.
+	retryMessage struct {
+		SubscriberMessage
+		sub     *retrySubscriber
+		attempt int
+		once    sync.Once
+	}
+)
+
+// retryAfter is used to wait out the backoff between attempts and
+// can be replaced in tests.
+var retryAfter = time.After
+
+// NewRetrySubscriber will wrap the given Subscriber so the messages it
+// emits are RetryMessages that can be retried up to cfg.MaxRetries times
+// before they are dead-lettered. Failed messages are redelivered by the
+// wrapper itself and only marked as done on the underlying Subscriber once
+// they succeed or are dead-lettered, so messages still waiting for a retry
+// when the Subscriber stops are redelivered by the backend.
+func NewRetrySubscriber(sub Subscriber, cfg RetryConfig) Subscriber {
+	return &retrySubscriber{Subscriber: sub, cfg: cfg, stop: make(chan struct{})}
+}
+
+// Start will start the underlying Subscriber and emit its messages,
+// along with any retried ones, to the returned channel. The channel is
+// closed once the underlying channel is closed and every message emitted
+// is done, dead-lettered or dropped by Stop.
+func (s *retrySubscriber) Start() <-chan SubscriberMessage {
+	s.output = make(chan SubscriberMessage)
+	msgs := s.Subscriber.Start()
+	go func() {
+		defer close(s.output)
+		for m := range msgs {
+			s.pending.Add(1)
+			s.deliver(&retryMessage{SubscriberMessage: m, sub: s, attempt: 1})
+		}
+		s.pending.Wait()
+	}()
+	return s.output
+}
+
+// Stop will stop any scheduled retries and the underlying Subscriber.
+func (s *retrySubscriber) Stop() error {
+	s.once.Do(func() { close(s.stop) })
+	return s.Subscriber.Stop()
+}
+
+// deliver will emit the message unless the subscriber is stopped, in
+// which case it is left for the backend to redeliver.
+func (s *retrySubscriber) deliver(m *retryMessage) {
+	select {
+	case s.output <- m:
+	case <-s.stop:
+		s.pending.Done()
+	}
+}
+
+// Done will mark the message as done on the underlying Subscriber.
+func (m *retryMessage) Done() error {
+	var err error
+	m.resolve(func() { err = m.SubscriberMessage.Done() })
+	return err
+}
+
+// resolve will run f and release the message from the subscriber's
+// pending messages, only the first time it is called.
+func (m *retryMessage) resolve(f func()) {
+	m.once.Do(func() {
+		f()
+		m.sub.pending.Done()
+	})
+}
+
+// Attempt returns the delivery attempt of the message, starting at 1.
+func (m *retryMessage) Attempt() int {
+	return m.attempt
+}
+
+// Fail will schedule the message for redelivery after the retry
+// backoff, or dead-letter it once it has hit the max retries.
+func (m *retryMessage) Fail(err error) error {
+	if err == nil {
+		err = ErrMessageFailed
+	}
+	s := m.sub
+	if m.attempt > s.cfg.MaxRetries {
+		if s.cfg.DeadLetter == nil {
+			Log.WithError(err).WithField("attempts", m.attempt).
+				Error("pubsub message failed on every attempt, dropping it")
+			return m.Done()
+		}
+		dl := &DeadLetter{
+			Topic:    s.cfg.Topic,
+			Error:    err.Error(),
+			Attempts: m.attempt,
+			Message:  m.Message(),
+		}
+		if dlerr := s.cfg.DeadLetter.PublishDeadLetter(context.Background(), dl); dlerr != nil {
+			// leave the message for the backend to redeliver
+			m.resolve(func() {})
+			return dlerr
+		}
+		return m.Done()
+	}
+
+	// the next attempt takes over this message's pending slot
+	var scheduled bool
+	m.once.Do(func() { scheduled = true })
+	if !scheduled {
+		return nil
+	}
+	next := &retryMessage{SubscriberMessage: m.SubscriberMessage, sub: s, attempt: m.attempt + 1}
+	backoff := s.cfg.RetryBackoff << uint(m.attempt-1)
+	go func() {
+		select {
+		case <-retryAfter(backoff):
+			s.deliver(next)
+		case <-s.stop:
+			s.pending.Done()
+		}
+	}()
+	return nil
+}
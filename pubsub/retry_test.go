@@ -0,0 +1,169 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRetrySubscriber(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		backoffs []time.Duration
+	)
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		backoffs = append(backoffs, d)
+		return time.After(0)
+	}
+	defer func() { retryAfter = time.After }()
+
+	tests := []struct {
+		name      string
+		succeedOn int
+
+		wantAttempts   []int
+		wantBackoffs   []time.Duration
+		wantDeadLetter bool
+	}{
+		{
+			name:      "success on retry",
+			succeedOn: 2,
+
+			wantAttempts: []int{1, 2},
+			wantBackoffs: []time.Duration{10 * time.Millisecond},
+		},
+		{
+			name: "dead-lettered after max retries",
+
+			wantAttempts:   []int{1, 2, 3},
+			wantBackoffs:   []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+			wantDeadLetter: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backoffs = nil
+			msg := &testMessage{body: []byte("hello")}
+			var deadLetters []*DeadLetter
+			sub := NewRetrySubscriber(&testSubscriber{msgs: []*testMessage{msg}}, RetryConfig{
+				MaxRetries:   2,
+				RetryBackoff: 10 * time.Millisecond,
+				Topic:        "orders",
+				DeadLetter: DeadLetterFunc(func(_ context.Context, dl *DeadLetter) error {
+					deadLetters = append(deadLetters, dl)
+					return nil
+				}),
+			})
+
+			var attempts []int
+			for m := range sub.Start() {
+				rm := m.(RetryMessage)
+				if string(rm.Message()) != "hello" {
+					t.Errorf("expected message %q, got %q", "hello", rm.Message())
+				}
+				attempts = append(attempts, rm.Attempt())
+				if rm.Attempt() == test.succeedOn {
+					rm.Done()
+					continue
+				}
+				if err := rm.Fail(errors.New("boom")); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			}
+
+			if !reflect.DeepEqual(attempts, test.wantAttempts) {
+				t.Errorf("expected attempts %v, got %v", test.wantAttempts, attempts)
+			}
+			if !reflect.DeepEqual(backoffs, test.wantBackoffs) {
+				t.Errorf("expected backoffs %v, got %v", test.wantBackoffs, backoffs)
+			}
+			wantDL := &DeadLetter{Topic: "orders", Error: "boom", Attempts: 3, Message: []byte("hello")}
+			if test.wantDeadLetter && (len(deadLetters) != 1 || !reflect.DeepEqual(deadLetters[0], wantDL)) {
+				t.Errorf("expected dead letter %+v, got %+v", wantDL, deadLetters)
+			}
+			if !test.wantDeadLetter && len(deadLetters) > 0 {
+				t.Errorf("expected no dead letters, got %v", deadLetters)
+			}
+			if msg.done != 1 {
+				t.Errorf("expected the message to be done once, got %d", msg.done)
+			}
+		})
+	}
+}
+
+func TestRetrySubscriberFailNil(t *testing.T) {
+	msg := &testMessage{body: []byte("hello")}
+	var deadLetters []*DeadLetter
+	sub := NewRetrySubscriber(&testSubscriber{msgs: []*testMessage{msg}}, RetryConfig{
+		DeadLetter: DeadLetterFunc(func(_ context.Context, dl *DeadLetter) error {
+			deadLetters = append(deadLetters, dl)
+			return nil
+		}),
+	})
+	for m := range sub.Start() {
+		if err := m.(RetryMessage).Fail(nil); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Error != ErrMessageFailed.Error() {
+		t.Errorf("expected a dead letter with the error %q, got %+v", ErrMessageFailed, deadLetters)
+	}
+}
+
+func TestRetrySubscriberDeadLetterError(t *testing.T) {
+	msg := &testMessage{body: []byte("hello")}
+	sub := NewRetrySubscriber(&testSubscriber{msgs: []*testMessage{msg}}, RetryConfig{
+		DeadLetter: DeadLetterFunc(func(context.Context, *DeadLetter) error {
+			return errors.New("dlq is down")
+		}),
+	})
+	for m := range sub.Start() {
+		if err := m.(RetryMessage).Fail(errors.New("boom")); err == nil {
+			t.Error("expected the dead-letter error to be returned")
+		}
+	}
+	if msg.done != 0 {
+		t.Error("expected the message not to be done so the backend redelivers it")
+	}
+}
+
+type testSubscriber struct {
+	msgs    []*testMessage
+	stopped bool
+}
+
+func (s *testSubscriber) Start() <-chan SubscriberMessage {
+	msgs := make(chan SubscriberMessage, len(s.msgs))
+	for _, m := range s.msgs {
+		msgs <- m
+	}
+	close(msgs)
+	return msgs
+}
+
+func (s *testSubscriber) Err() error { return nil }
+
+func (s *testSubscriber) Stop() error {
+	s.stopped = true
+	return nil
+}
+
+type testMessage struct {
+	body []byte
+	done int
+}
+
+func (m *testMessage) Message() []byte                        { return m.body }
+func (m *testMessage) ExtendDoneDeadline(time.Duration) error { return nil }
+
+func (m *testMessage) Done() error {
+	m.done++
+	return nil
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+)
+
+// BasicAuthMiddleware returns a middleware func that requires requests to
+// have HTTP basic auth credentials accepted by verify. Requests without
+// credentials or with rejected ones get a 401 with a WWW-Authenticate header
+// for the given realm.
+func BasicAuthMiddleware(realm string, verify func(user, pass string) bool) func(http.Handler) http.Handler {
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok || !verify(user, pass) {
+				w.Header().Set("WWW-Authenticate", challenge)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BasicAuthCredentials returns a verify func for BasicAuthMiddleware that
+// accepts the given user and password. The credentials are compared in
+// constant time to avoid leaking them via timing attacks.
+func BasicAuthCredentials(user, pass string) func(user, pass string) bool {
+	return func(u, p string) bool {
+		// compare both so the time taken does not reveal which one is wrong.
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user))
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(pass))
+		return userOK&passOK == 1
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	h := BasicAuthMiddleware("admin", BasicAuthCredentials("gizmo", "s3cret"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+
+	tests := []struct {
+		name       string
+		noAuth     bool
+		user, pass string
+
+		wantCode int
+	}{
+		{
+			name:     "missing header",
+			noAuth:   true,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong password",
+			user:     "gizmo",
+			pass:     "wrong",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong user",
+			user:     "nope",
+			pass:     "s3cret",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "correct credentials",
+			user:     "gizmo",
+			pass:     "s3cret",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			if !test.noAuth {
+				r.SetBasicAuth(test.user, test.pass)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			challenge := w.Header().Get("WWW-Authenticate")
+			if test.wantCode == http.StatusUnauthorized && challenge != `Basic realm="admin", charset="UTF-8"` {
+				t.Errorf("unexpected WWW-Authenticate header: %q", challenge)
+			}
+			if test.wantCode == http.StatusOK && challenge != "" {
+				t.Errorf("expected no WWW-Authenticate header, got %q", challenge)
+			}
+		})
+	}
+}
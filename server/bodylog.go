@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultBodyLogMaxBytes is the number of body bytes BodyLogMiddleware
+// captures if BodyLogOptions.MaxBytes is not set.
+const DefaultBodyLogMaxBytes = 4096
+
+// Redacted replaces the values of redacted fields in logged bodies.
+const Redacted = "[REDACTED]"
+
+// BodyLogOptions holds the settings for the BodyLogMiddleware.
+type BodyLogOptions struct {
+	// Logger is where the bodies are logged at debug level.
+	// If nil, this will default to the server package Log.
+	Logger logrus.FieldLogger
+	// Request will make the middleware log request bodies.
+	Request bool
+	// Response will make the middleware log response bodies.
+	Response bool
+	// MaxBytes is the most bytes of each body that are captured. Larger
+	// bodies are truncated. If 0, this will default to
+	// DefaultBodyLogMaxBytes.
+	MaxBytes int
+	// RedactFields are the dot separated paths, from the root of JSON
+	// bodies, of the fields whose values are replaced with Redacted, like
+	// "password" or "user.token". Arrays along the path are traversed.
+	// If set, bodies that are not valid JSON, like truncated ones, are not
+	// logged at all so their sensitive fields cannot leak.
+	RedactFields []string
+}
+
+// BodyLogMiddleware returns a middleware func that logs the request and
+// response bodies, as configured in opts, at debug level for debugging.
+// Only the first opts.MaxBytes of each body are captured, so large bodies do
+// not pile up in memory, and the request body is restored so handlers still
+// read all of it. Nothing is captured while the logger's debug level is
+// disabled.
+func BodyLogMiddleware(opts BodyLogOptions) func(http.Handler) http.Handler {
+	logger := opts.Logger
+	if logger == nil {
+		logger = Log
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultBodyLogMaxBytes
+	}
+	redact := make([][]string, len(opts.RedactFields))
+	for i, f := range opts.RedactFields {
+		redact[i] = strings.Split(f, ".")
+	}
+	return func(f http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l, ok := logger.(interface {
+				IsLevelEnabled(logrus.Level) bool
+			}); ok && !l.IsLevelEnabled(logrus.DebugLevel) {
+				f.ServeHTTP(w, r)
+				return
+			}
+
+			fields := logrus.Fields{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}
+			if opts.Request && r.Body != nil {
+				body, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(opts.MaxBytes)+1))
+				r.Body = replayBody{
+					Reader: io.MultiReader(bytes.NewReader(body), r.Body),
+					Closer: r.Body,
+				}
+				if err == nil {
+					fields["request_body"] = bodyLogValue(body, opts.MaxBytes, redact)
+				}
+			}
+
+			bw := &bodyLogResponseWriter{ResponseWriter: w, max: opts.MaxBytes}
+			if opts.Response {
+				w = bw
+			}
+			f.ServeHTTP(w, r)
+
+			if opts.Response {
+				fields["status"] = bw.status
+				fields["response_body"] = bodyLogValue(bw.buf.Bytes(), opts.MaxBytes, redact)
+			}
+			if id := RequestID(r); id != "" {
+				fields["request-id"] = id
+			}
+			logger.WithFields(fields).Debug("body")
+		})
+	}
+}
+
+// replayBody is a request body that first replays the bytes read from it.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyLogValue returns the body as it should be logged: truncated to max
+// bytes and with the redacted fields replaced.
+func bodyLogValue(body []byte, max int, redact [][]string) string {
+	truncated := len(body) > max
+	if truncated {
+		body = body[:max]
+	}
+	if len(body) == 0 || len(redact) == 0 {
+		if truncated {
+			return string(body) + "...(truncated)"
+		}
+		return string(body)
+	}
+
+	var v interface{}
+	if truncated || json.Unmarshal(body, &v) != nil {
+		return "(unparseable body omitted for redaction)"
+	}
+	for _, path := range redact {
+		redactPath(v, path)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "(unparseable body omitted for redaction)"
+	}
+	return string(b)
+}
+
+func redactPath(v interface{}, path []string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		child, ok := val[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) == 1 {
+			val[path[0]] = Redacted
+			return
+		}
+		redactPath(child, path[1:])
+	case []interface{}:
+		for _, child := range val {
+			redactPath(child, path)
+		}
+	}
+}
+
+// bodyLogResponseWriter captures the status and the first max bytes of
+// the response body.
+type bodyLogResponseWriter struct {
+	http.ResponseWriter
+	status int
+	max    int
+	buf    bytes.Buffer
+}
+
+// Unwrap returns the wrapped http.ResponseWriter.
+func (w *bodyLogResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bodyLogResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *bodyLogResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	// keep one extra byte so truncation can be detected
+	if n := w.max + 1 - w.buf.Len(); n > 0 {
+		if n > len(b) {
+			n = len(b)
+		}
+		w.buf.Write(b[:n])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// FlushError flushes the wrapped writer for http.ResponseController. It
+// returns http.ErrNotSupported if the wrapped writer can not be flushed.
+func (w *bodyLogResponseWriter) FlushError() error {
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestBodyLogMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		givenBody string
+		givenMax  int
+
+		wantRequestBody  string
+		wantResponseBody string
+	}{
+		{
+			name:             "redacted fields",
+			givenBody:        `{"user":{"name":"gizmo","password":"hunter2"},"tokens":[{"token":"abc"}]}`,
+			wantRequestBody:  `{"tokens":[{"token":"[REDACTED]"}],"user":{"name":"gizmo","password":"[REDACTED]"}}`,
+			wantResponseBody: `{"tokens":[{"token":"[REDACTED]"}],"user":{"name":"gizmo","password":"[REDACTED]"}}`,
+		},
+		{
+			name:             "truncated JSON is omitted",
+			givenBody:        `{"user":{"password":"hunter2"}}`,
+			givenMax:         10,
+			wantRequestBody:  "(unparseable body omitted for redaction)",
+			wantResponseBody: "(unparseable body omitted for redaction)",
+		},
+		{
+			name:             "non JSON is omitted",
+			givenBody:        "password=hunter2",
+			wantRequestBody:  "(unparseable body omitted for redaction)",
+			wantResponseBody: "(unparseable body omitted for redaction)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			logger.SetLevel(logrus.DebugLevel)
+
+			var gotBody string
+			h := BodyLogMiddleware(BodyLogOptions{
+				Logger:       logger,
+				Request:      true,
+				Response:     true,
+				MaxBytes:     test.givenMax,
+				RedactFields: []string{"user.password", "tokens.token"},
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				gotBody = string(b)
+				w.WriteHeader(http.StatusCreated)
+				w.Write(b)
+			}))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/svc/users", strings.NewReader(test.givenBody)))
+
+			if gotBody != test.givenBody {
+				t.Errorf("expected the handler to read the full body %q, got %q", test.givenBody, gotBody)
+			}
+			if w.Body.String() != test.givenBody {
+				t.Errorf("expected the full response body %q, got %q", test.givenBody, w.Body.String())
+			}
+
+			entry := hook.LastEntry()
+			if entry == nil {
+				t.Fatal("expected a log entry")
+			}
+			if entry.Level != logrus.DebugLevel {
+				t.Errorf("expected a debug entry, got %s", entry.Level)
+			}
+			if got := entry.Data["request_body"]; got != test.wantRequestBody {
+				t.Errorf("expected request body %q, got %q", test.wantRequestBody, got)
+			}
+			if got := entry.Data["response_body"]; got != test.wantResponseBody {
+				t.Errorf("expected response body %q, got %q", test.wantResponseBody, got)
+			}
+			if got := entry.Data["status"]; got != http.StatusCreated {
+				t.Errorf("expected status 201, got %v", got)
+			}
+		})
+	}
+}
+
+func TestBodyLogMiddlewareTruncation(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	body := strings.Repeat("a", 100)
+	var gotLen int
+	h := BodyLogMiddleware(BodyLogOptions{Logger: logger, Request: true, MaxBytes: 8})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			n, _ := io.Copy(ioutil.Discard, r.Body)
+			gotLen = int(n)
+		}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)))
+
+	if gotLen != len(body) {
+		t.Errorf("expected the handler to read %d bytes, got %d", len(body), gotLen)
+	}
+	if got, want := hook.LastEntry().Data["request_body"], "aaaaaaaa...(truncated)"; got != want {
+		t.Errorf("expected request body %q, got %q", want, got)
+	}
+	if _, ok := hook.LastEntry().Data["response_body"]; ok {
+		t.Error("expected no response body to be logged")
+	}
+}
+
+func TestBodyLogMiddlewareDebugDisabled(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.InfoLevel)
+
+	h := BodyLogMiddleware(BodyLogOptions{Logger: logger, Request: true, Response: true})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(w, r.Body)
+		}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("hello")))
+
+	if w.Body.String() != "hello" {
+		t.Errorf("expected the response body %q, got %q", "hello", w.Body.String())
+	}
+	if len(hook.AllEntries()) != 0 {
+		t.Errorf("expected nothing to be logged, got %d entries", len(hook.AllEntries()))
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy describes the Cache-Control header of a response.
+type CachePolicy struct {
+	// MaxAge is how long the response may be cached. It is sent in
+	// whole seconds.
+	MaxAge time.Duration `envconfig:"MAX_AGE"`
+	// NoStore will forbid caching the response at all. Every other
+	// setting is ignored when it is set.
+	NoStore bool `envconfig:"NO_STORE"`
+	// NoCache will make caches revalidate the response before reusing it.
+	NoCache bool `envconfig:"NO_CACHE"`
+	// Private will only allow the client, and no shared caches like
+	// proxies or CDNs, to cache the response.
+	Private bool `envconfig:"PRIVATE"`
+	// Public will allow shared caches to cache the response even if it
+	// would normally not be cacheable, like responses to authorized
+	// requests.
+	Public bool `envconfig:"PUBLIC"`
+}
+
+// String returns the Cache-Control header value for the policy, or an
+// empty string for the zero policy.
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	var directives []string
+	switch {
+	case p.Private:
+		directives = append(directives, "private")
+	case p.Public:
+		directives = append(directives, "public")
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if p.MaxAge > 0 {
+		directives = append(directives, "max-age="+strconv.FormatInt(int64(p.MaxAge/time.Second), 10))
+	}
+	return strings.Join(directives, ", ")
+}
+
+// CacheControlMiddleware returns a middleware func that sets the
+// Cache-Control header of the policy on successful and redirect responses.
+// The header is left alone if the handler, or a route registered with
+// HandleWithCache, already set it, and error responses are never given it
+// so they are not cached. SimpleServer applies it to every request if
+// Config.CacheControl is set.
+func CacheControlMiddleware(policy CachePolicy) func(http.Handler) http.Handler {
+	value := policy.String()
+	return func(h http.Handler) http.Handler {
+		if value == "" {
+			return h
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(&cacheControlResponseWriter{ResponseWriter: w, value: value}, r)
+		})
+	}
+}
+
+// cacheHandler will apply the route's own cache policy to the handler's
+// responses.
+type cacheHandler struct {
+	policy CachePolicy
+	h      http.Handler
+}
+
+func (c cacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	CacheControlMiddleware(c.policy)(c.h).ServeHTTP(w, r)
+}
+
+// cacheControlResponseWriter sets the Cache-Control header, unless it is
+// already set, right before the status is written.
+type cacheControlResponseWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+// Unwrap returns the wrapped http.ResponseWriter.
+func (w *cacheControlResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *cacheControlResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status < http.StatusBadRequest && w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", w.value)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheControlResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// FlushError flushes the wrapped writer for http.ResponseController. It
+// returns http.ErrNotSupported if the wrapped writer can not be flushed.
+func (w *cacheControlResponseWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachePolicyString(t *testing.T) {
+	tests := []struct {
+		given CachePolicy
+		want  string
+	}{
+		{CachePolicy{}, ""},
+		{CachePolicy{MaxAge: time.Hour}, "max-age=3600"},
+		{CachePolicy{MaxAge: time.Minute, Public: true}, "public, max-age=60"},
+		{CachePolicy{MaxAge: 90 * time.Second, Private: true, NoCache: true}, "private, no-cache, max-age=90"},
+		{CachePolicy{NoStore: true, MaxAge: time.Hour, Public: true}, "no-store"},
+	}
+	for _, test := range tests {
+		if got := test.given.String(); got != test.want {
+			t.Errorf("expected %+v to be %q, got %q", test.given, test.want, got)
+		}
+	}
+}
+
+func TestCacheControlMiddleware(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})
+
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.Handle("GET", "/default", ok)
+			rt.HandleWithCache("GET", "/override", ok, CachePolicy{NoStore: true})
+			rt.HandleWithCache("GET", "/handler", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "private, max-age=5")
+				w.WriteHeader(http.StatusOK)
+			}), CachePolicy{NoStore: true})
+			rt.Handle("GET", "/error", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", http.StatusInternalServerError)
+			}))
+			h := CacheControlMiddleware(CachePolicy{MaxAge: time.Minute, Public: true})(rt)
+
+			tests := []struct {
+				givenPath string
+				want      string
+			}{
+				{"/default", "public, max-age=60"},
+				{"/override", "no-store"},
+				{"/handler", "private, max-age=5"},
+				{"/error", ""},
+			}
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", test.givenPath, nil))
+				if got := w.Header().Get("Cache-Control"); got != test.want {
+					t.Errorf("expected %s to have Cache-Control %q, got %q", test.givenPath, test.want, got)
+				}
+			}
+		})
+	}
+}
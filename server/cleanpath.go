@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"path"
+)
+
+// CleanPathMiddleware returns a middleware func that normalizes request
+// paths before they are routed, so `/users//42` or `/users/./42` match the
+// `/users/{id}` route. Duplicate slashes are collapsed and `.` and `..`
+// segments are resolved while a trailing slash is kept. If redirect is set,
+// clients are redirected to the clean path like with TrailingSlashRedirect.
+// Otherwise, the path is rewritten in place. It must wrap the Router, so
+// apply it in Service.Middleware.
+func CleanPathMiddleware(redirect bool) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := cleanPath(r.URL.Path)
+			if p == r.URL.Path {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if redirect {
+				redirectPath(w, r, p)
+				return
+			}
+			h.ServeHTTP(w, withPath(r, p))
+		})
+	}
+}
+
+// cleanPath returns the canonical form of the URL path, keeping its
+// trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
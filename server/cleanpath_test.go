@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCleanPathMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		givenRedirect bool
+		givenMethod   string
+		givenPath     string
+
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:        "clean path",
+			givenMethod: "GET",
+			givenPath:   "/users/42",
+			wantCode:    http.StatusOK,
+			wantBody:    "42",
+		},
+		{
+			name:        "rewrite duplicate slashes",
+			givenMethod: "GET",
+			givenPath:   "/users//42",
+			wantCode:    http.StatusOK,
+			wantBody:    "42",
+		},
+		{
+			name:        "rewrite dot segments",
+			givenMethod: "GET",
+			givenPath:   "/users/./admin/../42",
+			wantCode:    http.StatusOK,
+			wantBody:    "42",
+		},
+		{
+			name:        "rewrite keeps trailing slash",
+			givenMethod: "GET",
+			givenPath:   "//users//",
+			wantCode:    http.StatusOK,
+			wantBody:    "list",
+		},
+		{
+			name:          "redirect",
+			givenRedirect: true,
+			givenMethod:   "GET",
+			givenPath:     "/users//42?fields=name",
+			wantCode:      http.StatusMovedPermanently,
+			wantLocation:  "/users/42?fields=name",
+		},
+		{
+			name:          "redirect non-GET",
+			givenRedirect: true,
+			givenMethod:   "POST",
+			givenPath:     "/users/../users//42",
+			wantCode:      http.StatusTemporaryRedirect,
+			wantLocation:  "/users/42",
+		},
+	}
+
+	for _, routerType := range routerTypes {
+		for _, test := range tests {
+			t.Run(routerType+"/"+test.name, func(t *testing.T) {
+				rt := NewRouter(&Config{RouterType: routerType})
+				user := func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(Vars(r)["id"]))
+				}
+				rt.HandleFunc("GET", "/users/{id}", user)
+				rt.HandleFunc("POST", "/users/{id}", user)
+				rt.HandleFunc("GET", "/users/", func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("list"))
+				})
+				h := CleanPathMiddleware(test.givenRedirect)(rt)
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(test.givenMethod, test.givenPath, nil))
+
+				if w.Code != test.wantCode {
+					t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("expected body %q, got %q", test.wantBody, w.Body.String())
+				}
+				if got := w.Header().Get("Location"); got != test.wantLocation {
+					t.Errorf("expected Location %q, got %q", test.wantLocation, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the IP address of the request's client. The
+// X-Forwarded-For chain is only honored as far as it was added by the given
+// trusted proxies: starting from the request's remote address, hops are
+// walked from the right and the first one that is not a trusted proxy is the
+// client. trustedProxies accepts CIDRs and single IP addresses, invalid
+// entries are ignored. With no trusted proxies, the remote address is
+// returned.
+func ClientIP(r *http.Request, trustedProxies []string) string {
+	nets, _ := parseCIDRs(trustedProxies, true)
+	return clientIP(r, nets)
+}
+
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := remoteIP(r)
+	if len(trusted) == 0 {
+		return ip
+	}
+	var hops []string
+	for _, xff := range r.Header["X-Forwarded-For"] {
+		hops = append(hops, strings.Split(xff, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if addr := net.ParseIP(ip); addr == nil || !containsIP(trusted, addr) {
+			return ip
+		}
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// a malformed hop can not be trusted any further
+			return ip
+		}
+		ip = hop
+	}
+	return ip
+}
+
+// remoteIP returns the host of the request's remote address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+
+		want string
+	}{
+		{
+			name:         "no trusted proxies",
+			remoteAddr:   "192.168.1.1:1234",
+			forwardedFor: []string{"1.2.3.4"},
+			want:         "192.168.1.1",
+		},
+		{
+			name:           "untrusted remote",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "192.168.1.1:1234",
+			forwardedFor:   []string{"1.2.3.4"},
+			want:           "192.168.1.1",
+		},
+		{
+			name:           "trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"1.2.3.4"},
+			want:           "1.2.3.4",
+		},
+		{
+			name:           "spoofed header",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"6.6.6.6, 1.2.3.4"},
+			want:           "1.2.3.4",
+		},
+		{
+			name:           "multiple proxies",
+			trustedProxies: []string{"10.0.0.0/8", "172.16.0.1"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"6.6.6.6, 1.2.3.4", "172.16.0.1, 10.0.0.2"},
+			want:           "1.2.3.4",
+		},
+		{
+			name:           "all trusted",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"10.0.0.3, 10.0.0.2"},
+			want:           "10.0.0.3",
+		},
+		{
+			name:           "malformed hop",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"1.2.3.4, unknown"},
+			want:           "10.0.0.1",
+		},
+		{
+			name:           "ipv6",
+			trustedProxies: []string{"2001:db8::/32"},
+			remoteAddr:     "[2001:db8::1]:1234",
+			forwardedFor:   []string{"2001:db9::5, 2001:db8::2"},
+			want:           "2001:db9::5",
+		},
+		{
+			name:       "ipv6 remote",
+			remoteAddr: "[::1]:1234",
+			want:       "::1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, xff := range test.forwardedFor {
+				r.Header.Add("X-Forwarded-For", xff)
+			}
+
+			if got := ClientIP(r, test.trustedProxies); got != test.want {
+				t.Errorf("expected client IP %q, got %q", test.want, got)
+			}
+		})
+	}
+}
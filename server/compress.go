@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressionMinSize is the minimum response size CompressionMiddleware
+// will compress if no minimum size is given.
+const DefaultCompressionMinSize = 1024
+
+// compressedContentTypes are the content type prefixes of responses
+// that are already compressed and would not gain from compression.
+var compressedContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-compress",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+}
+
+// CompressionMiddleware returns a middleware func for compressing responses
+// with gzip or deflate when the client accepts it. Responses smaller than
+// minSize bytes, or DefaultCompressionMinSize if minSize is 0, and responses
+// with an already compressed content type are left alone.
+func CompressionMiddleware(minSize int) func(http.Handler) http.Handler {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	return func(f http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				f.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+			defer cw.Close()
+			f.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// acceptedEncoding returns "gzip" or "deflate" if the Accept-Encoding
+// header accepts them, in that order of preference, or an empty string.
+func acceptedEncoding(header string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(header, ",") {
+		name, quality := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip":
+			gzipOK = true
+		case "deflate":
+			deflateOK = true
+		}
+	}
+	switch {
+	case gzipOK:
+		return "gzip"
+	case deflateOK:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressResponseWriter buffers the response until it reaches minSize
+// bytes to decide whether to compress it.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     bytes.Buffer
+	started bool
+	cw      io.WriteCloser
+}
+
+// Unwrap returns the underlying http.ResponseWriter for use with Hijack
+// and http.ResponseController.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.started {
+		w.buf.Write(b)
+		if w.buf.Len() < w.minSize {
+			return len(b), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// start will write the headers and any buffered bytes, compressing them
+// if compress is true and the response is compressible.
+func (w *compressResponseWriter) start(compress bool) error {
+	w.started = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if compress && w.compressible() {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+		}
+		if w.encoding == "gzip" {
+			w.cw = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.cw, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+func (w *compressResponseWriter) compressible() bool {
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := strings.ToLower(h.Get("Content-Type"))
+	if ct == "" {
+		ct = http.DetectContentType(w.buf.Bytes())
+	}
+	for _, prefix := range compressedContentTypes {
+		if strings.HasPrefix(ct, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// FlushError will start the response, compressing it if possible, and
+// flush what has been written so far to the client for
+// http.ResponseController.
+func (w *compressResponseWriter) FlushError() error {
+	if !w.started {
+		w.start(true)
+	}
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close will write a response that never reached the minimum size
+// uncompressed or finish the compressed stream.
+func (w *compressResponseWriter) Close() error {
+	if !w.started {
+		if w.status == 0 {
+			// nothing was written, leave the response to the server.
+			return nil
+		}
+		return w.start(false)
+	}
+	if w.cw != nil {
+		return w.cw.Close()
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := `{"data":"` + strings.Repeat("gizmo", 500) + `"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+
+		wantEncoding string
+	}{
+		{
+			name:           "large JSON gzip",
+			acceptEncoding: "gzip, deflate",
+			contentType:    "application/json",
+			body:           large,
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "large JSON deflate",
+			acceptEncoding: "deflate, gzip;q=0",
+			contentType:    "application/json",
+			body:           large,
+			wantEncoding:   "deflate",
+		},
+		{
+			name:           "small body",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           `{"data":"gizmo"}`,
+		},
+		{
+			name:           "no accept encoding",
+			acceptEncoding: "",
+			contentType:    "application/json",
+			body:           large,
+		},
+		{
+			name:           "already compressed",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			body:           large,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := CompressionMiddleware(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.WriteHeader(http.StatusCreated)
+				// write in chunks to cross the minimum size mid-response.
+				for i := 0; i < len(test.body); i += 100 {
+					end := i + 100
+					if end > len(test.body) {
+						end = len(test.body)
+					}
+					w.Write([]byte(test.body[i:end]))
+				}
+			}))
+
+			r, _ := http.NewRequest("GET", "/", nil)
+			if test.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("expected status %d, got %d", http.StatusCreated, w.Code)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("expected Vary header 'Accept-Encoding', got %q", got)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != test.wantEncoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", test.wantEncoding, got)
+			}
+
+			var body []byte
+			switch test.wantEncoding {
+			case "gzip":
+				gr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("unable to read gzip body: %s", err)
+				}
+				body, _ = ioutil.ReadAll(gr)
+			case "deflate":
+				body, _ = ioutil.ReadAll(flate.NewReader(w.Body))
+			default:
+				body = w.Body.Bytes()
+			}
+			if string(body) != test.body {
+				t.Errorf("expected body %q, got %q", test.body, string(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// ConcurrencyLimitMiddleware returns a middleware func that allows at most
+// max requests to be handled at the same time. Once the server is saturated,
+// requests wait up to wait for another request to finish before they
+// receive a 503, so load is shed instead of piling up goroutines. A wait of
+// 0 rejects excess requests immediately. If max is 0 or less, requests are
+// not limited.
+//
+// SimpleServer applies it to every request if Config.MaxConcurrentRequests
+// is set.
+func ConcurrencyLimitMiddleware(max int, wait time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if max <= 0 {
+			return h
+		}
+		sem := make(chan struct{}, max)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acquire(sem, wait) {
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			defer func() { <-sem }()
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acquire will take a slot in the semaphore, waiting up to wait for one to
+// free up. It returns false if no slot could be taken.
+func acquire(sem chan struct{}, wait time.Duration) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	}
+}
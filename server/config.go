@@ -1,10 +1,15 @@
 package server
 
 import (
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/NYTimes/gizmo/config"
 	"github.com/NYTimes/logrotate"
@@ -24,16 +29,106 @@ type Config struct {
 	CustomHealthCheckHandler http.Handler
 
 	// RouterType is used by the server to init the proper Router implementation.
-	// If empty, this will default to 'gorilla'.
+	// Valid values are 'gorilla', 'chi' and 'stdlib'. If empty, this will default to 'gorilla'.
 	RouterType string `envconfig:"GIZMO_ROUTER_TYPE"`
+	// AutoHEAD will make the Router register a HEAD handler for every GET
+	// route. The HEAD handler runs the GET handler but discards the body.
+	// Routes registered for HEAD take precedence over it and the HEAD
+	// routes it adds are listed by Routes. The 'stdlib' Router always
+	// serves HEAD requests with GET handlers, so it only lists them.
+	AutoHEAD bool `envconfig:"GIZMO_AUTO_HEAD"`
+	// TrailingSlashPolicy decides how the Router handles requests that only
+	// differ from a registered path by a trailing slash. Valid values are
+	// 'strict', 'redirect' and 'ignore'. If empty, this will default to 'strict'.
+	TrailingSlashPolicy string `envconfig:"GIZMO_TRAILING_SLASH_POLICY"`
+	// AutoOptions will make the Router respond to OPTIONS requests for any
+	// registered path with a 204 and an Allow header listing its methods.
+	AutoOptions bool `envconfig:"GIZMO_AUTO_OPTIONS"`
+	// CaseInsensitivePaths will make the Router redirect requests that do not
+	// match any route to their lowercase path if it matches one. Routes must
+	// be registered with lowercase paths for this to have any effect.
+	CaseInsensitivePaths bool `envconfig:"GIZMO_CASE_INSENSITIVE_PATHS"`
+	// CORS will make the Router respond to CORS preflight requests and add
+	// the CORS headers to the responses of allowed origins.
+	CORS CORSConfig `envconfig:"GIZMO_CORS"`
+	// SecurityHeaders will make SimpleServer apply the
+	// SecurityHeadersMiddleware to every request if Enabled is set.
+	SecurityHeaders SecurityHeadersConfig `envconfig:"GIZMO_SECURITY_HEADERS"`
+	// CacheControl will make SimpleServer apply the CacheControlMiddleware
+	// with this policy to every request if it is set.
+	CacheControl CachePolicy `envconfig:"GIZMO_CACHE_CONTROL"`
+	// MaintenanceAllowList are the paths SimpleServer keeps serving while
+	// maintenance mode is turned on with SetMaintenanceMode, in addition
+	// to the health check and metrics paths and the LivenessPath and
+	// ReadinessPath of RegisterHealthChecks.
+	MaintenanceAllowList []string `envconfig:"GIZMO_MAINTENANCE_ALLOW_LIST"`
+	// Redirects will make SimpleServer apply the RedirectMiddleware with
+	// these rules to every request, before it is routed.
+	Redirects []RedirectRule
+
+	// MaxRequestBodyBytes will limit the size of request bodies for all
+	// routes. Requests over the limit get a 413. If 0, there is no limit.
+	MaxRequestBodyBytes int64 `envconfig:"GIZMO_MAX_REQUEST_BODY_BYTES"`
+	// HandlerTimeout will limit how long the handlers of all routes may take.
+	// Requests over the limit get a 503 and their context is canceled. If 0,
+	// there is no limit.
+	HandlerTimeout time.Duration `envconfig:"GIZMO_HANDLER_TIMEOUT"`
+	// MaxConcurrentRequests will make SimpleServer apply the
+	// ConcurrencyLimitMiddleware to every request, so at most this many
+	// requests are handled at once and excess requests get a 503. If 0,
+	// there is no limit.
+	MaxConcurrentRequests int `envconfig:"GIZMO_MAX_CONCURRENT_REQUESTS"`
+	// ConcurrentRequestsWait is how long requests over MaxConcurrentRequests
+	// wait for capacity to free up before they get a 503.
+	ConcurrentRequestsWait time.Duration `envconfig:"GIZMO_CONCURRENT_REQUESTS_WAIT"`
+	// MaxURLPathLength will make SimpleServer apply the
+	// MaxURLLengthMiddleware to respond with a 414 to requests with an
+	// escaped path longer than this many bytes.
+	MaxURLPathLength int `envconfig:"GIZMO_MAX_URL_PATH_LENGTH"`
+	// MaxURLQueryLength will make SimpleServer apply the
+	// MaxURLLengthMiddleware to respond with a 414 to requests with a
+	// query string longer than this many bytes.
+	MaxURLQueryLength int `envconfig:"GIZMO_MAX_URL_QUERY_LENGTH"`
+
+	// Compression will make SimpleServer apply the CompressionMiddleware
+	// to every request.
+	Compression bool `envconfig:"GIZMO_COMPRESSION"`
+	// CompressionMinSize is the minimum response size in bytes that will be
+	// compressed. If 0, this will default to DefaultCompressionMinSize.
+	CompressionMinSize int `envconfig:"GIZMO_COMPRESSION_MIN_SIZE"`
+
+	// IPAllowList will make SimpleServer apply the IPFilterMiddleware to
+	// every request and only allow clients in the given CIDRs or IP addresses.
+	IPAllowList []string `envconfig:"GIZMO_IP_ALLOW_LIST"`
+	// IPDenyList will make SimpleServer apply the IPFilterMiddleware to every
+	// request and deny clients in the given CIDRs or IP addresses.
+	IPDenyList []string `envconfig:"GIZMO_IP_DENY_LIST"`
+	// TrustedProxies are the CIDRs or IP addresses of the proxies in front of
+	// the server. SimpleServer's IP filter and access log will honor the
+	// X-Forwarded-For hops added by them to find the client IP.
+	TrustedProxies []string `envconfig:"GIZMO_TRUSTED_PROXIES"`
 
 	// JSONContentType can be used to override the default JSONContentType.
 	JSONContentType *string `envconfig:"GIZMO_JSON_CONTENT_TYPE"`
+	// StrictAccept will make Encode and the JSON endpoints registered with
+	// HandleJSON respond with a 406 to requests that accept neither JSON nor
+	// XML instead of responding with JSON.
+	StrictAccept bool `envconfig:"GIZMO_STRICT_ACCEPT"`
+	// ContentLengthThreshold can be used to override the default
+	// DefaultContentLengthThreshold. Responses of JSONToHTTP and Encode up to
+	// this many bytes are sent with a Content-Length header while larger
+	// ones are streamed with chunked encoding. If negative, the header is
+	// never set explicitly.
+	ContentLengthThreshold *int `envconfig:"GIZMO_CONTENT_LENGTH_THRESHOLD"`
 	// MaxHeaderBytes can be used to override the default MaxHeaderBytes (1<<20).
 	MaxHeaderBytes *int `envconfig:"GIZMO_JSON_CONTENT_TYPE"`
 	// ReadTimeout can be used to override the default http server timeout of 10s.
 	// The string should be formatted like a time.Duration string.
 	ReadTimeout *string `envconfig:"GIZMO_READ_TIMEOUT"`
+	// ReadHeaderTimeout can be used to override the default http server
+	// timeout of 5s for reading request headers. The string should be
+	// formatted like a time.Duration string.
+	ReadHeaderTimeout *string `envconfig:"GIZMO_READ_HEADER_TIMEOUT"`
 	// WriteTimeout can be used to override the default http server timeout of 10s.
 	// The string should be formatted like a time.Duration string.
 	WriteTimeout *string `envconfig:"GIZMO_WRITE_TIMEOUT"`
@@ -41,6 +136,10 @@ type Config struct {
 	// The string should be formatted like a time.Duration string. This
 	// feature is supported only on Go 1.8+.
 	IdleTimeout *string `envconfig:"GIZMO_IDLE_TIMEOUT"`
+	// ShutdownTimeout can be used to override the default 10s the server will
+	// wait for in-flight requests to finish when stopping. The string should
+	// be formatted like a time.Duration string.
+	ShutdownTimeout *string `envconfig:"GIZMO_SHUTDOWN_TIMEOUT"`
 
 	// GOMAXPROCS can be used to override the default GOMAXPROCS (runtime.NumCPU).
 	GOMAXPROCS *int `envconfig:"GIZMO_SERVER_GOMAXPROCS"`
@@ -51,9 +150,21 @@ type Config struct {
 	// RPCAccessLog is the location of the RPC access log. If it is empty,
 	// no access logging will be done.
 	RPCAccessLog *string `envconfig:"RPC_ACCESS_LOG"`
+	// AccessLog will make SimpleServer log every request with the
+	// AccessLogMiddleware to the application log.
+	AccessLog bool `envconfig:"GIZMO_ACCESS_LOG"`
+	// AccessLogFields can be used to add fields to the AccessLog entries.
+	AccessLogFields func(*http.Request) map[string]interface{}
 
 	// HTTPPort is the port the server implementation will serve HTTP over.
 	HTTPPort int `envconfig:"HTTP_PORT"`
+	// Listener is an optional listener for the server to accept connections
+	// on, like one passed in by systemd socket activation. If set, HTTPPort
+	// is ignored.
+	Listener net.Listener
+	// EnableH2C will make the HTTP server accept HTTP/2 over plain text
+	// connections, like those of load balancers speaking h2c.
+	EnableH2C bool `envconfig:"GIZMO_ENABLE_H2C"`
 	// RPCPort is the port the server implementation will serve RPC over.
 	RPCPort int `envconfig:"RPC_PORT"`
 
@@ -71,13 +182,35 @@ type Config struct {
 	TLSCertFile *string `envconfig:"TLS_CERT"`
 	// TLSKeyFile is an optional string for enabling TLS in simple servers.
 	TLSKeyFile *string `envconfig:"TLS_KEY"`
+	// TLSGetCertificate is an optional func for enabling TLS in simple servers
+	// with certificates that can be reloaded without a restart. If set, the
+	// TLSCertFile and TLSKeyFile are ignored.
+	TLSGetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	// TLSRedirectPort is an optional port for simple servers with TLS enabled
+	// to listen on for plain HTTP requests and redirect them to HTTPS.
+	TLSRedirectPort int `envconfig:"TLS_REDIRECT_PORT"`
 
 	// NotFoundHandler will override the default server NotfoundHandler if set.
 	NotFoundHandler http.Handler
+	// MethodNotAllowedHandler will override the default server
+	// MethodNotAllowedHandler if set.
+	MethodNotAllowedHandler http.Handler
+	// JSONErrors will make SimpleServer respond to requests that match no
+	// route with the JSONNotFoundHandler and to requests with a method no
+	// route allows with the JSONMethodNotAllowedHandler, unless
+	// NotFoundHandler or MethodNotAllowedHandler are set.
+	JSONErrors bool `envconfig:"GIZMO_JSON_ERRORS"`
+
+	// EnableRequestID will make SimpleServer apply the RequestIDMiddleware
+	// to every request.
+	EnableRequestID bool `envconfig:"GIZMO_ENABLE_REQUEST_ID"`
 
 	// Enable pprof Profiling. Off by default.
 	EnablePProf bool `envconfig:"ENABLE_PPROF"`
 
+	// Metrics will make SimpleServer apply the PrometheusMiddleware to
+	// every request.
+	Metrics bool `envconfig:"GIZMO_METRICS"`
 	// MetricsNamespace is used by prometheus.
 	MetricsNamespace string `envconfig:"METRICS_NAMESPACE"`
 	// MetricsSubsystem is used by prometheus.
@@ -86,6 +219,200 @@ type Config struct {
 	MetricsPath string `envconfig:"METRICS_PATH"`
 }
 
+// CORSConfig holds the CORS settings for the Router. CORS handling is
+// disabled unless AllowedOrigins is set.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to make cross-origin requests.
+	// A "*" value will allow any origin.
+	AllowedOrigins []string `envconfig:"ALLOWED_ORIGINS"`
+	// AllowedMethods are the methods allowed in preflight requests.
+	// If empty, this will default to 'GET', 'HEAD' and 'POST'.
+	AllowedMethods []string `envconfig:"ALLOWED_METHODS"`
+	// AllowedHeaders are the request headers allowed in preflight requests.
+	AllowedHeaders []string `envconfig:"ALLOWED_HEADERS"`
+	// MaxAge is the number of seconds a preflight response can be cached for.
+	MaxAge int `envconfig:"MAX_AGE"`
+	// AllowCredentials will allow requests to include credentials.
+	AllowCredentials bool `envconfig:"ALLOW_CREDENTIALS"`
+}
+
+// RedirectRule is a path redirect or rewrite applied by the
+// RedirectMiddleware.
+type RedirectRule struct {
+	// From is the regular expression the whole request path must match.
+	// It may contain capture groups to use in To.
+	From string
+	// To is the path, optionally with a query string, to redirect or
+	// rewrite to. Capture groups of From can be referenced with $1 or
+	// ${name}.
+	To string
+	// Status is the redirect status code. If 0, this will default to a 301
+	// for Permanent rules and a 302 otherwise.
+	Status int
+	// Permanent will make the redirect default to a 301.
+	Permanent bool
+	// Rewrite will change the request path to To internally instead of
+	// redirecting the client.
+	Rewrite bool
+}
+
+// SecurityHeadersConfig holds the settings for the
+// SecurityHeadersMiddleware. Unset values default to conservative ones.
+type SecurityHeadersConfig struct {
+	// Enabled will make SimpleServer apply the SecurityHeadersMiddleware.
+	Enabled bool `envconfig:"ENABLED"`
+	// HSTSMaxAge is the Strict-Transport-Security max-age in seconds.
+	// If 0, this will default to DefaultHSTSMaxAge.
+	HSTSMaxAge int `envconfig:"HSTS_MAX_AGE"`
+	// HSTSIncludeSubdomains will add includeSubDomains to the
+	// Strict-Transport-Security header.
+	HSTSIncludeSubdomains bool `envconfig:"HSTS_INCLUDE_SUBDOMAINS"`
+	// HSTSPreload will add preload to the Strict-Transport-Security header.
+	HSTSPreload bool `envconfig:"HSTS_PRELOAD"`
+	// HSTSTrustForwardedProto will send the Strict-Transport-Security
+	// header for plain HTTP requests with an 'X-Forwarded-Proto: https'
+	// header, for servers behind a TLS terminating proxy.
+	HSTSTrustForwardedProto bool `envconfig:"HSTS_TRUST_FORWARDED_PROTO"`
+	// DisableHSTS will stop the Strict-Transport-Security header from
+	// being sent.
+	DisableHSTS bool `envconfig:"DISABLE_HSTS"`
+	// FrameOptions is the X-Frame-Options header value.
+	// If empty, this will default to DefaultFrameOptions.
+	FrameOptions string `envconfig:"FRAME_OPTIONS"`
+	// ReferrerPolicy is the Referrer-Policy header value.
+	// If empty, this will default to DefaultReferrerPolicy.
+	ReferrerPolicy string `envconfig:"REFERRER_POLICY"`
+	// ContentSecurityPolicy is the optional Content-Security-Policy header
+	// value. If empty, the header is not sent.
+	ContentSecurityPolicy string `envconfig:"CONTENT_SECURITY_POLICY"`
+}
+
+// ConfigErrors holds every problem found by Config.Validate.
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid server config: " + strings.Join(msgs, "; ")
+}
+
+// Validate will check the config for invalid values and combinations,
+// like out of range ports, half configured TLS or an unknown RouterType,
+// and return a ConfigErrors with all of them. It returns nil if the config
+// is valid. Call it at startup to fail fast on misconfiguration.
+func (c *Config) Validate() error {
+	var errs ConfigErrors
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, port := range []struct {
+		name string
+		port int
+	}{
+		{"HTTPPort", c.HTTPPort},
+		{"RPCPort", c.RPCPort},
+		{"TLSRedirectPort", c.TLSRedirectPort},
+	} {
+		if port.port < 0 || port.port > 65535 {
+			addErr("%s must be between 0 and 65535, got %d", port.name, port.port)
+		}
+	}
+	if c.HTTPPort != 0 && c.HTTPPort == c.RPCPort {
+		addErr("HTTPPort and RPCPort must differ, both are %d", c.HTTPPort)
+	}
+
+	certFile := c.TLSCertFile != nil && *c.TLSCertFile != ""
+	keyFile := c.TLSKeyFile != nil && *c.TLSKeyFile != ""
+	if certFile != keyFile {
+		addErr("TLSCertFile and TLSKeyFile must be set together")
+	}
+	if c.TLSGetCertificate != nil && (certFile || keyFile) {
+		addErr("TLSGetCertificate cannot be set with TLSCertFile or TLSKeyFile")
+	}
+	if c.TLSRedirectPort != 0 {
+		if !certFile && c.TLSGetCertificate == nil {
+			addErr("TLSRedirectPort requires TLS to be enabled")
+		}
+		if c.TLSRedirectPort == c.HTTPPort {
+			addErr("TLSRedirectPort and HTTPPort must differ, both are %d", c.HTTPPort)
+		}
+	}
+
+	switch c.RouterType {
+	case "", "gorilla", "chi", "stdlib":
+	default:
+		addErr("unknown RouterType %q, must be 'gorilla', 'chi' or 'stdlib'", c.RouterType)
+	}
+	switch c.TrailingSlashPolicy {
+	case "", TrailingSlashStrict, TrailingSlashRedirect, TrailingSlashIgnore:
+	default:
+		addErr("unknown TrailingSlashPolicy %q, must be 'strict', 'redirect' or 'ignore'", c.TrailingSlashPolicy)
+	}
+	switch c.HealthCheckType {
+	case "", "simple":
+	case "custom":
+		if c.CustomHealthCheckHandler == nil {
+			addErr("HealthCheckType 'custom' requires a CustomHealthCheckHandler")
+		}
+	default:
+		addErr("unknown HealthCheckType %q, must be 'simple' or 'custom'", c.HealthCheckType)
+	}
+
+	if c.MaxRequestBodyBytes < 0 {
+		addErr("MaxRequestBodyBytes must not be negative, got %d", c.MaxRequestBodyBytes)
+	}
+	if c.HandlerTimeout < 0 {
+		addErr("HandlerTimeout must not be negative, got %s", c.HandlerTimeout)
+	}
+	if c.MaxConcurrentRequests < 0 {
+		addErr("MaxConcurrentRequests must not be negative, got %d", c.MaxConcurrentRequests)
+	}
+	if c.ConcurrentRequestsWait < 0 {
+		addErr("ConcurrentRequestsWait must not be negative, got %s", c.ConcurrentRequestsWait)
+	}
+	if c.MaxURLPathLength < 0 {
+		addErr("MaxURLPathLength must not be negative, got %d", c.MaxURLPathLength)
+	}
+	if c.MaxURLQueryLength < 0 {
+		addErr("MaxURLQueryLength must not be negative, got %d", c.MaxURLQueryLength)
+	}
+	if _, err := compileRedirects(c.Redirects); err != nil {
+		addErr("Redirects: %s", err)
+	}
+	if c.SecurityHeaders.HSTSMaxAge < 0 {
+		addErr("SecurityHeaders.HSTSMaxAge must not be negative, got %d", c.SecurityHeaders.HSTSMaxAge)
+	}
+	if c.CacheControl.MaxAge < 0 {
+		addErr("CacheControl.MaxAge must not be negative, got %s", c.CacheControl.MaxAge)
+	}
+	if c.CacheControl.Private && c.CacheControl.Public {
+		addErr("CacheControl must not be both Private and Public")
+	}
+	if c.CompressionMinSize < 0 {
+		addErr("CompressionMinSize must not be negative, got %d", c.CompressionMinSize)
+	}
+	for _, list := range []struct {
+		name  string
+		cidrs []string
+	}{
+		{"IPAllowList", c.IPAllowList},
+		{"IPDenyList", c.IPDenyList},
+		{"TrustedProxies", c.TrustedProxies},
+	} {
+		if _, err := parseCIDRs(list.cidrs, false); err != nil {
+			addErr("%s: %s", list.name, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
 // LoadConfigFromEnv will attempt to load a Server object
 // from environment variables. If not populated, nil
 // is returned.
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cert, key, empty := "cert.pem", "key.pem", ""
+	getCert := func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return nil, nil }
+
+	tests := []struct {
+		name string
+		cfg  Config
+
+		wantErrs []string
+	}{
+		{
+			name: "empty config",
+			cfg:  Config{},
+		},
+		{
+			name: "valid config",
+			cfg: Config{
+				HTTPPort:                 8443,
+				RPCPort:                  8081,
+				TLSCertFile:              &cert,
+				TLSKeyFile:               &key,
+				TLSRedirectPort:          8080,
+				RouterType:               "chi",
+				TrailingSlashPolicy:      TrailingSlashRedirect,
+				HealthCheckType:          "custom",
+				CustomHealthCheckHandler: http.NotFoundHandler(),
+				IPAllowList:              []string{"10.0.0.0/8", "192.168.1.1"},
+			},
+		},
+		{
+			name: "bad ports",
+			cfg:  Config{HTTPPort: -1, RPCPort: 70000},
+
+			wantErrs: []string{
+				"HTTPPort must be between 0 and 65535, got -1",
+				"RPCPort must be between 0 and 65535, got 70000",
+			},
+		},
+		{
+			name: "same ports",
+			cfg:  Config{HTTPPort: 8080, RPCPort: 8080},
+
+			wantErrs: []string{"HTTPPort and RPCPort must differ, both are 8080"},
+		},
+		{
+			name: "cert without key",
+			cfg:  Config{TLSCertFile: &cert, TLSKeyFile: &empty},
+
+			wantErrs: []string{"TLSCertFile and TLSKeyFile must be set together"},
+		},
+		{
+			name: "cert files and GetCertificate",
+			cfg:  Config{TLSCertFile: &cert, TLSKeyFile: &key, TLSGetCertificate: getCert},
+
+			wantErrs: []string{"TLSGetCertificate cannot be set with TLSCertFile or TLSKeyFile"},
+		},
+		{
+			name: "redirect without TLS",
+			cfg:  Config{HTTPPort: 8443, TLSRedirectPort: 8080},
+
+			wantErrs: []string{"TLSRedirectPort requires TLS to be enabled"},
+		},
+		{
+			name: "unknown values",
+			cfg: Config{
+				RouterType:          "fasthttp",
+				TrailingSlashPolicy: "sometimes",
+				HealthCheckType:     "custom",
+			},
+
+			wantErrs: []string{
+				`unknown RouterType "fasthttp", must be 'gorilla', 'chi' or 'stdlib'`,
+				`unknown TrailingSlashPolicy "sometimes", must be 'strict', 'redirect' or 'ignore'`,
+				"HealthCheckType 'custom' requires a CustomHealthCheckHandler",
+			},
+		},
+		{
+			name: "negative limits and bad CIDRs",
+			cfg: Config{
+				MaxRequestBodyBytes:   -1,
+				HandlerTimeout:        -1,
+				MaxConcurrentRequests: -1,
+				MaxURLQueryLength:     -1,
+				IPDenyList:            []string{"10.0.0.0/33"},
+				TrustedProxies:        []string{"proxy"},
+			},
+
+			wantErrs: []string{
+				"MaxRequestBodyBytes must not be negative, got -1",
+				"HandlerTimeout must not be negative, got -1ns",
+				"MaxConcurrentRequests must not be negative, got -1",
+				"MaxURLQueryLength must not be negative, got -1",
+				`IPDenyList: invalid CIDR "10.0.0.0/33"`,
+				`TrustedProxies: invalid IP address "proxy"`,
+			},
+		},
+		{
+			name: "bad redirect",
+			cfg: Config{
+				Redirects: []RedirectRule{{From: "/a", To: "/b", Status: 200}},
+			},
+
+			wantErrs: []string{
+				`Redirects: invalid redirect status 200 for pattern "/a", must be a 3xx status`,
+			},
+		},
+		{
+			name: "bad cache control",
+			cfg: Config{
+				CacheControl: CachePolicy{MaxAge: -time.Second, Private: true, Public: true},
+			},
+
+			wantErrs: []string{
+				"CacheControl.MaxAge must not be negative, got -1s",
+				"CacheControl must not be both Private and Public",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.cfg.Validate()
+			if len(test.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			errs, ok := err.(ConfigErrors)
+			if !ok {
+				t.Fatalf("expected ConfigErrors, got %T: %v", err, err)
+			}
+			if len(errs) != len(test.wantErrs) {
+				t.Errorf("expected %d errors, got %d: %s", len(test.wantErrs), len(errs), err)
+			}
+			for _, want := range test.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error to contain %q, got %q", want, err)
+				}
+			}
+		})
+	}
+}
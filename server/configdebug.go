@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ConfigDebugPath is the path RegisterConfigDebug serves the config on.
+const ConfigDebugPath = "/debug/config"
+
+// RedactedSecret replaces the values of secret config fields.
+const RedactedSecret = "***"
+
+// RegisterConfigDebug will register a handler on the router at
+// ConfigDebugPath that responds with the given config, usually a pointer to
+// a struct, as JSON so operators can confirm what a running instance loaded.
+// The values of struct fields tagged with `secret:"true"`, at any depth, are
+// replaced with RedactedSecret. Values that can not be encoded as JSON, like
+// funcs, are left out. Like the pprof handlers, it should be protected with
+// middleware like BasicAuthMiddleware.
+func RegisterConfigDebug(router Router, cfg interface{}) {
+	router.Handle(http.MethodGet, ConfigDebugPath, JSONToHTTP(func(r *http.Request) (int, interface{}, error) {
+		return http.StatusOK, redactConfig(reflect.ValueOf(cfg)), nil
+	}))
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// redactConfig returns a copy of the value with its secret fields redacted
+// that can be encoded as JSON.
+func redactConfig(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil
+		}
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactConfig(v.Elem())
+	case reflect.Struct:
+		fields := map[string]interface{}{}
+		redactStruct(v, fields)
+		return fields
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = redactConfig(iter.Value())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// keep []byte base64 encoded like encoding/json does
+			return v.Interface()
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = redactConfig(v.Index(i))
+		}
+		return s
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil
+	}
+	return v.Interface()
+}
+
+// redactStruct adds the exported fields of the struct to fields, keyed by
+// their JSON names, with the fields of exported embedded structs promoted.
+func redactStruct(v reflect.Value, fields map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonFieldName(f.Tag.Get("json"))
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !fv.Type().Implements(jsonMarshalerType) {
+				redactStruct(fv, fields)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if f.Tag.Get("secret") == "true" {
+			fields[name] = RedactedSecret
+			continue
+		}
+		fields[name] = redactConfig(fv)
+	}
+}
+
+// jsonFieldName returns the name given to a field by its json tag.
+func jsonFieldName(tag string) string {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegisterConfigDebug(t *testing.T) {
+	type database struct {
+		Host     string
+		Password string `secret:"true"`
+	}
+	type Common struct {
+		Region string `json:"region"`
+		Token  string `json:"token" secret:"true"`
+	}
+	type config struct {
+		Common
+		Name      string        `json:"name"`
+		Timeout   time.Duration `json:"timeout"`
+		APIKey    string        `json:"api_key" secret:"true"`
+		Primary   *database     `json:"primary"`
+		Replicas  []database    `json:"replicas"`
+		Backup    *database     `json:"backup"`
+		Ignored   string        `json:"-"`
+		OnStart   func()        `json:"on_start"`
+		unexposed string
+	}
+	cfg := &config{
+		Common:    Common{Region: "us-east-1", Token: "shh"},
+		Name:      "gizmo",
+		Timeout:   time.Second,
+		APIKey:    "abc123",
+		Primary:   &database{Host: "db1", Password: "hunter2"},
+		Replicas:  []database{{Host: "db2", Password: "hunter3"}},
+		Ignored:   "ignored",
+		OnStart:   func() {},
+		unexposed: "unexposed",
+	}
+
+	router := NewRouter(&Config{})
+	RegisterConfigDebug(router, cfg)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", ConfigDebugPath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unable to decode response %q: %s", w.Body.String(), err)
+	}
+	want := map[string]interface{}{
+		"region":  "us-east-1",
+		"token":   RedactedSecret,
+		"name":    "gizmo",
+		"timeout": float64(time.Second),
+		"api_key": RedactedSecret,
+		"primary": map[string]interface{}{"Host": "db1", "Password": RedactedSecret},
+		"replicas": []interface{}{
+			map[string]interface{}{"Host": "db2", "Password": RedactedSecret},
+		},
+		"backup":   nil,
+		"on_start": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected config %v, got %v", want, got)
+	}
+	if !reflect.DeepEqual(cfg.Primary, &database{Host: "db1", Password: "hunter2"}) {
+		t.Errorf("expected the config not to be modified, got %+v", cfg.Primary)
+	}
+}
@@ -45,6 +45,9 @@ func ContextFields(r *http.Request) map[string]interface{} {
 	}
 	fields["path"] = r.URL.Path
 	fields["rawquery"] = r.URL.RawQuery
+	if id := RequestID(r); id != "" {
+		fields["request-id"] = id
+	}
 
 	return fields
 }
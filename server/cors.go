@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// serveCORS will add the CORS headers to the response if the request Origin is
+// allowed by the config and respond to the preflight requests of any path
+// that matches a route. It returns true if the request was handled.
+func serveCORS(cfg CORSConfig, w http.ResponseWriter, r *http.Request, matches func(*http.Request) bool) bool {
+	origin := r.Header.Get("Origin")
+	if len(cfg.AllowedOrigins) == 0 || origin == "" {
+		return false
+	}
+	w.Header().Add("Vary", "Origin")
+	if !allowedOrigin(cfg.AllowedOrigins, origin) {
+		return false
+	}
+
+	reqMethod := r.Header.Get("Access-Control-Request-Method")
+	preflight := r.Method == http.MethodOptions && reqMethod != ""
+	if preflight {
+		// only respond for the paths with a route for the requested method.
+		pr := r.WithContext(r.Context())
+		pr.Method = reqMethod
+		if !matches(pr) {
+			return false
+		}
+	}
+
+	if containsString(cfg.AllowedOrigins, "*") && !cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		return false
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(cfg.AllowedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+	}
+	if cfg.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func allowedOrigin(allowed []string, origin string) bool {
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxJSONBodyBytes is the largest request body DecodeJSONBody will read.
+var MaxJSONBodyBytes int64 = 1 << 20
+
+// JSONBodyErrorKind describes why DecodeJSONBody was unable to decode a
+// request body.
+type JSONBodyErrorKind int
+
+const (
+	// JSONBodySyntax is used for bodies that are not valid JSON.
+	JSONBodySyntax JSONBodyErrorKind = iota + 1
+	// JSONBodyTypeMismatch is used for JSON values that do not match the
+	// type of the field they are decoded into.
+	JSONBodyTypeMismatch
+	// JSONBodyUnknownField is used for JSON objects with fields the
+	// destination does not have.
+	JSONBodyUnknownField
+	// JSONBodyEmpty is used for requests without a body.
+	JSONBodyEmpty
+	// JSONBodyTooLarge is used for bodies larger than MaxJSONBodyBytes.
+	JSONBodyTooLarge
+)
+
+// JSONBodyError is the HTTPError returned by DecodeJSONBody. Its status
+// code is a 413 for bodies that are too large and a 400 otherwise.
+type JSONBodyError struct {
+	Kind JSONBodyErrorKind
+	Msg  string
+}
+
+// Error returns the client safe message of the error.
+func (e *JSONBodyError) Error() string {
+	return e.Msg
+}
+
+// StatusCode returns the status code to respond to the request with.
+func (e *JSONBodyError) StatusCode() int {
+	if e.Kind == JSONBodyTooLarge {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// DecodeJSONBody will decode the JSON request body into dst. The body is
+// limited to MaxJSONBodyBytes, must contain a single JSON value and may not
+// contain object fields dst does not have. Decoding errors are returned as
+// a *JSONBodyError, so JSON endpoints can return them directly.
+func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	if r.ContentLength > MaxJSONBodyBytes {
+		return tooLargeError()
+	}
+	if r.Body == nil {
+		return &JSONBodyError{Kind: JSONBodyEmpty, Msg: "request body must not be empty"}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxJSONBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return jsonBodyError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			return tooLargeError()
+		}
+		return &JSONBodyError{Kind: JSONBodySyntax, Msg: "request body must only contain a single JSON value"}
+	}
+	return nil
+}
+
+func jsonBodyError(err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return &JSONBodyError{Kind: JSONBodySyntax,
+			Msg: fmt.Sprintf("request body contains malformed JSON at position %d", e.Offset)}
+	case *json.UnmarshalTypeError:
+		if e.Field != "" {
+			return &JSONBodyError{Kind: JSONBodyTypeMismatch,
+				Msg: fmt.Sprintf("request body contains an invalid value for the %q field", e.Field)}
+		}
+		return &JSONBodyError{Kind: JSONBodyTypeMismatch,
+			Msg: fmt.Sprintf("request body contains an invalid value at position %d", e.Offset)}
+	case *http.MaxBytesError:
+		return tooLargeError()
+	}
+
+	switch {
+	case err == io.EOF:
+		return &JSONBodyError{Kind: JSONBodyEmpty, Msg: "request body must not be empty"}
+	case err == io.ErrUnexpectedEOF:
+		return &JSONBodyError{Kind: JSONBodySyntax, Msg: "request body contains malformed JSON"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields.
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &JSONBodyError{Kind: JSONBodyUnknownField,
+			Msg: fmt.Sprintf("request body contains unknown field %s", field)}
+	}
+	return err
+}
+
+func tooLargeError() error {
+	return &JSONBodyError{Kind: JSONBodyTooLarge,
+		Msg: fmt.Sprintf("request body must not be larger than %d bytes", MaxJSONBodyBytes)}
+}
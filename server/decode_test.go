@@ -0,0 +1,127 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONBody(t *testing.T) {
+	type body struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	defer func(max int64) { MaxJSONBodyBytes = max }(MaxJSONBodyBytes)
+	MaxJSONBodyBytes = 64
+
+	tests := []struct {
+		name         string
+		givenBody    string
+		givenChunked bool
+		givenNilBody bool
+
+		want       body
+		wantKind   JSONBodyErrorKind
+		wantStatus int
+	}{
+		{
+			name:      "success",
+			givenBody: `{"name":"gizmo","count":3}`,
+			want:      body{Name: "gizmo", Count: 3},
+		},
+		{
+			name:       "syntax error",
+			givenBody:  `{"name":"gizmo",}`,
+			wantKind:   JSONBodySyntax,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "truncated",
+			givenBody:  `{"name":"giz`,
+			wantKind:   JSONBodySyntax,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multiple values",
+			givenBody:  `{"name":"gizmo"}{"name":"gizmo"}`,
+			wantKind:   JSONBodySyntax,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "type mismatch",
+			givenBody:  `{"count":"three"}`,
+			wantKind:   JSONBodyTypeMismatch,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown field",
+			givenBody:  `{"name":"gizmo","color":"blue"}`,
+			wantKind:   JSONBodyUnknownField,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty body",
+			givenBody:  "",
+			wantKind:   JSONBodyEmpty,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "nil body",
+			givenNilBody: true,
+			wantKind:     JSONBodyEmpty,
+			wantStatus:   http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			givenBody:  `{"name":"` + strings.Repeat("a", 100) + `"}`,
+			wantKind:   JSONBodyTooLarge,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "too large without content length",
+			givenBody:    `{"name":"` + strings.Repeat("a", 100) + `"}`,
+			givenChunked: true,
+			wantKind:     JSONBodyTooLarge,
+			wantStatus:   http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(test.givenBody))
+			if test.givenChunked {
+				r.ContentLength = -1
+				r.Body = ioutil.NopCloser(strings.NewReader(test.givenBody))
+			}
+			if test.givenNilBody {
+				r.Body = nil
+			}
+
+			var got body
+			err := DecodeJSONBody(httptest.NewRecorder(), r, &got)
+			if test.wantKind == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got != test.want {
+					t.Errorf("expected %+v, got %+v", test.want, got)
+				}
+				return
+			}
+
+			je, ok := err.(*JSONBodyError)
+			if !ok {
+				t.Fatalf("expected a *JSONBodyError, got %T: %v", err, err)
+			}
+			if je.Kind != test.wantKind {
+				t.Errorf("expected error kind %d, got %d (%s)", test.wantKind, je.Kind, je)
+			}
+			if je.StatusCode() != test.wantStatus {
+				t.Errorf("expected status %d, got %d", test.wantStatus, je.StatusCode())
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// JSONErrorEnvelope is the response body of JSON endpoints registered
+// with HandleJSON that return an error.
+type JSONErrorEnvelope struct {
+	XMLName xml.Name        `json:"-" xml:"response"`
+	Error   JSONErrorDetail `json:"error" xml:"error"`
+}
+
+// JSONErrorDetail describes the error of a JSONErrorEnvelope.
+type JSONErrorDetail struct {
+	Status  int    `json:"status" xml:"status"`
+	Message string `json:"message" xml:"message"`
+}
+
+// HandleJSON will register the JSONEndpoint on the router via
+// JSONEndpointHandler.
+func HandleJSON(rt Router, method, path string, ep JSONEndpoint) {
+	rt.Handle(method, path, JSONEndpointHandler(ep))
+}
+
+// HTTPError is an error with the HTTP status code and client safe message
+// JSONEndpointHandler should respond with.
+type HTTPError interface {
+	error
+	StatusCode() int
+}
+
+// NewHTTPError returns an HTTPError with the given status code and message.
+func NewHTTPError(status int, msg string) HTTPError {
+	return &httpError{status: status, msg: msg}
+}
+
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func (e *httpError) StatusCode() int {
+	return e.status
+}
+
+// ProblemContentType is the Content-Type of RFC 7807 problem details
+// responses.
+const ProblemContentType = "application/problem+json"
+
+// ErrorEncoder writes the response for an error returned by an endpoint.
+// The error is an HTTPError, with the given status code, whose message is
+// safe to show to clients.
+type ErrorEncoder func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// errorEncoder is the ErrorEncoder JSONEndpointHandler and
+// StreamEndpointHandler respond to errors with.
+var errorEncoder ErrorEncoder = EnvelopeErrorEncoder
+
+// SetErrorEncoder will change how JSONEndpointHandler, StreamEndpointHandler,
+// RequireHeadersMiddleware, JSONNotFoundHandler and
+// JSONMethodNotAllowedHandler respond to errors so a service can
+// standardize its error responses. It is meant to be called at startup,
+// before the server is started. If enc is nil, this will reset it to the
+// default EnvelopeErrorEncoder.
+func SetErrorEncoder(enc ErrorEncoder) {
+	if enc == nil {
+		enc = EnvelopeErrorEncoder
+	}
+	errorEncoder = enc
+}
+
+// EnvelopeErrorEncoder is the default ErrorEncoder. It encodes the error in
+// a JSONErrorEnvelope with Encode, as JSON unless the client prefers XML.
+func EnvelopeErrorEncoder(w http.ResponseWriter, r *http.Request, status int, err error) {
+	err = Encode(w, r, status, JSONErrorEnvelope{Error: JSONErrorDetail{
+		Status:  status,
+		Message: err.Error(),
+	}})
+	if err != nil && err != ErrNotAcceptable {
+		LogWithFields(r).Error("unable to encode error response: ", err)
+	}
+}
+
+// ProblemErrorEncoder is an ErrorEncoder that responds with an RFC 7807
+// problem details document, like
+// `{"type":"about:blank","title":"Not Found","status":404,"detail":"no such user"}`.
+func ProblemErrorEncoder(w http.ResponseWriter, r *http.Request, status int, err error) {
+	EncodeProblem(w, r, NewProblem(status, err.Error()))
+}
+
+var (
+	// JSONNotFoundHandler responds with a 404 "not found" HTTPError via the
+	// ErrorEncoder set with SetErrorEncoder, which defaults to a
+	// JSONErrorEnvelope. SimpleServer uses it for requests that match no
+	// route if Config.JSONErrors is set.
+	JSONNotFoundHandler http.Handler = jsonErrorHandler(http.StatusNotFound, "not found")
+	// JSONMethodNotAllowedHandler responds with a 405 "method not allowed"
+	// HTTPError via the ErrorEncoder set with SetErrorEncoder. SimpleServer
+	// uses it for requests with a method no route allows if
+	// Config.JSONErrors is set.
+	JSONMethodNotAllowedHandler http.Handler = jsonErrorHandler(http.StatusMethodNotAllowed, "method not allowed")
+)
+
+// jsonErrorHandler returns a handler responding with an HTTPError with the
+// status code and message via the ErrorEncoder.
+func jsonErrorHandler(status int, msg string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		errorEncoder(w, r, status, NewHTTPError(status, msg))
+	})
+}
+
+// encodeEndpointError will respond to an error returned by an endpoint.
+// A *Problem, even when wrapped, is responded to as is and any other error
+// with the ErrorEncoder.
+func encodeEndpointError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	var p *Problem
+	if errors.As(err, &p) {
+		EncodeProblem(w, r, p)
+		return
+	}
+	he := clientError(r, code, err)
+	errorEncoder(w, r, he.StatusCode(), he)
+}
+
+// clientError returns the HTTPError an endpoint error is responded to with.
+// HTTPErrors, even when wrapped, are returned as is. Other errors are logged and replaced with
+// the given status code, or a 500 if it is not an error status code, and
+// its status text so their details do not leak to clients.
+func clientError(r *http.Request, code int, err error) HTTPError {
+	var he HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	if code < http.StatusBadRequest {
+		code = http.StatusInternalServerError
+	}
+	LogWithFields(r).WithField("status", code).Error("endpoint returned error: ", err)
+	return NewHTTPError(code, http.StatusText(code))
+}
+
+// JSONEndpointHandler will convert a JSONEndpoint into an http.Handler
+// that encodes the returned value with Encode, as JSON unless the client
+// prefers XML, with the returned status code.
+// Unlike JSONToHTTP, returned errors are responded to with the ErrorEncoder
+// set with SetErrorEncoder, which defaults to a JSONErrorEnvelope, except
+// for a *Problem, which is responded to as is via EncodeProblem.
+// HTTPErrors are responded to with their own status code and message.
+// Other errors are logged and responded to with the returned status code,
+// or a 500 if it is not an error status code, and the status text as
+// message so their details do not leak to clients.
+func JSONEndpointHandler(ep JSONEndpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			defer func() {
+				if err := r.Body.Close(); err != nil {
+					Log.Warn("unable to close request body: ", err)
+				}
+			}()
+		}
+
+		code, res, err := ep(r)
+		if err != nil {
+			encodeEndpointError(w, r, code, err)
+			return
+		}
+
+		err = Encode(w, r, code, res)
+		if err == nil || err == ErrNotAcceptable {
+			return
+		}
+		LogWithFields(r).Error("unable to encode response: ", err)
+		code = http.StatusInternalServerError
+		errorEncoder(w, r, code, NewHTTPError(code, http.StatusText(code)))
+	})
+}
+
+// StreamEndpoint is an endpoint for large or streamed responses, like file
+// downloads or CSV exports, that should not be buffered in memory. It
+// returns the status code, the Content-Type and the reader the response body
+// is copied from.
+type StreamEndpoint func(*http.Request) (status int, contentType string, body io.Reader, err error)
+
+// HandleStream will register the StreamEndpoint on the router via
+// StreamEndpointHandler.
+func HandleStream(rt Router, method, path string, ep StreamEndpoint) {
+	rt.Handle(method, path, StreamEndpointHandler(ep))
+}
+
+// StreamEndpointHandler will convert a StreamEndpoint into an http.Handler
+// that sets the returned Content-Type, writes the status code and copies the
+// body to the response, flushing after every chunk so nothing piles up in
+// buffers. The body is closed if it is an io.Closer. Returned errors are
+// responded to like JSONEndpointHandler does.
+func StreamEndpointHandler(ep StreamEndpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, contentType, body, err := ep(r)
+		if c, ok := body.(io.Closer); ok {
+			defer func() {
+				if err := c.Close(); err != nil {
+					LogWithFields(r).Warn("unable to close response body: ", err)
+				}
+			}()
+		}
+		if err != nil {
+			encodeEndpointError(w, r, code, err)
+			return
+		}
+
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if code == 0 {
+			code = http.StatusOK
+		}
+		w.WriteHeader(code)
+		if body == nil {
+			return
+		}
+		if _, err := io.Copy(flushWriter{w}, body); err != nil {
+			// the status is already written, so all we can do is log.
+			LogWithFields(r).Error("unable to stream response: ", err)
+		}
+	})
+}
+
+// flushWriter flushes the response after every write, if it can.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(b []byte) (int, error) {
+	n, err := f.w.Write(b)
+	http.NewResponseController(f.w).Flush()
+	return n, err
+}
+
+// XMLContentType is the Content-Type header Encode sets for XML responses.
+const XMLContentType = "application/xml; charset=UTF-8"
+
+// ErrNotAcceptable is returned by Encode when Config.StrictAccept is set
+// and the request does not accept JSON or XML.
+var ErrNotAcceptable = errors.New("none of the accepted media types are supported")
+
+// Encode will write the value to the response with the given status code
+// as XML if the request's Accept header prefers it over JSON, or as JSON
+// otherwise. If Config.StrictAccept is set, requests that accept neither get
+// a 406 and ErrNotAcceptable is returned. Values that can not be encoded as
+// XML, like maps, are encoded as JSON instead, so browsers, which prefer XML
+// in their default Accept header, still get a response. Nothing is written
+// if the value can not be encoded, so the caller may still respond with an
+// error.
+func Encode(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	contentType := negotiateContentType(r.Header.Get("Accept"))
+	if contentType == "" {
+		if strictAccept {
+			http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+			return ErrNotAcceptable
+		}
+		contentType = jsonContentType
+	}
+
+	var b bytes.Buffer
+	if contentType == XMLContentType {
+		b.WriteString(xml.Header)
+		if err := xml.NewEncoder(&b).Encode(v); err != nil {
+			// values like maps can not be encoded as XML, so respond with
+			// JSON unless the client refuses it
+			if jsonQ, _ := acceptQualities(r.Header.Get("Accept")); strictAccept && jsonQ == 0 {
+				return err
+			}
+			b.Reset()
+			contentType = jsonContentType
+		}
+	}
+	if contentType != XMLContentType {
+		if err := json.NewEncoder(&b).Encode(v); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	setContentLength(w, status, b.Len())
+	w.WriteHeader(status)
+	if _, err := w.Write(b.Bytes()); err != nil {
+		LogWithFields(r).Warn("unable to write response: ", err)
+	}
+	return nil
+}
+
+// DefaultContentLengthThreshold is the largest response, in bytes, that
+// JSONToHTTP and Encode set the Content-Length header of unless
+// Config.ContentLengthThreshold is set. Larger responses are streamed with
+// chunked encoding.
+const DefaultContentLengthThreshold = 1 << 20
+
+// setContentLength will set the Content-Length header of a buffered
+// response of n bytes if it is not over the contentLengthThreshold and the
+// status allows a body.
+func setContentLength(w http.ResponseWriter, status, n int) {
+	if contentLengthThreshold < 0 || n > contentLengthThreshold {
+		return
+	}
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+}
+
+// negotiateContentType returns the Content-Type of the JSON or XML
+// response preferred by the Accept header, or an empty string if neither
+// are acceptable. JSON wins ties.
+func negotiateContentType(accept string) string {
+	jsonQ, xmlQ := acceptQualities(accept)
+	switch {
+	case jsonQ > 0 && jsonQ >= xmlQ:
+		return jsonContentType
+	case xmlQ > 0:
+		return XMLContentType
+	}
+	return ""
+}
+
+// acceptQualities returns the quality values the Accept header gives JSON
+// and XML responses. Both are 1 if the header is empty. As in RFC 9110
+// section 12.5.1, a type gets the quality of the most specific range
+// matching it, so an explicit type beats "application/*", which beats "*/*".
+func acceptQualities(accept string) (jsonQ, xmlQ float64) {
+	if strings.TrimSpace(accept) == "" {
+		return 1, 1
+	}
+	// the quality and specificity of the best match for each type
+	types := [...]string{"application/json", "application/xml", "text/xml"}
+	var qs [len(types)]float64
+	var specificity [len(types)]int
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			mediaType = part[:i]
+			for _, param := range strings.Split(part[i+1:], ";") {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if pq, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						q = pq
+					}
+				}
+			}
+		}
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		for i, typ := range types {
+			var s int
+			switch mediaType {
+			case typ:
+				s = 3
+			case typ[:strings.Index(typ, "/")] + "/*":
+				s = 2
+			case "*/*":
+				s = 1
+			default:
+				continue
+			}
+			if s > specificity[i] || (s == specificity[i] && q > qs[i]) {
+				qs[i], specificity[i] = q, s
+			}
+		}
+	}
+	return qs[0], math.Max(qs[1], qs[2])
+}
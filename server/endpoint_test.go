@@ -0,0 +1,413 @@
+package server
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHandleJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		ep   JSONEndpoint
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "success",
+			ep: func(r *http.Request) (int, interface{}, error) {
+				return http.StatusCreated, map[string]string{"name": "gizmo"}, nil
+			},
+			wantCode: http.StatusCreated,
+			wantBody: `{"name":"gizmo"}` + "\n",
+		},
+		{
+			name: "http error",
+			ep: func(r *http.Request) (int, interface{}, error) {
+				return http.StatusOK, nil, NewHTTPError(http.StatusNotFound, "no such cat")
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":{"status":404,"message":"no such cat"}}` + "\n",
+		},
+		{
+			name: "wrapped http error",
+			ep: func(r *http.Request) (int, interface{}, error) {
+				return http.StatusOK, nil, fmt.Errorf("load cat: %w", NewHTTPError(http.StatusNotFound, "no such cat"))
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":{"status":404,"message":"no such cat"}}` + "\n",
+		},
+		{
+			name: "error",
+			ep: func(r *http.Request) (int, interface{}, error) {
+				return http.StatusBadRequest, nil, errors.New("missing name")
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":{"status":400,"message":"Bad Request"}}` + "\n",
+		},
+		{
+			name: "generic error",
+			ep: func(r *http.Request) (int, interface{}, error) {
+				return http.StatusOK, nil, errors.New("db password is hunter2")
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"error":{"status":500,"message":"Internal Server Error"}}` + "\n",
+		},
+		{
+			name: "unencodable response",
+			ep: func(r *http.Request) (int, interface{}, error) {
+				return http.StatusOK, func() {}, nil
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"error":{"status":500,"message":"Internal Server Error"}}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := NewRouter(&Config{})
+			HandleJSON(rt, "GET", "/json", test.ep)
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest("GET", "/json", nil))
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != JSONContentType {
+				t.Errorf("expected Content-Type %q, got %q", JSONContentType, got)
+			}
+			if got := w.Body.String(); got != test.wantBody {
+				t.Errorf("expected body %q, got %q", test.wantBody, got)
+			}
+		})
+	}
+}
+
+type encodeTestCat struct {
+	XMLName xml.Name `json:"-" xml:"cat"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func TestSetErrorEncoder(t *testing.T) {
+	defer SetErrorEncoder(nil)
+
+	ep := func(r *http.Request) (int, interface{}, error) {
+		return http.StatusOK, nil, NewHTTPError(http.StatusNotFound, "no such cat")
+	}
+	tests := []struct {
+		name    string
+		encoder ErrorEncoder
+
+		wantContentType  string
+		wantBody         string
+		wantNotFoundBody string
+	}{
+		{
+			name:             "default",
+			wantContentType:  JSONContentType,
+			wantBody:         `{"error":{"status":404,"message":"no such cat"}}` + "\n",
+			wantNotFoundBody: `{"error":{"status":404,"message":"not found"}}` + "\n",
+		},
+		{
+			name:             "problem",
+			encoder:          ProblemErrorEncoder,
+			wantContentType:  ProblemContentType,
+			wantBody:         `{"type":"about:blank","title":"Not Found","status":404,"detail":"no such cat"}` + "\n",
+			wantNotFoundBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"not found"}` + "\n",
+		},
+		{
+			name: "custom",
+			encoder: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+				Encode(w, r, status, map[string][]string{"errors": {err.Error()}})
+			},
+			wantContentType:  JSONContentType,
+			wantBody:         `{"errors":["no such cat"]}` + "\n",
+			wantNotFoundBody: `{"errors":["not found"]}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetErrorEncoder(test.encoder)
+			rt := NewRouter(&Config{})
+			HandleJSON(rt, "GET", "/json", ep)
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest("GET", "/json", nil))
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("expected Content-Type %q, got %q", test.wantContentType, got)
+			}
+			if got := w.Body.String(); got != test.wantBody {
+				t.Errorf("expected body %q, got %q", test.wantBody, got)
+			}
+
+			w = httptest.NewRecorder()
+			JSONNotFoundHandler.ServeHTTP(w, httptest.NewRequest("GET", "/nope", nil))
+			if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("expected not found Content-Type %q, got %q", test.wantContentType, got)
+			}
+			if got := w.Body.String(); got != test.wantNotFoundBody {
+				t.Errorf("expected not found body %q, got %q", test.wantNotFoundBody, got)
+			}
+		})
+	}
+}
+
+func TestEncodeBrowserAccept(t *testing.T) {
+	defer func() { strictAccept = false }()
+	for _, strict := range []bool{false, true} {
+		strictAccept = strict
+		rt := NewRouter(&Config{})
+		HandleJSON(rt, "GET", "/cat", func(r *http.Request) (int, interface{}, error) {
+			return http.StatusOK, map[string]string{"name": "gizmo"}, nil
+		})
+
+		r := httptest.NewRequest("GET", "/cat", nil)
+		r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("strict=%t: expected status %d, got %d", strict, http.StatusOK, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != JSONContentType {
+			t.Errorf("strict=%t: expected Content-Type %q, got %q", strict, JSONContentType, got)
+		}
+		if got, want := w.Body.String(), `{"name":"gizmo"}`+"\n"; got != want {
+			t.Errorf("strict=%t: expected body %q, got %q", strict, want, got)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		strict bool
+
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "no accept",
+			wantCode:        http.StatusOK,
+			wantContentType: JSONContentType,
+			wantBody:        `{"name":"gizmo"}` + "\n",
+		},
+		{
+			name:            "json",
+			accept:          "application/json",
+			wantCode:        http.StatusOK,
+			wantContentType: JSONContentType,
+			wantBody:        `{"name":"gizmo"}` + "\n",
+		},
+		{
+			name:            "xml",
+			accept:          "application/json;q=0.5, application/xml",
+			wantCode:        http.StatusOK,
+			wantContentType: XMLContentType,
+			wantBody:        xml.Header + `<cat><name>gizmo</name></cat>`,
+		},
+		{
+			name:            "wildcard",
+			accept:          "text/html, */*;q=0.1",
+			wantCode:        http.StatusOK,
+			wantContentType: JSONContentType,
+			wantBody:        `{"name":"gizmo"}` + "\n",
+		},
+		{
+			name:            "json refused",
+			accept:          "application/json;q=0, */*",
+			wantCode:        http.StatusOK,
+			wantContentType: XMLContentType,
+			wantBody:        xml.Header + `<cat><name>gizmo</name></cat>`,
+		},
+		{
+			name:     "json and xml refused strict",
+			accept:   "application/json;q=0, application/*;q=0, text/xml;q=0, */*",
+			strict:   true,
+			wantCode: http.StatusNotAcceptable,
+		},
+		{
+			name:            "unsupported",
+			accept:          "text/html",
+			wantCode:        http.StatusOK,
+			wantContentType: JSONContentType,
+			wantBody:        `{"name":"gizmo"}` + "\n",
+		},
+		{
+			name:     "unsupported strict",
+			accept:   "text/html",
+			strict:   true,
+			wantCode: http.StatusNotAcceptable,
+		},
+	}
+
+	defer func() { strictAccept = false }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strictAccept = test.strict
+			rt := NewRouter(&Config{})
+			HandleJSON(rt, "GET", "/cat", func(r *http.Request) (int, interface{}, error) {
+				return http.StatusOK, encodeTestCat{Name: "gizmo"}, nil
+			})
+
+			r := httptest.NewRequest("GET", "/cat", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if test.wantContentType == "" {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("expected Content-Type %q, got %q", test.wantContentType, got)
+			}
+			if got := w.Body.String(); got != test.wantBody {
+				t.Errorf("expected body %q, got %q", test.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	defer func(n int) { contentLengthThreshold = n }(contentLengthThreshold)
+	contentLengthThreshold = 32
+
+	small := map[string]string{"name": "gizmo"}
+	large := map[string]string{"name": strings.Repeat("gizmo", 10)}
+	jsonEndpoint := func(code int, res interface{}) JSONEndpoint {
+		return func(r *http.Request) (int, interface{}, error) {
+			return code, res, nil
+		}
+	}
+	tests := []struct {
+		name    string
+		handler http.Handler
+
+		wantContentLength bool
+	}{
+		{"small", JSONEndpointHandler(jsonEndpoint(http.StatusOK, small)), true},
+		{"large", JSONEndpointHandler(jsonEndpoint(http.StatusOK, large)), false},
+		{"no content", JSONEndpointHandler(jsonEndpoint(http.StatusNoContent, nil)), false},
+		{"small JSONToHTTP", JSONToHTTP(jsonEndpoint(http.StatusOK, small)), true},
+		{"large JSONToHTTP", JSONToHTTP(jsonEndpoint(http.StatusOK, large)), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			test.handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			got := w.Header().Get("Content-Length")
+			if !test.wantContentLength {
+				if got != "" {
+					t.Errorf("expected no Content-Length, got %q", got)
+				}
+				return
+			}
+			if want := strconv.Itoa(w.Body.Len()); got != want {
+				t.Errorf("expected Content-Length %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestHandleStream(t *testing.T) {
+	const size = 10 << 20
+	body := &countingReader{r: io.LimitReader(zeroReader{}, size)}
+	rt := NewRouter(&Config{})
+	HandleStream(rt, "GET", "/export", func(r *http.Request) (int, string, io.Reader, error) {
+		return http.StatusOK, "text/csv", body, nil
+	})
+	HandleStream(rt, "GET", "/missing", func(r *http.Request) (int, string, io.Reader, error) {
+		return 0, "", nil, NewHTTPError(http.StatusNotFound, "no export for you")
+	})
+
+	w := &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/export", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("expected Content-Type text/csv, got %q", got)
+	}
+	if w.written != size {
+		t.Errorf("expected %d bytes to be written, got %d", size, w.written)
+	}
+	if w.largestWrite >= size/4 || w.flushes < 4 {
+		t.Errorf("expected the body to be written and flushed in chunks, got a %d byte write and %d flushes",
+			w.largestWrite, w.flushes)
+	}
+	if !body.closed {
+		t.Error("expected the body to be closed")
+	}
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+	wantBody := `{"error":{"status":404,"message":"no export for you"}}` + "\n"
+	if got := rec.Body.String(); got != wantBody {
+		t.Errorf("expected body %q, got %q", wantBody, got)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = '0'
+	}
+	return len(b), nil
+}
+
+type countingReader struct {
+	r      io.Reader
+	closed bool
+}
+
+func (c *countingReader) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+func (c *countingReader) Close() error {
+	c.closed = true
+	return nil
+}
+
+// chunkRecorder records the size of writes instead of buffering them.
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	written      int
+	largestWrite int
+	flushes      int
+}
+
+func (c *chunkRecorder) Write(b []byte) (int, error) {
+	c.written += len(b)
+	if len(b) > c.largestWrite {
+		c.largestWrite = len(b)
+	}
+	return len(b), nil
+}
+
+func (c *chunkRecorder) Flush() { c.flushes++ }
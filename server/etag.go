@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ETagMiddleware returns a middleware func that buffers successful GET and
+// HEAD responses, sets a strong ETag computed from the SHA-256 hash of the
+// body and responds with a 304 Not Modified when it matches the request's
+// If-None-Match header. Responses that set their own ETag are only checked
+// against If-None-Match. Streaming responses, detected by a call to Flush or a
+// text/event-stream content type, are passed through untouched.
+func ETagMiddleware(f http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			f.ServeHTTP(w, r)
+			return
+		}
+		ew := &etagResponseWriter{ResponseWriter: w, r: r}
+		f.ServeHTTP(ew, r)
+		ew.Close()
+	})
+}
+
+// etagResponseWriter buffers the response so the ETag can be computed
+// before any of it is written.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	r *http.Request
+
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+// Unwrap returns the underlying http.ResponseWriter for use with Hijack
+// and http.ResponseController.
+func (w *etagResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *etagResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status != http.StatusOK ||
+		strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.stream()
+	}
+}
+
+func (w *etagResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// FlushError flushes the wrapped writer for http.ResponseController.
+// Flushing gives up on the ETag and streams the response from then on.
+func (w *etagResponseWriter) FlushError() error {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.streaming {
+		w.stream()
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// stream will write the headers and any buffered bytes and pass
+// all further writes through.
+func (w *etagResponseWriter) stream() {
+	w.streaming = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// Close will tag and write the buffered response or respond with a 304
+// if the client already has it.
+func (w *etagResponseWriter) Close() {
+	if w.streaming {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	etag := h.Get("ETag")
+	if etag == "" {
+		sum := sha256.Sum256(w.buf.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:]) + `"`
+		h.Set("ETag", etag)
+	}
+	if etagMatch(w.r.Header.Get("If-None-Match"), etag) {
+		for _, k := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+			h.Del(k)
+		}
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// etagMatch reports whether the If-None-Match header matches the given
+// ETag, using the weak comparison required for If-None-Match.
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
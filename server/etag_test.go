@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETagMiddleware(t *testing.T) {
+	h := ETagMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			fmt.Fprint(w, "part 1")
+			http.NewResponseController(w).Flush()
+			fmt.Fprint(w, "part 2")
+		case "/missing":
+			http.Error(w, "nope", http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "hello, gizmo")
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Body.String(); got != "hello, gizmo" {
+		t.Errorf("expected body %q, got %q", "hello, gizmo", got)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag header")
+	}
+
+	for _, inm := range []string{etag, `"other", W/` + etag, "*"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("If-None-Match", inm)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %q: expected status %d, got %d", inm, http.StatusNotModified, w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %q: expected no body, got %q", inm, w.Body.String())
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("If-None-Match %q: expected ETag %q, got %q", inm, etag, got)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("stale ETag: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	skipped := []*http.Request{
+		httptest.NewRequest("POST", "/", nil),
+		httptest.NewRequest("GET", "/stream", nil),
+		httptest.NewRequest("GET", "/missing", nil),
+	}
+	for _, r := range skipped {
+		r.Header.Set("If-None-Match", "*")
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("ETag"); got != "" {
+			t.Errorf("%s %s: expected no ETag, got %q", r.Method, r.URL.Path, got)
+		}
+		if w.Code == http.StatusNotModified {
+			t.Errorf("%s %s: expected no 304", r.Method, r.URL.Path)
+		}
+	}
+	r = httptest.NewRequest("GET", "/stream", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Body.String(); got != "part 1part 2" {
+		t.Errorf("expected streamed body %q, got %q", "part 1part 2", got)
+	}
+}
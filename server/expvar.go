@@ -0,0 +1,64 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ExpvarPath is the path RegisterExpvar serves the expvar JSON on.
+const ExpvarPath = "/debug/vars"
+
+var publishExpvar sync.Once
+
+// RegisterExpvar will register the expvar JSON handler on the router at
+// ExpvarPath and publish an "http" variable with the total number of
+// requests, the number of requests in flight and the totals per status
+// class. The values are read from the metrics PrometheusMiddleware
+// registered with the prometheus.DefaultRegisterer, so they are only
+// counted for servers using it, like SimpleServer with Config.Metrics set.
+func RegisterExpvar(router Router) {
+	publishExpvar.Do(func() {
+		expvar.Publish("http", expvar.Func(func() interface{} {
+			return gatherHTTPVars(prometheus.DefaultGatherer)
+		}))
+	})
+	router.Handle(http.MethodGet, ExpvarPath, expvar.Handler())
+}
+
+// httpVars is the value of the "http" expvar.
+type httpVars struct {
+	Requests int64            `json:"requests"`
+	InFlight int64            `json:"in_flight"`
+	Statuses map[string]int64 `json:"statuses"`
+}
+
+func gatherHTTPVars(g prometheus.Gatherer) httpVars {
+	vars := httpVars{Statuses: map[string]int64{}}
+	mfs, err := g.Gather()
+	if err != nil {
+		Log.Warn("unable to gather metrics for expvar: ", err)
+	}
+	for _, mf := range mfs {
+		switch {
+		case strings.HasSuffix(mf.GetName(), "http_requests_total"):
+			for _, m := range mf.GetMetric() {
+				n := int64(m.GetCounter().GetValue())
+				vars.Requests += n
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "status" {
+						vars.Statuses[l.GetValue()] += n
+					}
+				}
+			}
+		case strings.HasSuffix(mf.GetName(), "http_requests_in_flight"):
+			for _, m := range mf.GetMetric() {
+				vars.InFlight += int64(m.GetGauge().GetValue())
+			}
+		}
+	}
+	return vars
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterExpvar(t *testing.T) {
+	rt := NewRouter(&Config{})
+	RegisterExpvar(rt)
+	started, release := make(chan struct{}), make(chan struct{})
+	rt.HandleFunc("GET", "/ok", func(w http.ResponseWriter, r *http.Request) {})
+	rt.HandleFunc("GET", "/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	h := PrometheusMiddleware(nil, "expvar_test", "")(rt)
+
+	getVars := func() httpVars {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", ExpvarPath, nil))
+		var got struct {
+			HTTP httpVars `json:"http"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("unable to decode expvar response: %s", err)
+		}
+		return got.HTTP
+	}
+
+	before := getVars()
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-started
+
+	got := getVars()
+	close(release)
+	<-done
+
+	// the first expvar request is counted too
+	if n := got.Requests - before.Requests; n != 5 {
+		t.Errorf("expected 5 more requests, got %d", n)
+	}
+	if n := got.Statuses["2xx"] - before.Statuses["2xx"]; n != 4 {
+		t.Errorf("expected 4 more 2xx requests, got %d", n)
+	}
+	if n := got.Statuses["4xx"] - before.Statuses["4xx"]; n != 1 {
+		t.Errorf("expected 1 more 4xx request, got %d", n)
+	}
+	// the slow request and the expvar request itself
+	if got.InFlight != 2 {
+		t.Errorf("expected 2 requests in flight, got %d", got.InFlight)
+	}
+}
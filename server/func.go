@@ -2,8 +2,14 @@ package server
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
+
+	uuid "github.com/nu7hatch/gouuid"
 )
 
 // JSONContentType can be used for setting the Content-Type header for JSON encoding.
@@ -44,6 +50,46 @@ func GetUInt64Var(r *http.Request, key string) uint64 {
 	return i
 }
 
+// PathInt64 will return the named route variable of the request as an
+// int64. Missing or non-numeric variables return an HTTPError with a 400
+// status.
+func PathInt64(r *http.Request, name string) (int64, error) {
+	v, err := pathVar(r, name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("path parameter %q must be an integer, got %q", name, v))
+	}
+	return i, nil
+}
+
+// PathUUID will return the named route variable of the request as a UUID.
+// Missing or malformed variables return an HTTPError with a 400 status.
+func PathUUID(r *http.Request, name string) (uuid.UUID, error) {
+	v, err := pathVar(r, name)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	u, err := uuid.ParseHex(v)
+	if err != nil {
+		return uuid.UUID{}, NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("path parameter %q must be a UUID, got %q", name, v))
+	}
+	return *u, nil
+}
+
+func pathVar(r *http.Request, name string) (string, error) {
+	v := Vars(r)[name]
+	if v == "" {
+		return "", NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("missing path parameter %q", name))
+	}
+	return v, nil
+}
+
 // ParseTruthyFalsy is a helper method to attempt to parse booleans in
 // APIs that have no set contract on what a boolean should look like.
 func ParseTruthyFalsy(flag interface{}) (result bool, err error) {
@@ -53,3 +99,220 @@ func ParseTruthyFalsy(flag interface{}) (result bool, err error) {
 	}
 	return strconv.ParseBool(s)
 }
+
+// ParseInt64 will parse the named query parameter of the request as an
+// int64, returning def if the parameter is missing. Invalid values return
+// an HTTPError with a 400 status.
+func ParseInt64(r *http.Request, name string, def int64) (int64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return def, NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("query parameter %q must be an integer, got %q", name, v))
+	}
+	return i, nil
+}
+
+// ParseBool will parse the named query parameter of the request as a bool
+// with ParseTruthyFalsy, returning def if the parameter is missing. Invalid
+// values return an HTTPError with a 400 status.
+func ParseBool(r *http.Request, name string, def bool) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := ParseTruthyFalsy(v)
+	if err != nil {
+		return def, NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("query parameter %q must be a boolean, got %q", name, v))
+	}
+	return b, nil
+}
+
+// ParseTime will parse the named query parameter of the request as a
+// time.Time in the given layout, returning def if the parameter is missing.
+// Invalid values return an HTTPError with a 400 status.
+func ParseTime(r *http.Request, name, layout string, def time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	t, err := time.Parse(layout, v)
+	if err != nil {
+		return def, NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("query parameter %q must be a time formatted as %q, got %q", name, layout, v))
+	}
+	return t, nil
+}
+
+var (
+	// DefaultPageLimit is the limit ParsePagination returns when the
+	// request does not have a `limit` query parameter.
+	DefaultPageLimit = 20
+	// MaxPageLimit is the largest limit ParsePagination will return. Larger
+	// limits are capped to it.
+	MaxPageLimit = 100
+)
+
+// ParsePagination will parse the `limit` and `offset` query parameters of
+// the request. A missing limit defaults to DefaultPageLimit and limits above
+// MaxPageLimit are capped to it. Instead of an offset, a 1-based `page` can
+// be given, which is converted to an offset using the limit.
+//
+// Errors for malformed or out of range parameters are HTTPErrors with a 400
+// status, so JSON endpoints can return them directly.
+func ParsePagination(r *http.Request) (limit, offset int, err error) {
+	query := r.URL.Query()
+	limit = DefaultPageLimit
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return 0, 0, NewHTTPError(http.StatusBadRequest, "limit must be a positive integer")
+		}
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	o, p := query.Get("offset"), query.Get("page")
+	switch {
+	case o != "" && p != "":
+		return 0, 0, NewHTTPError(http.StatusBadRequest, "offset and page cannot be combined")
+	case o != "":
+		offset, err = strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			return 0, 0, NewHTTPError(http.StatusBadRequest, "offset must be a non-negative integer")
+		}
+	case p != "":
+		page, err := strconv.Atoi(p)
+		if err != nil || page < 1 {
+			return 0, 0, NewHTTPError(http.StatusBadRequest, "page must be a positive integer")
+		}
+		if page > math.MaxInt/limit {
+			return 0, 0, NewHTTPError(http.StatusBadRequest, "page is too large")
+		}
+		offset = (page - 1) * limit
+	}
+	return limit, offset, nil
+}
+
+// DecodeQuery will populate the fields of the struct pointed to by dst from
+// the request's query string. Fields are decoded from the query parameter
+// named by their `query` tag and fields without one, or unexported fields,
+// are skipped. A `default` tag sets the value of a missing parameter and a
+// `required:"true"` tag makes a missing parameter an error. Supported field
+// types are strings, bools, ints, uints, floats, time.Duration, time.Time (as
+// RFC 3339 or the layout in a `layout` tag) and slices of them, decoded from
+// repeated or comma separated parameters.
+//
+// Errors for missing or invalid parameters are HTTPErrors with a 400 status,
+// so JSON endpoints can return them directly.
+func DecodeQuery(r *http.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("DecodeQuery expects a pointer to a struct, got %T", dst)
+	}
+	v = v.Elem()
+	query := r.URL.Query()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("query")
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		vals, ok := query[name]
+		if !ok || len(vals) == 0 {
+			if def, hasDefault := field.Tag.Lookup("default"); hasDefault {
+				vals = []string{def}
+			} else if field.Tag.Get("required") == "true" {
+				return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("missing required query parameter %q", name))
+			} else {
+				continue
+			}
+		}
+
+		if err := setQueryValue(v.Field(i), field.Tag.Get("layout"), vals); err != nil {
+			return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid query parameter %q: %s", name, err))
+		}
+	}
+	return nil
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+func setQueryValue(v reflect.Value, layout string, vals []string) error {
+	if v.Kind() == reflect.Slice {
+		var parts []string
+		for _, val := range vals {
+			parts = append(parts, strings.Split(val, ",")...)
+		}
+		s := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setQueryValue(s.Index(i), layout, []string{part}); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+
+	val := strings.TrimSpace(vals[0])
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.Type() == timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, val)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(val)
+	case reflect.Bool:
+		b, err := ParseTruthyFalsy(val)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -193,3 +197,225 @@ func TestParseTruthyFalsy(t *testing.T) {
 	}
 
 }
+
+func TestDecodeQuery(t *testing.T) {
+	type params struct {
+		Limit   int           `query:"limit" default:"10"`
+		Active  bool          `query:"active"`
+		Since   time.Time     `query:"since"`
+		Day     time.Time     `query:"day" layout:"2006-01-02"`
+		Timeout time.Duration `query:"timeout"`
+		IDs     []uint64      `query:"id"`
+		Name    string        `query:"name" required:"true"`
+		Ignored string
+	}
+
+	tests := []struct {
+		givenQuery string
+
+		want     params
+		wantCode int
+	}{
+		{
+			givenQuery: "name=gizmo&limit=5&active=1&since=2019-01-02T03:04:05Z&day=2019-01-02&timeout=5s&id=1,2&id=3&Ignored=x",
+			want: params{
+				Limit:   5,
+				Active:  true,
+				Since:   time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+				Day:     time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC),
+				Timeout: 5 * time.Second,
+				IDs:     []uint64{1, 2, 3},
+				Name:    "gizmo",
+			},
+		},
+		{
+			givenQuery: "name=gizmo",
+			want:       params{Limit: 10, Name: "gizmo"},
+		},
+		{
+			givenQuery: "limit=5",
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			givenQuery: "name=gizmo&limit=five",
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			givenQuery: "name=gizmo&active=maybe",
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			givenQuery: "name=gizmo&since=yesterday",
+			wantCode:   http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		var got params
+		err := DecodeQuery(httptest.NewRequest("GET", "/?"+test.givenQuery, nil), &got)
+		if test.wantCode != 0 {
+			he, ok := err.(HTTPError)
+			if !ok || he.StatusCode() != test.wantCode {
+				t.Errorf("%s: expected an HTTPError with status %d, got %v", test.givenQuery, test.wantCode, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenQuery, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %+v, got %+v", test.givenQuery, test.want, got)
+		}
+	}
+
+	if err := DecodeQuery(httptest.NewRequest("GET", "/", nil), params{}); err == nil {
+		t.Error("expected an error for a non-pointer destination")
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		givenQuery string
+
+		wantLimit  int
+		wantOffset int
+		wantErr    bool
+	}{
+		{givenQuery: "", wantLimit: DefaultPageLimit},
+		{givenQuery: "limit=10&offset=30", wantLimit: 10, wantOffset: 30},
+		{givenQuery: "limit=10&page=3", wantLimit: 10, wantOffset: 20},
+		{givenQuery: "page=2", wantLimit: DefaultPageLimit, wantOffset: DefaultPageLimit},
+		{givenQuery: "limit=1000", wantLimit: MaxPageLimit},
+		{givenQuery: "limit=ten", wantErr: true},
+		{givenQuery: "limit=0", wantErr: true},
+		{givenQuery: "offset=-1", wantErr: true},
+		{givenQuery: "offset=abc", wantErr: true},
+		{givenQuery: "page=0", wantErr: true},
+		{givenQuery: "limit=100&page=" + strconv.Itoa(math.MaxInt/10), wantErr: true},
+		{givenQuery: "offset=10&page=2", wantErr: true},
+	}
+
+	for _, test := range tests {
+		limit, offset, err := ParsePagination(httptest.NewRequest("GET", "/?"+test.givenQuery, nil))
+		if test.wantErr {
+			he, ok := err.(HTTPError)
+			if !ok || he.StatusCode() != http.StatusBadRequest {
+				t.Errorf("%s: expected an HTTPError with status 400, got %v", test.givenQuery, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenQuery, err)
+			continue
+		}
+		if limit != test.wantLimit || offset != test.wantOffset {
+			t.Errorf("%s: expected limit %d and offset %d, got %d and %d",
+				test.givenQuery, test.wantLimit, test.wantOffset, limit, offset)
+		}
+	}
+}
+
+func TestParseInt64(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?id=42&bad=4x2", nil)
+
+	if got, err := ParseInt64(r, "id", 7); err != nil || got != 42 {
+		t.Errorf("expected 42 and no error, got %d and %v", got, err)
+	}
+	if got, err := ParseInt64(r, "missing", 7); err != nil || got != 7 {
+		t.Errorf("expected default 7 and no error, got %d and %v", got, err)
+	}
+	if _, err := ParseInt64(r, "bad", 7); !isBadRequest(err) {
+		t.Errorf("expected an HTTPError with status 400, got %v", err)
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?on=true&off=0&bad=maybe", nil)
+
+	if got, err := ParseBool(r, "on", false); err != nil || !got {
+		t.Errorf("expected true and no error, got %t and %v", got, err)
+	}
+	if got, err := ParseBool(r, "off", true); err != nil || got {
+		t.Errorf("expected false and no error, got %t and %v", got, err)
+	}
+	if got, err := ParseBool(r, "missing", true); err != nil || !got {
+		t.Errorf("expected default true and no error, got %t and %v", got, err)
+	}
+	if _, err := ParseBool(r, "bad", false); !isBadRequest(err) {
+		t.Errorf("expected an HTTPError with status 400, got %v", err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?since=2018-10-29&bad=yesterday", nil)
+	def := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got, err := ParseTime(r, "since", "2006-01-02", def)
+	if want := time.Date(2018, 10, 29, 0, 0, 0, 0, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("expected %s and no error, got %s and %v", want, got, err)
+	}
+	if got, err := ParseTime(r, "missing", "2006-01-02", def); err != nil || !got.Equal(def) {
+		t.Errorf("expected default %s and no error, got %s and %v", def, got, err)
+	}
+	if _, err := ParseTime(r, "bad", "2006-01-02", def); !isBadRequest(err) {
+		t.Errorf("expected an HTTPError with status 400, got %v", err)
+	}
+}
+
+func isBadRequest(err error) bool {
+	he, ok := err.(HTTPError)
+	return ok && he.StatusCode() == http.StatusBadRequest
+}
+
+func TestPathParams(t *testing.T) {
+	const id = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	tests := []struct {
+		givenPath string
+
+		wantID      int64
+		wantUUID    string
+		wantIDErr   bool
+		wantUUIDErr bool
+	}{
+		{givenPath: "/things/123/" + id, wantID: 123, wantUUID: id},
+		{givenPath: "/things/abc/not-a-uuid", wantIDErr: true, wantUUIDErr: true},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			for _, test := range tests {
+				var (
+					gotID, gotMissing    int64
+					gotUUID              = "unset"
+					idErr, uuidErr, mErr error
+				)
+				rt := NewRouter(&Config{RouterType: routerType})
+				rt.HandleFunc("GET", "/things/{id}/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+					gotID, idErr = PathInt64(r, "id")
+					u, err := PathUUID(r, "uuid")
+					gotUUID, uuidErr = u.String(), err
+					gotMissing, mErr = PathInt64(r, "missing")
+				})
+				rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.givenPath, nil))
+
+				if !isBadRequest(mErr) || gotMissing != 0 {
+					t.Errorf("%s: expected a 400 HTTPError for a missing param, got %d and %v",
+						test.givenPath, gotMissing, mErr)
+				}
+				if (test.wantIDErr && !isBadRequest(idErr)) || (!test.wantIDErr && idErr != nil) {
+					t.Errorf("%s: unexpected id error: %v", test.givenPath, idErr)
+				}
+				if (test.wantUUIDErr && !isBadRequest(uuidErr)) || (!test.wantUUIDErr && uuidErr != nil) {
+					t.Errorf("%s: unexpected uuid error: %v", test.givenPath, uuidErr)
+				}
+				if !test.wantIDErr && gotID != test.wantID {
+					t.Errorf("%s: expected id %d, got %d", test.givenPath, test.wantID, gotID)
+				}
+				if !test.wantUUIDErr && gotUUID != test.wantUUID {
+					t.Errorf("%s: expected uuid %s, got %s", test.givenPath, test.wantUUID, gotUUID)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"context"
 	"io"
 	"net/http"
+	"sync"
 )
 
 // HealthCheckHandler is an interface used by SimpleServer and RPCServer
@@ -82,3 +84,70 @@ func (c *CustomHealthCheck) Stop() error {
 func (c *CustomHealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c.handler.ServeHTTP(w, r)
 }
+
+// HealthCheck is a named readiness probe used by RegisterHealthChecks.
+// Check should return an error if the dependency it probes is unavailable.
+type HealthCheck struct {
+	Name  string
+	Check func(context.Context) error
+}
+
+// HealthStatus is the JSON response of the readiness endpoint registered
+// by RegisterHealthChecks. Checks maps each HealthCheck's name to "ok" or its
+// error message.
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+const (
+	// LivenessPath is the path RegisterHealthChecks serves the liveness
+	// endpoint on.
+	LivenessPath = "/healthz"
+	// ReadinessPath is the path RegisterHealthChecks serves the readiness
+	// endpoint on.
+	ReadinessPath = "/readyz"
+)
+
+// RegisterHealthChecks will register a liveness endpoint at LivenessPath that
+// always responds with a 200 and a readiness endpoint at ReadinessPath that runs
+// all of the given checks and responds with a 503 if any of them fail. The
+// readiness endpoint also responds with a 503, with a "startup" check, until
+// the funcs registered with OnStart have completed.
+func RegisterHealthChecks(router Router, checks ...HealthCheck) {
+	router.Handle(http.MethodGet, LivenessPath, JSONToHTTP(func(r *http.Request) (int, interface{}, error) {
+		return http.StatusOK, HealthStatus{Status: "ok"}, nil
+	}))
+	router.Handle(http.MethodGet, ReadinessPath, JSONToHTTP(func(r *http.Request) (int, interface{}, error) {
+		return readiness(r.Context(), checks)
+	}))
+}
+
+// readiness runs the checks concurrently and summarizes their results.
+func readiness(ctx context.Context, checks []HealthCheck) (int, interface{}, error) {
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			errs[i] = check.Check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	code, status := http.StatusOK, HealthStatus{Status: "ok", Checks: map[string]string{}}
+	for i, check := range checks {
+		if errs[i] != nil {
+			code, status.Status = http.StatusServiceUnavailable, "unavailable"
+			status.Checks[check.Name] = errs[i].Error()
+			continue
+		}
+		status.Checks[check.Name] = "ok"
+	}
+	if err := startupStatus(); err != nil {
+		code, status.Status = http.StatusServiceUnavailable, "unavailable"
+		status.Checks["startup"] = err.Error()
+	}
+	return code, status, nil
+}
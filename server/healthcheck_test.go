@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -41,3 +43,57 @@ func TestSimpleHealthCheck(t *testing.T) {
 	}
 
 }
+
+func TestRegisterHealthChecks(t *testing.T) {
+	pass := HealthCheck{Name: "db", Check: func(context.Context) error { return nil }}
+	fail := HealthCheck{Name: "cache", Check: func(context.Context) error { return errors.New("connection refused") }}
+
+	tests := []struct {
+		name   string
+		checks []HealthCheck
+		path   string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "liveness",
+			checks:   []HealthCheck{fail},
+			path:     "/healthz",
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"ok"}`,
+		},
+		{
+			name:     "ready",
+			checks:   []HealthCheck{pass},
+			path:     "/readyz",
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"ok","checks":{"db":"ok"}}`,
+		},
+		{
+			name:     "not ready",
+			checks:   []HealthCheck{pass, fail},
+			path:     "/readyz",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"unavailable","checks":{"cache":"connection refused","db":"ok"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := NewRouter(&Config{})
+			RegisterHealthChecks(router, test.checks...)
+
+			r, _ := http.NewRequest("GET", test.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != test.wantBody {
+				t.Errorf("expected body %s, got %s", test.wantBody, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrStartupPending is reported by the readiness endpoint registered by
+// RegisterHealthChecks while funcs registered with OnStart have not
+// completed.
+var ErrStartupPending = errors.New("startup hooks have not completed")
+
+var startHooks struct {
+	sync.Mutex
+	fns []func(context.Context) error
+	err error
+}
+
+// OnStart will register a func to initialize a component, like warming a
+// cache or checking a dependency, before the server starts accepting
+// requests. The funcs run in the order they were registered and the server
+// fails to start with the error of the first one that fails. Until they all
+// complete, the readiness endpoint registered by RegisterHealthChecks
+// reports the server as unavailable.
+func OnStart(fn func(ctx context.Context) error) {
+	startHooks.Lock()
+	defer startHooks.Unlock()
+	startHooks.fns = append(startHooks.fns, fn)
+}
+
+// runStartHooks will run the OnStart funcs, unregistering each one that
+// succeeds, and return the error of the first one that fails.
+func runStartHooks(ctx context.Context) error {
+	startHooks.Lock()
+	fns := startHooks.fns
+	startHooks.err = nil
+	startHooks.Unlock()
+
+	for _, fn := range fns {
+		err := fn(ctx)
+		startHooks.Lock()
+		if err != nil {
+			startHooks.err = err
+			startHooks.Unlock()
+			Log.Error("startup hook returned with error: ", err)
+			return err
+		}
+		startHooks.fns = startHooks.fns[1:]
+		startHooks.Unlock()
+	}
+	return nil
+}
+
+// startupStatus returns the error of the failed OnStart func,
+// ErrStartupPending if some have not run yet or nil once they all completed.
+func startupStatus() error {
+	startHooks.Lock()
+	defer startHooks.Unlock()
+	if startHooks.err != nil {
+		return startHooks.err
+	}
+	if len(startHooks.fns) > 0 {
+		return ErrStartupPending
+	}
+	return nil
+}
+
+var shutdownHooks struct {
+	sync.Mutex
+	fns []func(context.Context) error
+}
+
+// OnShutdown will register a func to clean up a component, like a database
+// pool or a pubsub subscriber, when the server stops. The funcs run after
+// in-flight requests are drained, in the reverse order they were registered,
+// with the context given to Shutdown. Each func runs once, so funcs must be
+// registered again for servers started afterwards.
+func OnShutdown(fn func(ctx context.Context) error) {
+	shutdownHooks.Lock()
+	defer shutdownHooks.Unlock()
+	shutdownHooks.fns = append(shutdownHooks.fns, fn)
+}
+
+// ShutdownErrors holds every error returned by the funcs registered with
+// OnShutdown.
+type ShutdownErrors []error
+
+func (e ShutdownErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "shutdown hooks failed: " + strings.Join(msgs, "; ")
+}
+
+// runShutdownHooks will run and unregister the OnShutdown funcs, logging
+// their errors and returning them as a ShutdownErrors.
+func runShutdownHooks(ctx context.Context) error {
+	shutdownHooks.Lock()
+	fns := shutdownHooks.fns
+	shutdownHooks.fns = nil
+	shutdownHooks.Unlock()
+
+	var errs ShutdownErrors
+	for i := len(fns) - 1; i >= 0; i-- {
+		if err := fns[i](ctx); err != nil {
+			Log.Warn("shutdown hook returned with error: ", err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOnStart(t *testing.T) {
+	defer func() {
+		startHooks.fns, startHooks.err = nil, nil
+	}()
+	router := NewRouter(&Config{})
+	RegisterHealthChecks(router)
+	ready := func() (int, string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	var calls []string
+	OnStart(func(context.Context) error {
+		calls = append(calls, "cache")
+		return nil
+	})
+	OnStart(func(context.Context) error {
+		calls = append(calls, "db")
+		return nil
+	})
+
+	wantBody := `{"status":"unavailable","checks":{"startup":"startup hooks have not completed"}}`
+	if code, body := ready(); code != http.StatusServiceUnavailable || body != wantBody {
+		t.Errorf("expected a 503 with %s before starting, got a %d with %s", wantBody, code, body)
+	}
+
+	srvr := NewSimpleServer(&Config{HealthCheckType: "simple", HealthCheckPath: "/status"})
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	srvr.Stop()
+
+	if want := []string{"cache", "db"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected hooks to run in order %v, got %v", want, calls)
+	}
+	if code, body := ready(); code != http.StatusOK {
+		t.Errorf("expected a 200 after starting, got a %d with %s", code, body)
+	}
+}
+
+func TestOnStartError(t *testing.T) {
+	defer func() {
+		startHooks.fns, startHooks.err = nil, nil
+	}()
+	router := NewRouter(&Config{})
+	RegisterHealthChecks(router)
+
+	errDB := errors.New("db: connection refused")
+	var ranAfter bool
+	OnStart(func(context.Context) error { return errDB })
+	OnStart(func(context.Context) error {
+		ranAfter = true
+		return nil
+	})
+
+	srvr := NewSimpleServer(&Config{HealthCheckType: "simple", HealthCheckPath: "/status"})
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err != errDB {
+		t.Fatalf("expected Start to return the hook error, got %v", err)
+	}
+	if srvr.listener != nil {
+		t.Error("expected the server not to listen")
+	}
+	if ranAfter {
+		t.Error("expected hooks after the failing one not to run")
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	wantBody := `{"status":"unavailable","checks":{"startup":"db: connection refused"}}`
+	if got := strings.TrimSpace(w.Body.String()); w.Code != http.StatusServiceUnavailable || got != wantBody {
+		t.Errorf("expected a 503 with %s, got a %d with %s", wantBody, w.Code, got)
+	}
+}
+
+func TestOnShutdown(t *testing.T) {
+	srvr := NewSimpleServer(&Config{HealthCheckType: "simple", HealthCheckPath: "/status"})
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+
+	var calls []string
+	hook := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	errDB, errCache := errors.New("db: close failed"), errors.New("cache: flush failed")
+	OnShutdown(hook("db", errDB))
+	OnShutdown(hook("pubsub", nil))
+	OnShutdown(hook("cache", errCache))
+
+	err := srvr.Stop()
+
+	if want := []string{"cache", "pubsub", "db"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected hooks to run in order %v, got %v", want, calls)
+	}
+	errs, ok := err.(ShutdownErrors)
+	if !ok {
+		t.Fatalf("expected ShutdownErrors, got %T: %v", err, err)
+	}
+	if want := (ShutdownErrors{errCache, errDB}); !reflect.DeepEqual(errs, want) {
+		t.Errorf("expected errors %v, got %v", want, errs)
+	}
+
+	calls = nil
+	if err := runShutdownHooks(context.Background()); err != nil || len(calls) != 0 {
+		t.Errorf("expected hooks to only run once, got calls %v and error %v", calls, err)
+	}
+}
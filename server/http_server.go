@@ -1,13 +1,43 @@
 package server
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
 
-func httpServer(handler http.Handler) *http.Server {
-	return &http.Server{
+// httpServer will return an http.Server for the handler with the timeouts
+// of the config or the defaults for those that are not set.
+func httpServer(cfg *Config, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{
 		Handler:        handler,
 		MaxHeaderBytes: maxHeaderBytes,
-		ReadTimeout:    readTimeout,
-		WriteTimeout:   writeTimeout,
-		IdleTimeout:    idleTimeout,
 	}
+	var err error
+	if srv.ReadTimeout, err = parseTimeout("ReadTimeout", cfg.ReadTimeout, readTimeout); err != nil {
+		return nil, err
+	}
+	if srv.ReadHeaderTimeout, err = parseTimeout("ReadHeaderTimeout", cfg.ReadHeaderTimeout, readHeaderTimeout); err != nil {
+		return nil, err
+	}
+	if srv.WriteTimeout, err = parseTimeout("WriteTimeout", cfg.WriteTimeout, writeTimeout); err != nil {
+		return nil, err
+	}
+	if srv.IdleTimeout, err = parseTimeout("IdleTimeout", cfg.IdleTimeout, idleTimeout); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+// parseTimeout will parse the time.Duration string of the named config
+// field or return the default if it is not set.
+func parseTimeout(name string, value *string, def time.Duration) (time.Duration, error) {
+	if value == nil {
+		return def, nil
+	}
+	d, err := time.ParseDuration(*value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid server %s: %s", name, err)
+	}
+	return d, nil
 }
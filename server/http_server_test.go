@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	srv, err := httpServer(&Config{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if srv.ReadTimeout != readTimeout || srv.ReadHeaderTimeout != readHeaderTimeout ||
+		srv.WriteTimeout != writeTimeout || srv.IdleTimeout != idleTimeout {
+		t.Errorf("expected the default timeouts, got read %s, read header %s, write %s, idle %s",
+			srv.ReadTimeout, srv.ReadHeaderTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	srv, err = httpServer(&Config{
+		ReadTimeout:       str("1s"),
+		ReadHeaderTimeout: str("2s"),
+		WriteTimeout:      str("3s"),
+		IdleTimeout:       str("4s"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if srv.ReadTimeout != time.Second || srv.ReadHeaderTimeout != 2*time.Second ||
+		srv.WriteTimeout != 3*time.Second || srv.IdleTimeout != 4*time.Second {
+		t.Errorf("expected the configured timeouts, got read %s, read header %s, write %s, idle %s",
+			srv.ReadTimeout, srv.ReadHeaderTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	if _, err = httpServer(&Config{ReadHeaderTimeout: str("soon")}, nil); err == nil {
+		t.Error("expected an error for an invalid timeout")
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPFilterMiddleware returns a middleware func that responds with a 403 to
+// requests from client IPs that are in the deny list or, if the allow list is
+// not empty, are not in the allow list. The deny list takes precedence. Both
+// lists accept CIDRs and single IP addresses.
+//
+// The client IP is found with ClientIP and the given trusted proxies.
+func IPFilterMiddleware(allow, deny, trustedProxies []string) (func(http.Handler) http.Handler, error) {
+	allowNets, err := parseCIDRs(allow, false)
+	if err != nil {
+		return nil, err
+	}
+	denyNets, err := parseCIDRs(deny, false)
+	if err != nil {
+		return nil, err
+	}
+	proxyNets, err := parseCIDRs(trustedProxies, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(clientIP(r, proxyNets))
+			if ip == nil || containsIP(denyNets, ip) ||
+				(len(allowNets) > 0 && !containsIP(allowNets, ip)) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// parseCIDRs parses the CIDRs or IP addresses into networks. If
+// skipInvalid is true, invalid entries are skipped instead of returning
+// an error.
+func parseCIDRs(cidrs []string, skipInvalid bool) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				if skipInvalid {
+					continue
+				}
+				return nil, fmt.Errorf("invalid IP address %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			if skipInvalid {
+				continue
+			}
+			return nil, fmt.Errorf("invalid CIDR %q: %s", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
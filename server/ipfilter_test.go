@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilterMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		allow          []string
+		deny           []string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+
+		wantCode int
+	}{
+		{
+			name:       "allow list match",
+			allow:      []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:1234",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "allow list miss",
+			allow:      []string{"10.0.0.0/8"},
+			remoteAddr: "192.168.1.1:1234",
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "deny list precedence",
+			allow:      []string{"10.0.0.0/8"},
+			deny:       []string{"10.1.2.3"},
+			remoteAddr: "10.1.2.3:1234",
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "deny list only",
+			deny:       []string{"10.0.0.0/8"},
+			remoteAddr: "192.168.1.1:1234",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "ipv6",
+			allow:      []string{"2001:db8::/32"},
+			remoteAddr: "[2001:db8::1]:1234",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:         "forwarded for ignored",
+			allow:        []string{"10.0.0.0/8"},
+			remoteAddr:   "192.168.1.1:1234",
+			forwardedFor: "10.1.2.3",
+			wantCode:     http.StatusForbidden,
+		},
+		{
+			name:           "trusted proxy",
+			allow:          []string{"10.0.0.0/8"},
+			trustedProxies: []string{"192.168.0.0/16"},
+			remoteAddr:     "192.168.1.1:1234",
+			forwardedFor:   "1.2.3.4, 10.1.2.3",
+			wantCode:       http.StatusOK,
+		},
+		{
+			name:           "trusted proxy spoofed",
+			allow:          []string{"10.0.0.0/8"},
+			trustedProxies: []string{"192.168.0.0/16"},
+			remoteAddr:     "192.168.1.1:1234",
+			forwardedFor:   "10.1.2.3, 1.2.3.4",
+			wantCode:       http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mw, err := IPFilterMiddleware(test.allow, test.deny, test.trustedProxies)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+		})
+	}
+
+	if _, err := IPFilterMiddleware([]string{"10.0.0.0/33"}, nil, nil); err == nil {
+		t.Error("expected an error for an invalid CIDR")
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaMiddleware returns a middleware func that validates JSON request
+// bodies against the given JSON schema before calling the handler. Bodies
+// that do not conform get a 400 with a JSONSchemaErrors response listing
+// each invalid field. Bodies larger than MaxJSONBodyBytes get a 413. The
+// decoded body is available to the handler via JSONBody(r) and the request
+// body can still be read.
+//
+// Only a subset of JSON schema is supported: the type, properties,
+// required, additionalProperties, items, enum, minLength, maxLength, minimum
+// and maximum keywords, along with the title, description and $schema
+// annotations. It will panic if the schema is not valid JSON or uses any
+// other keyword, so schemas are never only partially enforced.
+func JSONSchemaMiddleware(schema string) func(http.Handler) http.Handler {
+	var s jsonSchema
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		panic("server: invalid JSON schema: " + err.Error())
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > MaxJSONBodyBytes {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxJSONBodyBytes))
+				r.Body.Close()
+				if _, ok := err.(*http.MaxBytesError); ok {
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+					return
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			var v interface{}
+			var errs []JSONSchemaError
+			if err := json.Unmarshal(body, &v); err != nil {
+				errs = []JSONSchemaError{{Message: "invalid JSON: " + err.Error()}}
+			} else {
+				errs = s.validate("", v)
+			}
+			if len(errs) > 0 {
+				w.Header().Set("Content-Type", jsonContentType)
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(JSONSchemaErrors{Errors: errs})
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jsonBodyKey{}, v)))
+		})
+	}
+}
+
+type jsonBodyKey struct{}
+
+// JSONBody returns the request body decoded by JSONSchemaMiddleware
+// or nil if there is none.
+func JSONBody(r *http.Request) interface{} {
+	return r.Context().Value(jsonBodyKey{})
+}
+
+// JSONSchemaErrors is the response of JSONSchemaMiddleware for
+// invalid request bodies.
+type JSONSchemaErrors struct {
+	Errors []JSONSchemaError `json:"errors"`
+}
+
+// JSONSchemaError describes why a field of a request body is invalid.
+// Field is a dot separated path to the field, with array indexes in
+// brackets, and is empty for the body itself.
+type JSONSchemaError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type jsonSchema struct {
+	Type                 jsonSchemaTypes        `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+}
+
+// jsonSchemaKeywords are the keywords a jsonSchema may use.
+var jsonSchemaKeywords = map[string]bool{
+	"type": true, "properties": true, "required": true, "additionalProperties": true,
+	"items": true, "enum": true, "minLength": true, "maxLength": true,
+	"minimum": true, "maximum": true,
+	"title": true, "description": true, "$schema": true,
+}
+
+// UnmarshalJSON decodes the schema, returning an error for keywords that
+// are not supported.
+func (s *jsonSchema) UnmarshalJSON(b []byte) error {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keywords); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !jsonSchemaKeywords[name] {
+			return fmt.Errorf("unsupported keyword %q", name)
+		}
+	}
+	type schema jsonSchema
+	return json.Unmarshal(b, (*schema)(s))
+}
+
+// jsonSchemaTypes holds the types of a schema's type keyword, which may be
+// a single type or an array of them.
+type jsonSchemaTypes []string
+
+// UnmarshalJSON decodes a type name or an array of them.
+func (t *jsonSchemaTypes) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*t = jsonSchemaTypes{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = names
+	return nil
+}
+
+// matches returns true if the schema has no type or the value is of one of
+// its types.
+func (t jsonSchemaTypes) matches(v interface{}) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, name := range t {
+		if jsonType(v, name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *jsonSchema) validate(field string, v interface{}) []JSONSchemaError {
+	fail := func(format string, args ...interface{}) []JSONSchemaError {
+		return []JSONSchemaError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if !s.Type.matches(v) {
+		return fail("expected %s, got %s", strings.Join(s.Type, " or "), jsonType(v, ""))
+	}
+	if len(s.Enum) > 0 && !jsonEnumContains(s.Enum, v) {
+		return fail("must be one of %s", jsonEnumString(s.Enum))
+	}
+
+	var errs []JSONSchemaError
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				errs = append(errs, JSONSchemaError{Field: joinField(field, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, JSONSchemaError{Field: joinField(field, name), Message: "is not allowed"})
+				}
+				continue
+			}
+			errs = append(errs, prop.validate(joinField(field, name), val[name])...)
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range val {
+				errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item)...)
+			}
+		}
+	case string:
+		if n := len([]rune(val)); s.MinLength != nil && n < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		} else if s.MaxLength != nil && n > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		} else if s.Maximum != nil && val > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+	}
+	return errs
+}
+
+// jsonType returns the JSON schema type of the decoded value. Whole
+// numbers are reported as integers if that is the expected type.
+func jsonType(v interface{}, expected string) string {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if expected == "integer" && val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "null"
+}
+
+func jsonEnumContains(enum []interface{}, v interface{}) bool {
+	b, _ := json.Marshal(v)
+	for _, e := range enum {
+		if eb, _ := json.Marshal(e); bytes.Equal(b, eb) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonEnumString(enum []interface{}) string {
+	vals := make([]string, len(enum))
+	for i, e := range enum {
+		b, _ := json.Marshal(e)
+		vals[i] = string(b)
+	}
+	return strings.Join(vals, ", ")
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testUserSchema = `{
+	"type": "object",
+	"required": ["name", "age"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"nickname": {"type": ["string", "null"], "maxLength": 8}
+	}
+}`
+
+func TestJSONSchemaMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+
+		wantCode   int
+		wantErrors []JSONSchemaError
+	}{
+		{
+			name:     "valid body",
+			body:     `{"name": "gizmo", "age": 5, "role": "admin", "tags": ["a", "b"]}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "type mismatch",
+			body:     `{"name": "gizmo", "age": "five", "tags": ["a", 2]}`,
+			wantCode: http.StatusBadRequest,
+			wantErrors: []JSONSchemaError{
+				{Field: "age", Message: "expected integer, got string"},
+				{Field: "tags[1]", Message: "expected string, got number"},
+			},
+		},
+		{
+			name:     "nullable field",
+			body:     `{"name": "gizmo", "age": 5, "nickname": null}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "type array mismatch",
+			body:     `{"name": "gizmo", "age": 5, "nickname": 7}`,
+			wantCode: http.StatusBadRequest,
+			wantErrors: []JSONSchemaError{
+				{Field: "nickname", Message: "expected string or null, got number"},
+			},
+		},
+		{
+			name:     "missing required field",
+			body:     `{"name": "gizmo"}`,
+			wantCode: http.StatusBadRequest,
+			wantErrors: []JSONSchemaError{
+				{Field: "age", Message: "is required"},
+			},
+		},
+		{
+			name:     "constraints",
+			body:     `{"name": "", "age": -1, "role": "root"}`,
+			wantCode: http.StatusBadRequest,
+			wantErrors: []JSONSchemaError{
+				{Field: "age", Message: "must be at least 0"},
+				{Field: "name", Message: "must be at least 1 characters"},
+				{Field: "role", Message: `must be one of "admin", "user"`},
+			},
+		},
+		{
+			name:     "invalid JSON",
+			body:     `{"name": `,
+			wantCode: http.StatusBadRequest,
+			wantErrors: []JSONSchemaError{
+				{Message: "invalid JSON: unexpected end of JSON input"},
+			},
+		},
+	}
+
+	h := JSONSchemaMiddleware(testUserSchema)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := JSONBody(r).(map[string]interface{})
+		raw, _ := ioutil.ReadAll(r.Body)
+		var again map[string]interface{}
+		if err := json.Unmarshal(raw, &again); err != nil || !reflect.DeepEqual(body, again) {
+			t.Errorf("expected the request body to still be readable, got %q", raw)
+		}
+		w.Write([]byte(body["name"].(string)))
+	}))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(test.body)))
+
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if test.wantCode == http.StatusOK {
+				if got := w.Body.String(); got != "gizmo" {
+					t.Errorf("expected the decoded body name, got %q", got)
+				}
+				return
+			}
+			var got JSONSchemaErrors
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("unable to decode errors: %s", err)
+			}
+			if !reflect.DeepEqual(got.Errors, test.wantErrors) {
+				t.Errorf("expected errors %#v, got %#v", test.wantErrors, got.Errors)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaMiddlewareUnsupportedKeyword(t *testing.T) {
+	tests := []string{
+		`{"type": "string", "pattern": "^a"}`,
+		`{"type": "object", "properties": {"email": {"type": "string", "format": "email"}}}`,
+		`{"oneOf": [{"type": "string"}, {"type": "integer"}]}`,
+		`{"type": "array", "items": {"$ref": "#/definitions/user"}}`,
+	}
+
+	for _, schema := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic for the unsupported keyword", schema)
+				}
+			}()
+			JSONSchemaMiddleware(schema)
+		}()
+	}
+}
+
+func TestJSONSchemaMiddlewareTooLarge(t *testing.T) {
+	defer func(limit int64) { MaxJSONBodyBytes = limit }(MaxJSONBodyBytes)
+	MaxJSONBodyBytes = 16
+
+	h := JSONSchemaMiddleware(`{"type": "string"}`)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the handler not to be called")
+	}))
+
+	body := `"` + strings.Repeat("a", 32) + `"`
+	for _, chunked := range []bool{false, true} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("chunked %t: expected status %d, got %d", chunked, http.StatusRequestEntityTooLarge, w.Code)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/NYTimes/gizmo/auth"
+	"golang.org/x/oauth2/jws"
+)
+
+// JWTOptions holds the claims JWTMiddleware requires of the tokens
+// it validates.
+type JWTOptions struct {
+	// Issuer is the required `iss` claim. If empty, any issuer is accepted.
+	Issuer string
+	// Audience is the required `aud` claim. If empty, any audience is accepted.
+	Audience string
+	// Decoder will be used to decode token payloads into claims. If nil,
+	// they are decoded into a *JWTClaims.
+	Decoder auth.ClaimsDecoderFunc
+	// Verify can be used to check any other claims after a token's
+	// signature, expiry, issuer and audience have been verified.
+	Verify auth.VerifyFunc
+}
+
+// JWTClaims is the default claims type of JWTMiddleware.
+type JWTClaims struct {
+	jws.ClaimSet
+}
+
+// BaseClaims returns the token's registered claims.
+func (c *JWTClaims) BaseClaims() *jws.ClaimSet {
+	return &c.ClaimSet
+}
+
+type claimsKey struct{}
+
+// JWTMiddleware returns a middleware func that requires requests to have an
+// `Authorization: Bearer` token signed by one of the keys in ks that has not
+// expired and matches the issuer and audience of the options. Requests
+// without a valid token get a 401. The token's claims are available to the
+// handler via Claims(r).
+//
+// Only protected routes will require a token if the middleware is applied via
+// Router.HandleWithMiddleware.
+func JWTMiddleware(ks auth.PublicKeySource, opts JWTOptions) func(http.Handler) http.Handler {
+	decoder := opts.Decoder
+	if decoder == nil {
+		decoder = func(_ context.Context, b []byte) (auth.ClaimSetter, error) {
+			var claims JWTClaims
+			err := json.Unmarshal(b, &claims)
+			return &claims, err
+		}
+	}
+	verifier := auth.NewVerifier(ks, decoder, func(ctx context.Context, c interface{}) bool {
+		claims := c.(auth.ClaimSetter).BaseClaims()
+		if opts.Issuer != "" && claims.Iss != opts.Issuer {
+			return false
+		}
+		if opts.Audience != "" && claims.Aud != opts.Audience {
+			return false
+		}
+		return opts.Verify == nil || opts.Verify(ctx, c)
+	})
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				unauthorized(w, r, "missing bearer token")
+				return
+			}
+			claims, err := verifier.VerifyClaims(r.Context(), token)
+			if err != nil {
+				unauthorized(w, r, err.Error())
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+		})
+	}
+}
+
+// Claims returns the JWT claims of a request validated by JWTMiddleware
+// or nil if there are none.
+func Claims(r *http.Request) auth.ClaimSetter {
+	claims, _ := r.Context().Value(claimsKey{}).(auth.ClaimSetter)
+	return claims
+}
+
+// bearerToken returns the token of the request's Authorization header.
+func bearerToken(r *http.Request) string {
+	hdr := r.Header.Get("Authorization")
+	if len(hdr) < 7 || !strings.EqualFold(hdr[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(hdr[7:])
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, reason string) {
+	LogWithFields(r).Debug("unauthorized request: ", reason)
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/auth"
+	"golang.org/x/oauth2/jws"
+)
+
+type testKeySource struct {
+	keys auth.PublicKeySet
+}
+
+func (t testKeySource) Get(ctx context.Context) (auth.PublicKeySet, error) {
+	return t.keys, nil
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := testKeySource{keys: auth.PublicKeySet{
+		Expiry: time.Now().Add(time.Hour),
+		Keys:   map[string]*rsa.PublicKey{"1": &key.PublicKey},
+	}}
+
+	now := time.Now().Unix()
+	sign := func(k *rsa.PrivateKey, claims jws.ClaimSet) string {
+		token, err := jws.Encode(&jws.Header{Algorithm: "RS256", Typ: "JWT", KeyID: "1"}, &claims, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+
+		wantCode int
+		wantSub  string
+	}{
+		{
+			name:     "valid token",
+			token:    sign(key, jws.ClaimSet{Iss: "gizmo", Aud: "api", Sub: "user", Iat: now, Exp: now + 3600}),
+			wantCode: http.StatusOK,
+			wantSub:  "user",
+		},
+		{
+			name:     "expired token",
+			token:    sign(key, jws.ClaimSet{Iss: "gizmo", Aud: "api", Sub: "user", Iat: now - 7200, Exp: now - 3600}),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong signature",
+			token:    sign(otherKey, jws.ClaimSet{Iss: "gizmo", Aud: "api", Sub: "user", Iat: now, Exp: now + 3600}),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong audience",
+			token:    sign(key, jws.ClaimSet{Iss: "gizmo", Aud: "other", Sub: "user", Iat: now, Exp: now + 3600}),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "missing token",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	router := NewRouter(&Config{})
+	router.HandleWithMiddleware("GET", "/protected", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Claims(r).BaseClaims().Sub))
+	}), JWTMiddleware(ks, JWTOptions{Issuer: "gizmo", Audience: "api"}))
+	router.HandleFunc("GET", "/public", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/protected", nil)
+			if test.token != "" {
+				r.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if got := w.Body.String(); test.wantSub != "" && got != test.wantSub {
+				t.Errorf("expected subject %q, got %q", test.wantSub, got)
+			}
+		})
+	}
+
+	r, _ := http.NewRequest("GET", "/public", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected unprotected route to be allowed, got status %d", w.Code)
+	}
+}
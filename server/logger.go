@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+// LeveledLogger is the minimal logger Logger returns for a request. It is
+// satisfied by *logrus.Entry and *zap.SugaredLogger, among others.
+type LeveledLogger interface {
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+}
+
+type loggerKey struct{}
+
+// LoggerMiddleware returns a middleware func that puts a logger carrying the
+// request ID, method and path of each request into its context, where
+// Logger can get it from. The logger is created by calling newLogger with
+// those fields. If newLogger is nil, this will default to the server package
+// Log. It must wrap the RequestIDMiddleware for the request ID to be added.
+func LoggerMiddleware(newLogger func(fields map[string]interface{}) LeveledLogger) func(http.Handler) http.Handler {
+	if newLogger == nil {
+		newLogger = defaultLogger
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := newLogger(requestLogFields(r))
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerKey{}, l)))
+		})
+	}
+}
+
+// Logger will return the logger LoggerMiddleware put into the request's
+// context. For requests it did not handle, this returns the server package
+// Log with the same request fields.
+func Logger(r *http.Request) LeveledLogger {
+	if l, ok := r.Context().Value(loggerKey{}).(LeveledLogger); ok {
+		return l
+	}
+	return defaultLogger(requestLogFields(r))
+}
+
+func defaultLogger(fields map[string]interface{}) LeveledLogger {
+	return Log.WithFields(fields)
+}
+
+func requestLogFields(r *http.Request) map[string]interface{} {
+	fields := map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	}
+	if id := RequestID(r); id != "" {
+		fields["request-id"] = id
+	}
+	return fields
+}
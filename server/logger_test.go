@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogger(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	newLogger := func(fields map[string]interface{}) LeveledLogger {
+		return logger.WithFields(fields)
+	}
+	h := RequestIDMiddleware(LoggerMiddleware(newLogger)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			Logger(r).Info("hello")
+		})))
+
+	r := httptest.NewRequest("POST", "/thing", nil)
+	r.Header.Set(RequestIDHeader, "abc-123")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("expected a log entry")
+	}
+	if entry.Message != "hello" {
+		t.Errorf("expected message %q, got %q", "hello", entry.Message)
+	}
+	want := map[string]interface{}{
+		"request-id": "abc-123",
+		"method":     "POST",
+		"path":       "/thing",
+	}
+	for k, v := range want {
+		if entry.Data[k] != v {
+			t.Errorf("expected field %s to be %v, got %v", k, v, entry.Data[k])
+		}
+	}
+}
+
+func TestLoggerWithoutMiddleware(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	defer func(l *logrus.Logger) { Log = l }(Log)
+	Log = logger
+
+	r := httptest.NewRequest("GET", "/other", nil)
+	Logger(r).Warn("careful")
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("expected a log entry")
+	}
+	if entry.Data["method"] != "GET" || entry.Data["path"] != "/other" {
+		t.Errorf("expected the request fields, got %v", entry.Data)
+	}
+}
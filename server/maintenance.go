@@ -0,0 +1,63 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var maintenance struct {
+	mu         sync.RWMutex
+	on         bool
+	retryAfter time.Duration
+}
+
+// SetMaintenanceMode will turn the maintenance mode of the
+// MaintenanceMiddleware on or off. While it is on, requests get a 503 with
+// a Retry-After header of retryAfter, if it is positive. It is safe to call
+// at any time, like from a signal handler or an admin endpoint.
+func SetMaintenanceMode(on bool, retryAfter time.Duration) {
+	maintenance.mu.Lock()
+	maintenance.on = on
+	maintenance.retryAfter = retryAfter
+	maintenance.mu.Unlock()
+}
+
+// MaintenanceMode returns whether maintenance mode is on and the
+// Retry-After duration it was turned on with.
+func MaintenanceMode() (on bool, retryAfter time.Duration) {
+	maintenance.mu.RLock()
+	defer maintenance.mu.RUnlock()
+	return maintenance.on, maintenance.retryAfter
+}
+
+// MaintenanceMiddleware returns a middleware func that responds with a 503
+// to every request while maintenance mode is turned on with
+// SetMaintenanceMode, except for requests to the allowed paths.
+//
+// SimpleServer applies it to every request, allowing its health check and
+// metrics paths, the paths of RegisterHealthChecks and any in
+// Config.MaintenanceAllowList.
+func MaintenanceMiddleware(allow ...string) func(http.Handler) http.Handler {
+	return maintenanceMiddleware(func(path string) bool {
+		return containsString(allow, path)
+	})
+}
+
+func maintenanceMiddleware(allowed func(path string) bool) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			on, retryAfter := MaintenanceMode()
+			if !on || allowed(r.URL.Path) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			}
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		})
+	}
+}
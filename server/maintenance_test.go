@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	defer SetMaintenanceMode(false, 0)
+
+	h := MaintenanceMiddleware("/healthz")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := serve("/articles"); w.Code != http.StatusOK {
+		t.Errorf("expected a 200 before maintenance, got %d", w.Code)
+	}
+
+	SetMaintenanceMode(true, 90*time.Second)
+	w := serve("/articles")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 during maintenance, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "90" {
+		t.Errorf("expected a Retry-After of 90, got %q", got)
+	}
+	if w := serve("/healthz"); w.Code != http.StatusOK {
+		t.Errorf("expected the allowed path to get a 200 during maintenance, got %d", w.Code)
+	}
+
+	SetMaintenanceMode(false, 0)
+	if w := serve("/articles"); w.Code != http.StatusOK {
+		t.Errorf("expected a 200 after maintenance, got %d", w.Code)
+	}
+}
+
+func TestSimpleServerMaintenanceMode(t *testing.T) {
+	defer SetMaintenanceMode(false, 0)
+
+	cfg := &Config{HealthCheckType: "simple", HealthCheckPath: "/status"}
+	srvr := NewSimpleServer(cfg)
+	RegisterHealthHandler(cfg, srvr.monitor, srvr.mux)
+	RegisterHealthChecks(srvr.mux)
+	if err := srvr.Register(&benchmarkSimpleService{}); err != nil {
+		t.Fatalf("unable to register service: %s", err)
+	}
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		srvr.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	SetMaintenanceMode(true, 0)
+	if code := serve("/svc/v1/2"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 during maintenance, got %d", code)
+	}
+	if code := serve("/status"); code != http.StatusOK {
+		t.Errorf("expected the health check to get a 200 during maintenance, got %d", code)
+	}
+	for _, path := range []string{LivenessPath, ReadinessPath} {
+		if code := serve(path); code != http.StatusOK {
+			t.Errorf("expected %s to get a 200 during maintenance, got %d", path, code)
+		}
+	}
+
+	SetMaintenanceMode(false, 0)
+	if code := serve("/svc/v1/2"); code != http.StatusOK {
+		t.Errorf("expected a 200 after maintenance, got %d", code)
+	}
+}
@@ -4,8 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
+
+	uuid "github.com/nu7hatch/gouuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
 )
 
 // JSONToHTTP is the middleware func to convert a JSONEndpoint to
@@ -27,7 +39,6 @@ func JSONToHTTP(ep JSONEndpoint) http.Handler {
 
 		// call the func and return err or not
 		code, res, err := ep(r)
-		w.WriteHeader(code)
 		if err != nil {
 			res = err
 		}
@@ -37,12 +48,23 @@ func JSONToHTTP(ep JSONEndpoint) http.Handler {
 			LogWithFields(r).Error("unable to JSON encode response: ", err)
 		}
 
+		setContentLength(w, code, b.Len())
+		w.WriteHeader(code)
 		if _, err := w.Write(b.Bytes()); err != nil {
 			LogWithFields(r).Warn("unable to write response: ", err)
 		}
 	})
 }
 
+// chainMiddleware will wrap the given handler with the given middleware so that
+// the first middleware in the list is the outermost.
+func chainMiddleware(h http.Handler, mw ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
 // CORSHandler is a middleware func for setting all headers that enable CORS.
 // If an originSuffix is provided, a strings.HasSuffix check will be performed
 // before adding any CORS header. If an empty string is provided, any Origin
@@ -67,6 +89,288 @@ func CORSHandler(f http.Handler, originSuffix string) http.Handler {
 	})
 }
 
+// RequestIDHeader is the header RequestIDMiddleware reads incoming request
+// IDs from and echoes them on.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDMiddleware is a middleware func for giving every request a unique ID.
+// The ID is taken from the X-Request-ID header or generated if it is absent
+// or not a valid request ID, set on the response's X-Request-ID header and
+// made available via RequestID. Valid request IDs are at most 128 bytes of
+// letters, digits, '.', '_' and '-', so clients can not inject arbitrary
+// values into logs and responses.
+func RequestIDMiddleware(f http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.ServeHTTP(w, withRequestID(w, r))
+	})
+}
+
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		uid, err := uuid.NewV4()
+		if err != nil {
+			LogWithFields(r).Warn("unable to generate request ID: ", err)
+			return r
+		}
+		id = uid.String()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// maxRequestIDLength is the longest incoming request ID withRequestID accepts.
+const maxRequestIDLength = 128
+
+// validRequestID returns true if the incoming request ID is not empty, not
+// longer than maxRequestIDLength and only made of [A-Za-z0-9._-].
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID will return the ID given to the request by RequestIDMiddleware
+// or an empty string if it has none.
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// responseRequestID returns the request ID of the request or, for
+// middleware wrapping RequestIDMiddleware that do not see the request it
+// passes on, the ID it set on the response. This way the access log and
+// recovery entries of a request always share its ID.
+func responseRequestID(w http.ResponseWriter, r *http.Request) string {
+	if id := RequestID(r); id != "" {
+		return id
+	}
+	return w.Header().Get(RequestIDHeader)
+}
+
+// AccessLogMiddleware returns a middleware func for logging the method, path,
+// status, duration, bytes written and request ID of every request to the
+// given logger. If fields is not nil, the fields it returns for the request
+// are added to each entry.
+func AccessLogMiddleware(logger logrus.FieldLogger, fields func(*http.Request) map[string]interface{}) func(http.Handler) http.Handler {
+	return func(f http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := NewResponseWriter(w)
+			f.ServeHTTP(rw, r)
+
+			entry := logger.WithFields(logrus.Fields{
+				"method":   r.Method,
+				"path":     r.URL.Path,
+				"status":   rw.Status(),
+				"duration": time.Since(start).String(),
+				"bytes":    rw.BytesWritten(),
+			})
+			if id := responseRequestID(rw, r); id != "" {
+				entry = entry.WithField("request-id", id)
+			}
+			if fields != nil {
+				entry = entry.WithFields(fields(r))
+			}
+			entry.Info("access")
+		})
+	}
+}
+
+// RecoveryMiddleware returns a middleware func that recovers from panics in
+// the handler, logs them with their stack trace, counts them in the
+// `http_panics_recovered_total` Prometheus metric and responds with a 500 and
+// a JSONErrorEnvelope. Panics with http.ErrAbortHandler are re-panicked so
+// the server still aborts the response. The request ID, if any, is logged
+// and echoed on the X-Request-ID header of the 500 so the entry can be
+// correlated with the access log. SimpleServer applies it to every request.
+func RecoveryMiddleware(logger logrus.FieldLogger) func(http.Handler) http.Handler {
+	panics := registerCollector(prometheus.DefaultRegisterer, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_panics_recovered_total",
+		Help: "The number of panics recovered from while serving HTTP requests.",
+	})).(prometheus.Counter)
+	return func(f http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				x := recover()
+				if x == nil {
+					return
+				}
+				if x == http.ErrAbortHandler {
+					panic(x)
+				}
+				panics.Inc()
+				entry := logger.WithFields(logrus.Fields{
+					"method": r.Method,
+					"path":   r.URL.Path,
+					"panic":  fmt.Sprint(x),
+					"stack":  string(debug.Stack()),
+				})
+				if id := responseRequestID(w, r); id != "" {
+					entry = entry.WithField("request-id", id)
+					w.Header().Set(RequestIDHeader, id)
+				}
+				entry.Error("recovered from a panic")
+
+				w.Header().Set("Content-Type", jsonContentType)
+				w.WriteHeader(http.StatusInternalServerError)
+				err := json.NewEncoder(w).Encode(JSONErrorEnvelope{Error: JSONErrorDetail{
+					Status:  http.StatusInternalServerError,
+					Message: http.StatusText(http.StatusInternalServerError),
+				}})
+				if err != nil {
+					logger.Warn("unable to write response: ", err)
+				}
+			}()
+			f.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PrometheusMiddleware returns a middleware func for recording the count and
+// duration of requests in Prometheus metrics labeled by method, status class
+// and the path template of the matched route, along with the number of
+// requests in flight. It must wrap a Router so the route template is known.
+// The metrics are registered with reg, which will default to the
+// prometheus.DefaultRegisterer if nil.
+func PrometheusMiddleware(reg prometheus.Registerer, namespace, subsystem string) func(http.Handler) http.Handler {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	labels := []string{"method", "route", "status"}
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "http_requests_total",
+		Help:      "The number of HTTP requests served.",
+	}, labels)
+	requests = registerCollector(reg, requests).(*prometheus.CounterVec)
+	durations := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "http_request_duration_seconds",
+		Help:      "The duration of HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, labels)
+	durations = registerCollector(reg, durations).(*prometheus.HistogramVec)
+	inFlight := registerCollector(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "http_requests_in_flight",
+		Help:      "The number of HTTP requests being served.",
+	})).(prometheus.Gauge)
+
+	return func(f http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inFlight.Inc()
+			defer inFlight.Dec()
+			start := time.Now()
+			rw := NewResponseWriter(w)
+			r, routeTemplate := trackRouteTemplate(r)
+			f.ServeHTTP(rw, r)
+
+			route := routeTemplate()
+			if route == "" {
+				// avoid a label for every unknown path
+				route = "__404__"
+			}
+			status := strconv.Itoa(rw.Status()/100) + "xx"
+			requests.WithLabelValues(r.Method, route, status).Inc()
+			durations.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// TracingMiddleware returns a middleware func for starting an OpenCensus span
+// around every request. The trace context is extracted from the incoming
+// headers with the given format, which will default to the W3C Trace Context
+// format if nil. Like PrometheusMiddleware, it must wrap a Router so the span
+// can be named after the path template of the matched route.
+func TracingMiddleware(format propagation.HTTPFormat, opts ...trace.StartOption) func(http.Handler) http.Handler {
+	if format == nil {
+		format = &tracecontext.HTTPFormat{}
+	}
+	opts = append([]trace.StartOption{trace.WithSpanKind(trace.SpanKindServer)}, opts...)
+	return func(f http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				ctx  context.Context
+				span *trace.Span
+			)
+			if sc, ok := format.SpanContextFromRequest(r); ok {
+				ctx, span = trace.StartSpanWithRemoteParent(r.Context(), r.URL.Path, sc, opts...)
+			} else {
+				ctx, span = trace.StartSpan(r.Context(), r.URL.Path, opts...)
+			}
+			defer span.End()
+
+			rw := NewResponseWriter(w)
+			r, routeTemplate := trackRouteTemplate(r.WithContext(ctx))
+			f.ServeHTTP(rw, r)
+
+			attrs := []trace.Attribute{
+				trace.StringAttribute(ochttp.MethodAttribute, r.Method),
+				trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(rw.Status())),
+			}
+			if route := routeTemplate(); route != "" {
+				span.SetName(route)
+				attrs = append(attrs, trace.StringAttribute("http.route", route))
+			}
+			span.AddAttributes(attrs...)
+			span.SetStatus(ochttp.TraceStatus(rw.Status(), ""))
+		})
+	}
+}
+
+// registerCollector will register the collector or return the collector
+// that has already been registered in its place.
+func registerCollector(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		Log.Warn("unable to register prometheus collector: ", err)
+	}
+	return c
+}
+
+// RequireHeadersMiddleware returns a middleware func that responds with a
+// 400 and an error, encoded by the ErrorEncoder set with SetErrorEncoder,
+// listing every missing header to requests without a value for all of the
+// given headers.
+//
+// The middleware can be applied to a single route via
+// Router.HandleWithMiddleware or to a whole service via Service.Middleware.
+func RequireHeadersMiddleware(names ...string) func(http.Handler) http.Handler {
+	return func(f http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var missing []string
+			for _, name := range names {
+				if r.Header.Get(name) == "" {
+					missing = append(missing, http.CanonicalHeaderKey(name))
+				}
+			}
+			if len(missing) == 0 {
+				f.ServeHTTP(w, r)
+				return
+			}
+			errorEncoder(w, r, http.StatusBadRequest, NewHTTPError(http.StatusBadRequest,
+				"missing required headers: "+strings.Join(missing, ", ")))
+		})
+	}
+}
+
 // NoCacheHandler is a middleware func for setting the Cache-Control to no-cache.
 func NoCacheHandler(f http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -2,12 +2,19 @@ package server
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"go.opencensus.io/trace"
 )
 
 func TestCORSHandler(t *testing.T) {
@@ -177,3 +184,403 @@ func TestNoCacheHandler(t *testing.T) {
 		t.Errorf("expected no-cache Expires header to be '%#v', got '%#v'", want, got)
 	}
 }
+
+func TestRequireHeadersMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		givenHeaders map[string]string
+
+		wantCode    int
+		wantMessage string
+	}{
+		{
+			name:         "all present",
+			givenHeaders: map[string]string{"X-Api-Version": "2", "X-Client": "gizmo"},
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "one missing",
+			givenHeaders: map[string]string{"X-Api-Version": "2"},
+			wantCode:     http.StatusBadRequest,
+			wantMessage:  "missing required headers: X-Client",
+		},
+		{
+			name:        "all missing",
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "missing required headers: X-Api-Version, X-Client",
+		},
+	}
+
+	rt := NewRouter(&Config{})
+	rt.HandleWithMiddleware("GET", "/things", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), RequireHeadersMiddleware("x-api-version", "X-Client"))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/things", nil)
+			for k, v := range test.givenHeaders {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if test.wantMessage == "" {
+				return
+			}
+			var got JSONErrorEnvelope
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("unable to decode error response: %s", err)
+			}
+			if got.Error.Status != test.wantCode || got.Error.Message != test.wantMessage {
+				t.Errorf("expected error %d %q, got %d %q", test.wantCode, test.wantMessage,
+					got.Error.Status, got.Error.Message)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		givenID string
+
+		wantGenerated bool
+	}{
+		{"", true},
+		{"abc-123", false},
+		{"trace_1.2-AB", false},
+		{strings.Repeat("a", 128), false},
+		{strings.Repeat("a", 129), true},
+		{"abc 123", true},
+		{"abc\r\nX-Injected: 1", true},
+		{`{"id":1}`, true},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.givenID != "" {
+			r.Header.Set(RequestIDHeader, test.givenID)
+		}
+		w := httptest.NewRecorder()
+
+		var gotID, gotField string
+		RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotID = RequestID(r)
+			gotField, _ = ContextFields(r)["request-id"].(string)
+		})).ServeHTTP(w, r)
+
+		if test.wantGenerated {
+			if len(gotID) != 36 {
+				t.Errorf("expected a generated UUID request ID, got '%#v'", gotID)
+			}
+		} else if gotID != test.givenID {
+			t.Errorf("expected request ID to be '%#v', got '%#v'", test.givenID, gotID)
+		}
+		if got := w.Header().Get(RequestIDHeader); got != gotID {
+			t.Errorf("expected request ID header to be '%#v', got '%#v'", gotID, got)
+		}
+		if gotField != gotID {
+			t.Errorf("expected request-id log field to be '%#v', got '%#v'", gotID, gotField)
+		}
+	}
+
+	if got := RequestID(httptest.NewRequest("GET", "/", nil)); got != "" {
+		t.Errorf("expected no request ID without the middleware, got '%#v'", got)
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		givenStatus int
+		givenBody   string
+
+		wantStatus int
+		wantBytes  int
+	}{
+		{"implicit status", 0, "hello", http.StatusOK, 5},
+		{"explicit status", http.StatusCreated, "created!", http.StatusCreated, 8},
+		{"no body", http.StatusNoContent, "", http.StatusNoContent, 0},
+	}
+
+	for _, test := range tests {
+		logger, hook := logtest.NewNullLogger()
+		extra := func(r *http.Request) map[string]interface{} {
+			return map[string]interface{}{"user-agent": r.UserAgent()}
+		}
+		h := AccessLogMiddleware(logger, extra)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.givenStatus != 0 {
+				w.WriteHeader(test.givenStatus)
+			}
+			io.WriteString(w, test.givenBody)
+		}))
+
+		r := httptest.NewRequest("POST", "/svc/users", nil)
+		r.Header.Set("User-Agent", "gizmo-test")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		entry := hook.LastEntry()
+		if entry == nil {
+			t.Errorf("%s: expected an access log entry", test.name)
+			continue
+		}
+		if got := entry.Data["status"]; got != test.wantStatus {
+			t.Errorf("%s: expected status to be '%#v', got '%#v'", test.name, test.wantStatus, got)
+		}
+		if got := entry.Data["bytes"]; got != test.wantBytes {
+			t.Errorf("%s: expected bytes to be '%#v', got '%#v'", test.name, test.wantBytes, got)
+		}
+		if got := entry.Data["method"]; got != "POST" {
+			t.Errorf("%s: expected method to be 'POST', got '%#v'", test.name, got)
+		}
+		if got := entry.Data["path"]; got != "/svc/users" {
+			t.Errorf("%s: expected path to be '/svc/users', got '%#v'", test.name, got)
+		}
+		if got := entry.Data["user-agent"]; got != "gizmo-test" {
+			t.Errorf("%s: expected extra field to be 'gizmo-test', got '%#v'", test.name, got)
+		}
+	}
+}
+
+func TestPrometheusMiddleware(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.HandleFunc("GET", "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if Vars(r)["id"] == "0" {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			})
+			h := PrometheusMiddleware(reg, "test", "svc")(rt)
+
+			for _, path := range []string{"/users/1", "/users/2", "/users/0", "/nope"} {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+			}
+
+			tests := []struct {
+				givenRoute  string
+				givenStatus string
+
+				wantCount float64
+			}{
+				{"/users/{id}", "2xx", 2},
+				{"/users/{id}", "5xx", 1},
+				{"__404__", "4xx", 1},
+			}
+
+			counts := metricCounts(t, reg)
+			for _, test := range tests {
+				key := "GET " + test.givenRoute + " " + test.givenStatus
+				if got := counts["test_svc_http_requests_total"][key]; got != test.wantCount {
+					t.Errorf("%s: expected request count %v, got %v", key, test.wantCount, got)
+				}
+				if got := counts["test_svc_http_request_duration_seconds"][key]; got != test.wantCount {
+					t.Errorf("%s: expected duration sample count %v, got %v", key, test.wantCount, got)
+				}
+			}
+		})
+	}
+}
+
+// metricCounts returns the counter values and histogram sample counts
+// of the gathered metrics by name and "method route status" labels.
+func metricCounts(t *testing.T, reg *prometheus.Registry) map[string]map[string]float64 {
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("unable to gather metrics: %s", err)
+	}
+	counts := map[string]map[string]float64{}
+	for _, mf := range mfs {
+		counts[mf.GetName()] = map[string]float64{}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			key := labels["method"] + " " + labels["route"] + " " + labels["status"]
+			if m.GetCounter() != nil {
+				counts[mf.GetName()][key] = m.GetCounter().GetValue()
+			} else if m.GetHistogram() != nil {
+				counts[mf.GetName()][key] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return counts
+}
+
+type testSpanExporter struct {
+	spans []*trace.SpanData
+}
+
+func (e *testSpanExporter) ExportSpan(sd *trace.SpanData) {
+	e.spans = append(e.spans, sd)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	exp := &testSpanExporter{}
+	trace.RegisterExporter(exp)
+	defer trace.UnregisterExporter(exp)
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			exp.spans = nil
+			rt := NewRouter(&Config{RouterType: routerType})
+			var gotCtx trace.SpanContext
+			rt.HandleFunc("GET", "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if span := trace.FromContext(r.Context()); span != nil {
+					gotCtx = span.SpanContext()
+				}
+				w.WriteHeader(http.StatusAccepted)
+			})
+			h := TracingMiddleware(nil, trace.WithSampler(trace.AlwaysSample()))(rt)
+
+			r := httptest.NewRequest("GET", "/users/123", nil)
+			r.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got := gotCtx.TraceID.String(); got != traceID {
+				t.Errorf("expected handler span trace ID to be %q, got %q", traceID, got)
+			}
+			if len(exp.spans) != 1 {
+				t.Fatalf("expected 1 exported span, got %d", len(exp.spans))
+			}
+			sd := exp.spans[0]
+			if sd.Name != "/users/{id}" {
+				t.Errorf("expected span name to be %q, got %q", "/users/{id}", sd.Name)
+			}
+			if got := sd.ParentSpanID.String(); got != spanID {
+				t.Errorf("expected span parent ID to be %q, got %q", spanID, got)
+			}
+			if got := sd.Attributes["http.route"]; got != "/users/{id}" {
+				t.Errorf("expected route attribute to be %q, got %v", "/users/{id}", got)
+			}
+			if got := sd.Attributes["http.method"]; got != "GET" {
+				t.Errorf("expected method attribute to be %q, got %v", "GET", got)
+			}
+			if got := sd.Attributes["http.status_code"]; got != int64(http.StatusAccepted) {
+				t.Errorf("expected status code attribute to be %d, got %v", http.StatusAccepted, got)
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	h := RecoveryMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		panic("boom")
+	}))
+	before := recoveredPanics(t)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != JSONContentType {
+		t.Errorf("expected Content-Type %q, got %q", JSONContentType, got)
+	}
+	wantBody := `{"error":{"status":500,"message":"Internal Server Error"}}` + "\n"
+	if got := w.Body.String(); got != wantBody {
+		t.Errorf("expected body %q, got %q", wantBody, got)
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("expected the panic to be logged")
+	}
+	if entry.Data["panic"] != "boom" {
+		t.Errorf("expected the panic value to be logged, got %v", entry.Data["panic"])
+	}
+	if stack, _ := entry.Data["stack"].(string); !strings.Contains(stack, "TestRecoveryMiddleware") {
+		t.Errorf("expected the stack trace to be logged, got %q", stack)
+	}
+	if got := recoveredPanics(t) - before; got != 1 {
+		t.Errorf("expected 1 recovered panic to be counted, got %v", got)
+	}
+
+	defer func() {
+		if x := recover(); x != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-panicked, got %v", x)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+}
+
+func TestRecoveryAccessLogRequestID(t *testing.T) {
+	panicky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// handlers resetting their headers should not lose the ID
+		w.Header().Del(RequestIDHeader)
+		panic("boom")
+	})
+
+	tests := []struct {
+		name  string
+		given func(logger *logrus.Logger) http.Handler
+	}{
+		{
+			name: "request ID outermost",
+			given: func(logger *logrus.Logger) http.Handler {
+				return RequestIDMiddleware(AccessLogMiddleware(logger, nil)(RecoveryMiddleware(logger)(panicky)))
+			},
+		},
+		{
+			name: "request ID innermost",
+			given: func(logger *logrus.Logger) http.Handler {
+				return AccessLogMiddleware(logger, nil)(RecoveryMiddleware(logger)(RequestIDMiddleware(
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") }))))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/boom", nil)
+			r.Header.Set(RequestIDHeader, "abc-123")
+			test.given(logger).ServeHTTP(w, r)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+			}
+			if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+				t.Errorf("expected the 500 to echo the request ID, got %q", got)
+			}
+			entries := hook.AllEntries()
+			if len(entries) != 2 {
+				t.Fatalf("expected a recovery and an access log entry, got %d entries", len(entries))
+			}
+			for _, entry := range entries {
+				if got := entry.Data["request-id"]; got != "abc-123" {
+					t.Errorf("expected the %q entry to have the request ID, got %v", entry.Message, got)
+				}
+			}
+		})
+	}
+}
+
+func recoveredPanics(t *testing.T) float64 {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("unable to gather metrics: %s", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == "http_panics_recovered_total" {
+			return mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	return 0
+}
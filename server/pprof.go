@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// PprofPrefix is the path prefix RegisterPprof mounts the pprof handlers
+// under.
+const PprofPrefix = "/debug/pprof/"
+
+// RegisterPprof will register the net/http/pprof handlers on the router
+// under PprofPrefix, wrapped with the given middleware. Every profile linked
+// to by the index page, like heap or goroutine, is served. As profiles can
+// leak sensitive details and be expensive to take, the middleware should
+// restrict access to operators, like BasicAuthMiddleware does.
+func RegisterPprof(router Router, mw ...func(http.Handler) http.Handler) {
+	wrap := func(h http.Handler) http.Handler {
+		for i := len(mw) - 1; i >= 0; i-- {
+			h = mw[i](h)
+		}
+		return h
+	}
+	router.Handle(http.MethodGet, PprofPrefix+"cmdline", wrap(http.HandlerFunc(pprof.Cmdline)))
+	router.Handle(http.MethodGet, PprofPrefix+"profile", wrap(http.HandlerFunc(pprof.Profile)))
+	router.HandleMethods([]string{http.MethodGet, http.MethodPost}, PprofPrefix+"symbol",
+		wrap(http.HandlerFunc(pprof.Symbol)))
+	router.Handle(http.MethodGet, PprofPrefix+"trace", wrap(http.HandlerFunc(pprof.Trace)))
+	// the index serves any named profile from the rest of the subtree.
+	router.HandleCatchAll(http.MethodGet, PprofPrefix, wrap(http.HandlerFunc(pprof.Index)))
+}
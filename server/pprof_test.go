@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegisterPprof(t *testing.T) {
+	tests := []struct {
+		givenPath string
+
+		wantBody string
+	}{
+		{"/debug/pprof/", "Types of profiles available"},
+		{"/debug/pprof/goroutine?debug=1", "goroutine profile"},
+		{"/debug/pprof/cmdline", ""},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			RegisterPprof(rt, BasicAuthMiddleware("debug", BasicAuthCredentials("ops", "secret")))
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest("GET", test.givenPath, nil))
+				if w.Code != http.StatusUnauthorized {
+					t.Errorf("%s: expected status 401 without auth, got %d", test.givenPath, w.Code)
+				}
+
+				r := httptest.NewRequest("GET", test.givenPath, nil)
+				r.SetBasicAuth("ops", "secret")
+				w = httptest.NewRecorder()
+				rt.ServeHTTP(w, r)
+				if w.Code != http.StatusOK {
+					t.Errorf("%s: expected status 200 with auth, got %d", test.givenPath, w.Code)
+				}
+				if !strings.Contains(w.Body.String(), test.wantBody) {
+					t.Errorf("%s: expected body to contain %q, got %q", test.givenPath, test.wantBody, w.Body.String())
+				}
+			}
+		})
+	}
+}
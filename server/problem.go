@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details document. It is an HTTPError, so
+// endpoints can return it as their error and JSONEndpointHandler and
+// StreamEndpointHandler will respond with it as is via EncodeProblem.
+type Problem struct {
+	// Type is a URI reference identifying the problem type.
+	Type string `json:"type,omitempty"`
+	// Title is a short, human readable summary of the problem type.
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status,omitempty"`
+	// Detail is a human readable explanation of this occurrence of the
+	// problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI reference identifying this occurrence of the
+	// problem.
+	Instance string `json:"instance,omitempty"`
+	// Extensions are additional members, like "balance" or
+	// "invalid-params", added to the document next to the standard ones.
+	// Extensions named like a standard member are ignored.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem returns a Problem with the given status code and detail, the
+// "about:blank" type and the status text as title.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error returns the detail of the problem or, if it has none, its title.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	if p.Title != "" {
+		return p.Title
+	}
+	return http.StatusText(p.StatusCode())
+}
+
+// StatusCode returns the status of the problem or a 500 if it has none.
+func (p *Problem) StatusCode() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// MarshalJSON encodes the standard members of the problem followed by its
+// extension members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	b, err := json.Marshal((*problem)(p))
+	if err != nil {
+		return nil, err
+	}
+
+	ext := make(map[string]interface{}, len(p.Extensions))
+	for k, v := range p.Extensions {
+		switch k {
+		case "type", "title", "status", "detail", "instance":
+			continue
+		}
+		ext[k] = v
+	}
+	if len(ext) == 0 {
+		return b, nil
+	}
+	eb, err := json.Marshal(ext)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 2 {
+		return eb, nil
+	}
+	// splice the extension members into the standard members' object
+	return append(append(b[:len(b)-1], ','), eb[1:]...), nil
+}
+
+// EncodeProblem will respond with the problem as JSON with the
+// ProblemContentType and the problem's status code.
+func EncodeProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		LogWithFields(r).Error("unable to encode problem response: ", err)
+		p = NewProblem(http.StatusInternalServerError, "")
+		b, _ = json.Marshal(p)
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.StatusCode())
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		LogWithFields(r).Warn("unable to write response: ", err)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblemMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		given *Problem
+		want  string
+	}{
+		{
+			name:  "constructor",
+			given: NewProblem(http.StatusNotFound, "no such cat"),
+			want:  `{"type":"about:blank","title":"Not Found","status":404,"detail":"no such cat"}`,
+		},
+		{
+			name: "all fields",
+			given: &Problem{
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "You do not have enough credit.",
+				Status:   http.StatusForbidden,
+				Detail:   "Your current balance is 30, but that costs 50.",
+				Instance: "/account/12345/msgs/abc",
+				Extensions: map[string]interface{}{
+					"balance":  30,
+					"accounts": []string{"/account/12345", "/account/67890"},
+					"status":   200,
+				},
+			},
+			want: `{"type":"https://example.com/probs/out-of-credit",` +
+				`"title":"You do not have enough credit.","status":403,` +
+				`"detail":"Your current balance is 30, but that costs 50.",` +
+				`"instance":"/account/12345/msgs/abc",` +
+				`"accounts":["/account/12345","/account/67890"],"balance":30}`,
+		},
+		{
+			name:  "only extensions",
+			given: &Problem{Extensions: map[string]interface{}{"retry": true}},
+			want:  `{"retry":true}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := json.Marshal(test.given)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := string(b); got != test.want {
+				t.Errorf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleJSONProblem(t *testing.T) {
+	problem := NewProblem(http.StatusConflict, "cat already exists")
+	problem.Instance = "/cats/tom"
+	problem.Extensions = map[string]interface{}{"name": "tom"}
+
+	tests := []struct {
+		name     string
+		givenErr error
+	}{
+		{name: "problem", givenErr: problem},
+		{name: "wrapped problem", givenErr: fmt.Errorf("load user: %w", problem)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := NewRouter(&Config{})
+			HandleJSON(rt, "PUT", "/cats/tom", func(r *http.Request) (int, interface{}, error) {
+				return http.StatusOK, nil, test.givenErr
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PUT", "/cats/tom", nil)
+			r.Header.Set("Accept", "application/xml")
+			rt.ServeHTTP(w, r)
+
+			if w.Code != http.StatusConflict {
+				t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Errorf("expected Content-Type %q, got %q", ProblemContentType, got)
+			}
+			want := `{"type":"about:blank","title":"Conflict","status":409,"detail":"cat already exists",` +
+				`"instance":"/cats/tom","name":"tom"}` + "\n"
+			if got := w.Body.String(); got != want {
+				t.Errorf("expected body %q, got %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterTTL is how long a client's limiter is kept after its last request.
+var rateLimiterTTL = 3 * time.Minute
+
+// RateLimitMiddleware returns a middleware func that allows each client
+// key rate requests per second with bursts of up to burst requests. Requests
+// over the limit receive a 429 with a Retry-After header. If keyFunc is nil,
+// clients will be keyed by their remote IP address. Servers behind proxies
+// should use ClientIP with their trusted proxies as the keyFunc instead.
+// Requests with an empty key are not limited.
+//
+// The middleware can be applied to a single route via
+// Router.HandleWithMiddleware or to a whole service via Service.Middleware.
+func RateLimitMiddleware(rps, burst int, keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) string {
+			return ClientIP(r, nil)
+		}
+	}
+	limiters := newRateLimiters(rate.Limit(rps), burst, rateLimiterTTL)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if wait, ok := limiters.take(key, time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimiters is a map of token bucket limiters that expire
+// after they have not been used for the given TTL.
+type rateLimiters struct {
+	limit rate.Limit
+	burst int
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	limiters  map[string]*rateLimiter
+	lastSweep time.Time
+}
+
+type rateLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiters(limit rate.Limit, burst int, ttl time.Duration) *rateLimiters {
+	return &rateLimiters{
+		limit:    limit,
+		burst:    burst,
+		ttl:      ttl,
+		limiters: map[string]*rateLimiter{},
+	}
+}
+
+// take will attempt to take a token from the key's bucket. If none are
+// available, it returns how long until one is.
+func (l *rateLimiters) take(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > l.ttl {
+		for k, lim := range l.limiters {
+			if now.Sub(lim.lastSeen) > l.ttl {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	lim, ok := l.limiters[key]
+	if !ok {
+		lim = &rateLimiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = lim
+	}
+	lim.lastSeen = now
+
+	res := lim.ReserveN(now, 1)
+	if !res.OK() {
+		// the burst is 0 so no requests will ever be allowed.
+		return l.ttl, false
+	}
+	if wait := res.DelayFrom(now); wait > 0 {
+		res.CancelAt(now)
+		return wait, false
+	}
+	return 0, true
+}
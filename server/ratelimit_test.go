@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	h := RateLimitMiddleware(1, 3, func(r *http.Request) string {
+		return r.Header.Get("X-Client")
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	do := func(client string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// the burst is allowed
+	for i := 0; i < 3; i++ {
+		if w := do("a"); w.Code != http.StatusOK {
+			t.Fatalf("expected request %d to be allowed, got status %d", i+1, w.Code)
+		}
+	}
+
+	// the sustained rate is throttled
+	w := do("a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After '1', got %q", got)
+	}
+
+	// other keys are limited independently
+	if w := do("b"); w.Code != http.StatusOK {
+		t.Errorf("expected another client to be allowed, got status %d", w.Code)
+	}
+}
+
+func TestRateLimitersExpire(t *testing.T) {
+	limiters := newRateLimiters(1, 1, time.Minute)
+	now := time.Now()
+
+	if _, ok := limiters.take("a", now); !ok {
+		t.Fatal("expected first request to be allowed")
+	}
+	if wait, ok := limiters.take("a", now); ok || wait != time.Second {
+		t.Fatalf("expected second request to wait 1s, got %s, %t", wait, ok)
+	}
+
+	limiters.take("b", now.Add(2*time.Minute))
+	if _, ok := limiters.limiters["a"]; ok {
+		t.Error("expected unused limiter to expire")
+	}
+}
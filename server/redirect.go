@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RedirectMiddleware returns a middleware func that applies the first of the
+// rules whose From pattern matches the request path. Redirect rules respond
+// with their status and a Location built from the To template, keeping the
+// request query string unless the template has its own. Rewrite rules
+// change the request path to the To template and pass the request on, so it
+// is routed to the handler of the new path without a client round-trip.
+// Requests without a matching rule are passed on untouched.
+//
+// SimpleServer applies it to every request if Config.Redirects is set.
+func RedirectMiddleware(rules []RedirectRule) (func(http.Handler) http.Handler, error) {
+	compiled, err := compileRedirects(rules)
+	if err != nil {
+		return nil, err
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range compiled {
+				m := rule.from.FindStringSubmatchIndex(r.URL.Path)
+				if m == nil {
+					continue
+				}
+				to := string(rule.from.ExpandString(nil, rule.To, r.URL.Path, m))
+				path, query := to, r.URL.RawQuery
+				if i := strings.Index(to, "?"); i >= 0 {
+					path, query = to[:i], to[i+1:]
+				}
+
+				if !rule.Rewrite {
+					if query != "" {
+						path += "?" + query
+					}
+					http.Redirect(w, r, path, rule.status())
+					return
+				}
+
+				r2 := new(http.Request)
+				*r2 = *r
+				u := *r.URL
+				u.Path, u.RawPath, u.RawQuery = path, "", query
+				r2.URL = &u
+				r2.RequestURI = u.RequestURI()
+				h.ServeHTTP(w, r2)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+type compiledRedirect struct {
+	RedirectRule
+	from *regexp.Regexp
+}
+
+func (r compiledRedirect) status() int {
+	if r.Status != 0 {
+		return r.Status
+	}
+	if r.Permanent {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusFound
+}
+
+func compileRedirects(rules []RedirectRule) ([]compiledRedirect, error) {
+	compiled := make([]compiledRedirect, len(rules))
+	for i, rule := range rules {
+		from, err := regexp.Compile("^(?:" + rule.From + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect pattern %q: %s", rule.From, err)
+		}
+		if rule.Status != 0 && (rule.Status < 300 || rule.Status > 399) {
+			return nil, fmt.Errorf("invalid redirect status %d for pattern %q, must be a 3xx status",
+				rule.Status, rule.From)
+		}
+		compiled[i] = compiledRedirect{RedirectRule: rule, from: from}
+	}
+	return compiled, nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectMiddleware(t *testing.T) {
+	rt := NewRouter(&Config{})
+	rt.HandleFunc("GET", "/v2/articles/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v2 article " + Vars(r)["id"] + " " + r.URL.RawQuery))
+	})
+	rt.HandleFunc("GET", "/legacy", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("legacy"))
+	})
+
+	mw, err := RedirectMiddleware([]RedirectRule{
+		{From: `/articles/(\d+)`, To: "/v2/articles/$1", Permanent: true},
+		{From: `/old/(?P<id>\d+)`, To: "/v2/articles/${id}", Rewrite: true},
+		{From: `/tmp`, To: "/legacy?from=tmp"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	h := mw(rt)
+
+	tests := []struct {
+		givenURL string
+
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			givenURL:     "/articles/123?page=2",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/v2/articles/123?page=2",
+		},
+		{
+			// patterns must match the whole path
+			givenURL: "/articles/123/comments",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			givenURL: "/old/456?page=3",
+			wantCode: http.StatusOK,
+			wantBody: "v2 article 456 page=3",
+		},
+		{
+			givenURL:     "/tmp?ignored=1",
+			wantCode:     http.StatusFound,
+			wantLocation: "/legacy?from=tmp",
+		},
+		{
+			givenURL: "/legacy",
+			wantCode: http.StatusOK,
+			wantBody: "legacy",
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", test.givenURL, nil))
+		if w.Code != test.wantCode {
+			t.Errorf("%s: expected status %d, got %d", test.givenURL, test.wantCode, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != test.wantLocation {
+			t.Errorf("%s: expected Location %q, got %q", test.givenURL, test.wantLocation, got)
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("%s: expected body %q, got %q", test.givenURL, test.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestRedirectMiddlewareInvalidRules(t *testing.T) {
+	if _, err := RedirectMiddleware([]RedirectRule{{From: "/(unclosed", To: "/"}}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+	if _, err := RedirectMiddleware([]RedirectRule{{From: "/a", To: "/b", Status: 200}}); err == nil {
+		t.Error("expected an error for a non-redirect status")
+	}
+}
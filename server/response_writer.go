@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter is an http.ResponseWriter that keeps track of the status
+// code and number of bytes written so middleware can observe them. It does
+// not implement http.Flusher, http.Hijacker or http.Pusher itself, so it
+// never claims support the underlying writer lacks. Use
+// http.ResponseController to reach them through its Unwrap method.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// NewResponseWriter will wrap the given http.ResponseWriter unless it
+// already is a *ResponseWriter.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// WriteHeader will record the status code and call the underlying WriteHeader.
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write will record the number of bytes written by the underlying Write.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Status returns the status code written to the response or 200 if
+// nothing has been written yet.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// BytesWritten returns the number of bytes written to the response body.
+func (w *ResponseWriter) BytesWritten() int {
+	return w.bytes
+}
+
+// errNotHijacker is returned by Hijack if no writer in the chain
+// implements http.Hijacker.
+var errNotHijacker = errors.New("server: underlying ResponseWriter does not implement http.Hijacker")
+
+// Hijack will take over the connection of the given http.ResponseWriter.
+// Wrapping writers that do not implement http.Hijacker themselves are
+// unwrapped with their Unwrap method until one that does is found. This
+// lets WebSocket upgrades, like gorilla/websocket's Upgrader, work through
+// any middleware that wraps the response. Any *ResponseWriter in the chain
+// records the hijack as a 101 Switching Protocols status.
+func Hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	var rws []*ResponseWriter
+	for {
+		if h, ok := w.(http.Hijacker); ok {
+			conn, buf, err := h.Hijack()
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, rw := range rws {
+				if rw.status == 0 {
+					rw.status = http.StatusSwitchingProtocols
+				}
+			}
+			return conn, buf, nil
+		}
+		if rw, ok := w.(*ResponseWriter); ok {
+			rws = append(rws, rw)
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil, nil, errNotHijacker
+		}
+		w = u.Unwrap()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter for
+// use with http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec)
+	if got := NewResponseWriter(w); got != w {
+		t.Errorf("expected a *ResponseWriter not to be wrapped again")
+	}
+
+	w.Write([]byte("hello "))
+	w.WriteHeader(http.StatusTeapot)
+	w.Write([]byte("world"))
+
+	if got := w.Status(); got != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, got)
+	}
+	if got := w.BytesWritten(); got != 11 {
+		t.Errorf("expected 11 bytes written, got %d", got)
+	}
+
+	var rw http.ResponseWriter = w
+	if _, ok := rw.(http.Flusher); ok {
+		t.Error("expected the writer not to be an http.Flusher")
+	}
+	if _, ok := rw.(http.Hijacker); ok {
+		t.Error("expected the writer not to be an http.Hijacker")
+	}
+	if _, ok := rw.(http.Pusher); ok {
+		t.Error("expected the writer not to be an http.Pusher")
+	}
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		t.Errorf("unexpected error flushing: %s", err)
+	}
+	if !rec.Flushed {
+		t.Errorf("expected Flush to be forwarded to the underlying writer")
+	}
+	if _, _, err := rc.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected Hijack to return http.ErrNotSupported, got %v", err)
+	}
+
+	// a writer that can not be flushed
+	if err := http.NewResponseController(NewResponseWriter(struct{ http.ResponseWriter }{rec})).Flush(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected Flush to return http.ErrNotSupported, got %v", err)
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := NewResponseWriter(w)
+		conn, buf, err := Hijack(rw)
+		if err != nil {
+			t.Errorf("unexpected error hijacking the connection: %s", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+
+		if got := rw.Status(); got != http.StatusSwitchingProtocols {
+			t.Errorf("expected status code %d, got %d", http.StatusSwitchingProtocols, got)
+		}
+	}))
+	defer srv.Close()
+
+	upgrade(t, srv)
+}
+
+func TestHijack(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// two layers of wrapping that do not hijack themselves
+		// around a *ResponseWriter
+		w = unwrapWriter{unwrapWriter{NewResponseWriter(w)}}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("expected the wrapped writer not to be an http.Hijacker")
+			return
+		}
+		conn, buf, err := Hijack(w)
+		if err != nil {
+			t.Errorf("unexpected error hijacking the connection: %s", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+	}))
+	defer srv.Close()
+
+	upgrade(t, srv)
+
+	if _, _, err := Hijack(unwrapWriter{httptest.NewRecorder()}); err == nil {
+		t.Errorf("expected Hijack to return an error for a writer without support")
+	}
+}
+
+// unwrapWriter is a minimal wrapping writer that only exposes Unwrap.
+type unwrapWriter struct {
+	w http.ResponseWriter
+}
+
+func (u unwrapWriter) Header() http.Header         { return u.w.Header() }
+func (u unwrapWriter) Write(b []byte) (int, error) { return u.w.Write(b) }
+func (u unwrapWriter) WriteHeader(status int)      { u.w.WriteHeader(status) }
+func (u unwrapWriter) Unwrap() http.ResponseWriter { return u.w }
+
+// upgrade will send a WebSocket upgrade request to the server and
+// expect a 101 Switching Protocols response.
+func upgrade(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	conn, err := (&net.Dialer{}).Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to dial test server: %s", err)
+	}
+	defer conn.Close()
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("unable to write request: %s", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("unable to read response: %s", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("expected status code %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+}
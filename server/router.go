@@ -1,8 +1,19 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/gorilla/mux"
 )
 
@@ -11,41 +22,357 @@ import (
 type Router interface {
 	Handle(method string, path string, handler http.Handler)
 	HandleFunc(method string, path string, handlerFunc func(http.ResponseWriter, *http.Request))
+	HandleMethods(methods []string, path string, handler http.Handler)
+	// HandleAliases will register the handler on each of the given paths,
+	// so they all behave identically, like `/health` and `/healthz`.
+	HandleAliases(method string, paths []string, handler http.Handler)
+	// HandleWithMiddleware will wrap the handler with the given middleware
+	// before registering it. Middleware runs in declaration order, so the
+	// first one given is the outermost.
+	HandleWithMiddleware(method, path string, handler http.Handler, mw ...func(http.Handler) http.Handler)
+	// Use will add middleware that wraps every handler registered on the
+	// Router afterwards, outside of any per-route middleware. Middleware
+	// runs in the order it was added. Groups inherit the middleware of
+	// their parent at the time they are created, while middleware added to
+	// a group only wraps the group's routes.
+	Use(mw ...func(http.Handler) http.Handler)
+	// HandleWithLimit will register the handler with its own request body
+	// size limit instead of Config.MaxRequestBodyBytes. A limit of 0 or
+	// less disables the limit for the route.
+	HandleWithLimit(method, path string, handler http.Handler, limit int64)
+	// HandleWithTimeout will register the handler with its own timeout
+	// instead of Config.HandlerTimeout. A timeout of 0 or less disables
+	// the timeout for the route.
+	HandleWithTimeout(method, path string, handler http.Handler, timeout time.Duration)
+	// HandleWithCache will register the handler with its own cache policy,
+	// which takes precedence over the CacheControlMiddleware but not over
+	// a Cache-Control header set by the handler itself.
+	HandleWithCache(method, path string, handler http.Handler, policy CachePolicy)
+	// HandleNamed will register the handler and name the route so its URL
+	// can later be built with URL.
+	HandleNamed(name, method, path string, handler http.Handler)
+	// HandleHost will register a handler that only matches requests
+	// for the given host. Routes registered without a host still match
+	// requests for any host. The GorillaRouter matches the host, which
+	// may be a Gorilla host template, against the request's Host
+	// including any port, so it must include a port to match requests
+	// that contain one. The ChiRouter and StdlibRouter compare the host
+	// to the request's Host without its port.
+	HandleHost(host, method, path string, handler http.Handler)
+	// HandleQueries will register a handler that only matches requests
+	// with the given query key/value pairs. Multiple handlers may be
+	// registered on the same path with different queries.
+	HandleQueries(method, path string, handler http.Handler, queryPairs ...string)
+	// HandleHeaders will register a handler that only matches requests
+	// with the given header key/value pairs. An empty value will match any
+	// value. Multiple handlers may be registered on the same path with
+	// different headers and the first matching handler wins.
+	HandleHeaders(method, path string, handler http.Handler, headerPairs ...string)
+	// HandleCatchAll will register a handler for every path under the given
+	// prefix. The rest of the path, including its leading slash, is
+	// available via Vars(r)["filepath"].
+	HandleCatchAll(method, prefix string, handler http.Handler)
+	// Mount will pass requests of any method for the prefix, or any path
+	// under it, to the handler with the prefix stripped from their path.
+	// It is meant for embedding third-party handlers, like a GraphQL
+	// server, that do their own routing.
+	Mount(prefix string, handler http.Handler)
+	// URL will build the path of the route registered with the given name.
+	// The pairs are the route variable names and values, in order.
+	URL(name string, pairs ...string) (string, error)
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 	SetNotFoundHandler(handler http.Handler)
+	SetMethodNotAllowedHandler(handler http.Handler)
+	SetPanicHandler(handler func(http.ResponseWriter, *http.Request, interface{}))
+	// Routes returns the routes registered on the Router and its groups
+	// in the order they were registered.
+	Routes() []RouteInfo
+	// Group returns a Router that will prepend the given prefix to the path
+	// of every route registered with it. The group inherits the settings of
+	// its parent at the time it is created.
+	Group(prefix string) Router
+	// Unwrap returns the underlying router implementation. This is an escape
+	// hatch for configuring backend-specific features that are not covered
+	// by this interface, so callers must type assert the result.
+	Unwrap() interface{}
 }
 
 // NewRouter will return the router specified by the server
 // config. If no Router value is supplied, the server
-// will default to using Gorilla mux.
+// will default to using Gorilla mux. An unknown RouterType, or a 'stdlib'
+// RouterType without the Go 1.22 ServeMux patterns, will also default to
+// Gorilla mux after logging a warning. Use NewRouterStrict to get an error
+// instead.
 func NewRouter(cfg *Config) Router {
+	r, err := NewRouterStrict(cfg)
+	if err != nil {
+		Log.Warn(err, ", defaulting to gorilla")
+		gorilla := *cfg
+		gorilla.RouterType = ""
+		r, _ = NewRouterStrict(&gorilla)
+	}
+	return r
+}
+
+// NewRouterStrict will return the router specified by the server config
+// or an error if the RouterType is not one of the supported values or is
+// 'stdlib' while the ServeMux patterns it relies on are disabled.
+// If no Router value is supplied, the server will default to using
+// Gorilla mux.
+func NewRouterStrict(cfg *Config) (Router, error) {
 	switch cfg.RouterType {
-	case "gorilla":
-		return &GorillaRouter{mux.NewRouter()}
+	case "", "gorilla":
+		return &GorillaRouter{
+			mux:           mux.NewRouter(),
+			autoHEAD:      cfg.AutoHEAD,
+			maxBodyBytes:  cfg.MaxRequestBodyBytes,
+			timeout:       cfg.HandlerTimeout,
+			trailingSlash: cfg.TrailingSlashPolicy,
+			caseFold:      cfg.CaseInsensitivePaths,
+			cors:          cfg.CORS,
+			autoOptions:   cfg.AutoOptions,
+			routes:        &routeTable{},
+			heads:         &[]*mux.Route{},
+		}, nil
+	case "chi":
+		return &ChiRouter{
+			mux:           chi.NewRouter(),
+			autoHEAD:      cfg.AutoHEAD,
+			maxBodyBytes:  cfg.MaxRequestBodyBytes,
+			timeout:       cfg.HandlerTimeout,
+			trailingSlash: cfg.TrailingSlashPolicy,
+			caseFold:      cfg.CaseInsensitivePaths,
+			cors:          cfg.CORS,
+			autoOptions:   cfg.AutoOptions,
+			routes:        &routeTable{},
+			names:         map[string]string{},
+			hosts:         map[string]*ChiRouter{},
+			heads:         map[string]bool{},
+		}, nil
+	case "stdlib":
+		if !stdlibPatternsEnabled() {
+			return nil, errStdlibPatterns
+		}
+		return &StdlibRouter{
+			mux:           http.NewServeMux(),
+			autoHEAD:      cfg.AutoHEAD,
+			maxBodyBytes:  cfg.MaxRequestBodyBytes,
+			timeout:       cfg.HandlerTimeout,
+			trailingSlash: cfg.TrailingSlashPolicy,
+			caseFold:      cfg.CaseInsensitivePaths,
+			cors:          cfg.CORS,
+			autoOptions:   cfg.AutoOptions,
+			routes:        &routeTable{},
+			names:         map[string]string{},
+			fallbacks:     &stdlibFallbacks{},
+		}, nil
 	default:
-		return &GorillaRouter{mux.NewRouter()}
+		return nil, fmt.Errorf("unknown router type %q", cfg.RouterType)
 	}
 }
 
+// Supported values for Config.TrailingSlashPolicy.
+const (
+	// TrailingSlashStrict will only match paths exactly as they
+	// were registered. This is the default.
+	TrailingSlashStrict = "strict"
+	// TrailingSlashRedirect will redirect requests to the registered path
+	// if they only differ from it by a trailing slash.
+	TrailingSlashRedirect = "redirect"
+	// TrailingSlashIgnore will serve requests with the handler of the
+	// registered path if they only differ from it by a trailing slash.
+	TrailingSlashIgnore = "ignore"
+)
+
 // GorillaRouter is a Router implementation for the Gorilla web toolkit's `mux.Router`.
 type GorillaRouter struct {
-	mux *mux.Router
+	mux           *mux.Router
+	autoHEAD      bool
+	maxBodyBytes  int64
+	timeout       time.Duration
+	trailingSlash string
+	caseFold      bool
+	cors          CORSConfig
+	panicHandler  func(http.ResponseWriter, *http.Request, interface{})
+	autoOptions   bool
+	middleware    []func(http.Handler) http.Handler
+	// routes is shared with groups.
+	routes *routeTable
+	// heads holds the routes registered for HEAD requests, which take
+	// precedence over the HEAD requests matched by AutoHEAD, and is shared
+	// with groups.
+	heads *[]*mux.Route
 }
 
 // Handle will call the Gorilla web toolkit's Handle().Method() methods.
 func (g *GorillaRouter) Handle(method, path string, h http.Handler) {
-	g.mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	g.HandleMethods([]string{method}, path, h)
+}
+
+// HandleFunc will call the Gorilla web toolkit's HandleFunc().Method() methods.
+func (g *GorillaRouter) HandleFunc(method, path string, h func(http.ResponseWriter, *http.Request)) {
+	g.Handle(method, path, http.HandlerFunc(h))
+}
+
+// HandleMethods will call the Gorilla web toolkit's Handle().Methods() methods.
+func (g *GorillaRouter) HandleMethods(methods []string, path string, h http.Handler) {
+	g.handle(g.mux.Path(path), methods, h)
+}
+
+// HandleAliases will call Handle for each of the given paths.
+func (g *GorillaRouter) HandleAliases(method string, paths []string, h http.Handler) {
+	for _, path := range paths {
+		g.Handle(method, path, h)
+	}
+}
+
+// HandleNamed will call the Gorilla web toolkit's Handle().Methods().Name() methods.
+func (g *GorillaRouter) HandleNamed(name, method, path string, h http.Handler) {
+	g.handle(g.mux.Path(path), []string{method}, h).Name(name)
+}
+
+// HandleHost will call the Gorilla web toolkit's Host().Path().Methods() methods.
+// The host may be a Gorilla host template and must include a port to match
+// requests that contain one, unlike with the other Routers.
+func (g *GorillaRouter) HandleHost(host, method, path string, h http.Handler) {
+	g.handle(g.mux.Host(host).Path(path), []string{method}, h)
+}
+
+// HandleQueries will call the Gorilla web toolkit's Path().Queries().Methods() methods.
+func (g *GorillaRouter) HandleQueries(method, path string, h http.Handler, queryPairs ...string) {
+	g.handle(g.mux.Path(path).Queries(queryPairs...), []string{method}, h)
+}
+
+// HandleHeaders will call the Gorilla web toolkit's Path().Headers().Methods() methods.
+func (g *GorillaRouter) HandleHeaders(method, path string, h http.Handler, headerPairs ...string) {
+	g.handle(g.mux.Path(path).Headers(headerPairs...), []string{method}, h)
+}
+
+// HandleCatchAll will call the Gorilla web toolkit's Path().Methods() methods
+// with a `{filepath:.*}` variable after the prefix.
+func (g *GorillaRouter) HandleCatchAll(method, prefix string, h http.Handler) {
+	route := g.mux.Path(strings.TrimSuffix(prefix, "/") + "/{filepath:.*}")
+	g.record(route, []string{method}, h)
+	g.register(route, []string{method}, catchAllHandler("filepath", h))
+}
+
+// Mount will call the Gorilla web toolkit's Path() and PathPrefix() methods
+// so the prefix and every path under it reach the handler with the
+// prefix stripped.
+func (g *GorillaRouter) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	exact := g.mux.Path(prefix)
+	tmpl, _ := exact.GetPathTemplate()
+	g.routes.add("*", tmpl, h)
+	h = chainMiddleware(limitBody(g.maxBodyBytes, withTimeout(g.timeout, mountHandler(tmpl, h))), g.middleware...)
+	mounted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recoverWith(g.panicHandler, w, r)
+		setRouteTemplate(r, tmpl+"/*")
+		h.ServeHTTP(w, r)
+	})
+	exact.Handler(mounted)
+	g.mux.PathPrefix(prefix + "/").Handler(mounted)
+}
+
+// URL will call the Gorilla web toolkit's Get().URL() methods.
+func (g *GorillaRouter) URL(name string, pairs ...string) (string, error) {
+	route := g.mux.Get(name)
+	if route == nil {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+	u, err := route.URL(pairs...)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// handle will set the given methods and handler on the route.
+func (g *GorillaRouter) handle(route *mux.Route, methods []string, h http.Handler) *mux.Route {
+	g.record(route, methods, h)
+	return g.register(route, methods, h)
+}
+
+func (g *GorillaRouter) record(route *mux.Route, methods []string, h http.Handler) {
+	path, _ := route.GetPathTemplate()
+	for _, method := range methods {
+		g.routes.add(method, path, h)
+	}
+	if g.isAutoHEAD(methods) {
+		g.routes.add(http.MethodHead, path, h)
+	}
+}
+
+// isAutoHEAD returns true if AutoHEAD should make a route registered for
+// the methods match HEAD requests.
+func (g *GorillaRouter) isAutoHEAD(methods []string) bool {
+	return g.autoHEAD && containsString(methods, http.MethodGet) && !containsString(methods, http.MethodHead)
+}
+
+// matchesHEAD returns true if a route registered for HEAD requests
+// matches the request.
+func (g *GorillaRouter) matchesHEAD(r *http.Request) bool {
+	for _, route := range *g.heads {
+		var match mux.RouteMatch
+		if route.Match(r, &match) && match.MatchErr == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *GorillaRouter) register(route *mux.Route, methods []string, h http.Handler) *mux.Route {
+	h = chainMiddleware(limitBody(g.maxBodyBytes, withTimeout(g.timeout, h)), g.middleware...)
+	if containsString(methods, http.MethodHead) {
+		*g.heads = append(*g.heads, route)
+	}
+	autoHEAD := g.isAutoHEAD(methods)
+	if autoHEAD {
+		methods = append(methods[:len(methods):len(methods)], http.MethodHead)
+		// leave HEAD requests to routes registered for them, even
+		// if they are registered afterwards.
+		route.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+			return r.Method != http.MethodHead || !g.matchesHEAD(r)
+		})
+	}
+	tmpl, _ := route.GetPathTemplate()
+	return route.Methods(methods...).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recoverWith(g.panicHandler, w, r)
+		setRouteTemplate(r, tmpl)
 		// copy the route params into a shared location
 		// duplicating memory, but allowing Gizmo to be more flexible with
 		// router implementations.
 		SetRouteVars(r, mux.Vars(r))
+		if autoHEAD && r.Method == http.MethodHead {
+			w = headResponseWriter{w}
+		}
 		h.ServeHTTP(w, r)
-	})).Methods(method)
+	}))
 }
 
-// HandleFunc will call the Gorilla web toolkit's HandleFunc().Method() methods.
-func (g *GorillaRouter) HandleFunc(method, path string, h func(http.ResponseWriter, *http.Request)) {
-	g.Handle(method, path, http.HandlerFunc(h))
+// Use will add middleware that wraps every handler registered afterwards.
+func (g *GorillaRouter) Use(mw ...func(http.Handler) http.Handler) {
+	g.middleware = append(g.middleware[:len(g.middleware):len(g.middleware)], mw...)
+}
+
+// HandleWithMiddleware will compose the middleware around the handler and call Handle.
+func (g *GorillaRouter) HandleWithMiddleware(method, path string, h http.Handler, mw ...func(http.Handler) http.Handler) {
+	g.Handle(method, path, chainMiddleware(h, mw...))
+}
+
+// HandleWithLimit will call Handle with the handler's own request body size limit.
+func (g *GorillaRouter) HandleWithLimit(method, path string, h http.Handler, limit int64) {
+	g.Handle(method, path, limitedHandler{limit: limit, h: h})
+}
+
+// HandleWithTimeout will call Handle with the handler's own timeout.
+func (g *GorillaRouter) HandleWithTimeout(method, path string, h http.Handler, timeout time.Duration) {
+	g.Handle(method, path, timeoutHandler{timeout: timeout, h: h})
+}
+
+// HandleWithCache will call Handle with the handler's own cache policy.
+func (g *GorillaRouter) HandleWithCache(method, path string, h http.Handler, policy CachePolicy) {
+	g.Handle(method, path, cacheHandler{policy: policy, h: h})
 }
 
 // SetNotFoundHandler will set the Gorilla mux.Router.NotFoundHandler.
@@ -53,7 +380,898 @@ func (g *GorillaRouter) SetNotFoundHandler(h http.Handler) {
 	g.mux.NotFoundHandler = h
 }
 
-// ServeHTTP will call Gorilla mux.Router.ServerHTTP directly.
+// SetMethodNotAllowedHandler will set the Gorilla mux.Router.MethodNotAllowedHandler.
+func (g *GorillaRouter) SetMethodNotAllowedHandler(h http.Handler) {
+	g.mux.MethodNotAllowedHandler = h
+}
+
+// SetPanicHandler will set a func to be called whenever a registered
+// handler panics. The Gorilla mux.Router has no recovery of its own, so every
+// registered handler is wrapped in a deferred recover.
+func (g *GorillaRouter) SetPanicHandler(h func(http.ResponseWriter, *http.Request, interface{})) {
+	g.panicHandler = h
+}
+
+// Group will return a GorillaRouter wrapping a Gorilla mux.Router.PathPrefix().Subrouter().
+func (g *GorillaRouter) Group(prefix string) Router {
+	return &GorillaRouter{
+		mux:          g.mux.PathPrefix(prefix).Subrouter(),
+		autoHEAD:     g.autoHEAD,
+		maxBodyBytes: g.maxBodyBytes,
+		timeout:      g.timeout,
+		panicHandler: g.panicHandler,
+		autoOptions:  g.autoOptions,
+		middleware:   g.middleware,
+		routes:       g.routes,
+		heads:        g.heads,
+	}
+}
+
+// Routes will return the routes registered on the GorillaRouter and its groups.
+func (g *GorillaRouter) Routes() []RouteInfo {
+	return append([]RouteInfo(nil), g.routes.routes...)
+}
+
+// Unwrap will return the underlying *mux.Router.
+func (g *GorillaRouter) Unwrap() interface{} {
+	return g.mux
+}
+
+// ServeHTTP will call Gorilla mux.Router.ServerHTTP directly unless
+// the request is a CORS preflight, an OPTIONS request handled by
+// Config.AutoOptions or is handled by the trailing slash policy or
+// Config.CaseInsensitivePaths.
 func (g *GorillaRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if serveCORS(g.cors, w, r, g.matches) {
+		return
+	}
+	if serveAutoOptions(g.autoOptions, g.routes, w, r, g.matches) {
+		return
+	}
+	if serveTrailingSlash(g.trailingSlash, w, r, g.matches, g.mux) {
+		return
+	}
+	if serveCaseFold(g.caseFold, w, r, g.matches) {
+		return
+	}
 	g.mux.ServeHTTP(w, r)
 }
+
+func (g *GorillaRouter) matches(r *http.Request) bool {
+	var match mux.RouteMatch
+	return g.mux.Match(r, &match) && match.MatchErr == nil
+}
+
+// ChiRouter is a Router implementation for the go-chi `chi.Mux`.
+type ChiRouter struct {
+	mux           *chi.Mux
+	autoHEAD      bool
+	maxBodyBytes  int64
+	timeout       time.Duration
+	trailingSlash string
+	caseFold      bool
+	cors          CORSConfig
+	panicHandler  func(http.ResponseWriter, *http.Request, interface{})
+	autoOptions   bool
+	middleware    []func(http.Handler) http.Handler
+	// routes is shared with groups.
+	routes *routeTable
+
+	// prefix is the full path prefix of a group's routes.
+	prefix string
+	// names maps route names to path templates and is shared with groups.
+	names map[string]string
+	// hosts holds a router for each host given to HandleHost and
+	// is shared with groups.
+	hosts map[string]*ChiRouter
+	// conditions holds the handlers registered for each method and path
+	// via HandleQueries and HandleHeaders.
+	conditions map[string]*conditionalHandler
+	// heads holds the paths with routes registered for HEAD requests and
+	// is shared with groups.
+	heads map[string]bool
+	// groups holds the router mounted for each prefix given to Group so
+	// grouping the same prefix again reuses it.
+	groups map[string]*ChiRouter
+}
+
+// Handle will call the chi Mux.Method() method.
+func (c *ChiRouter) Handle(method, path string, h http.Handler) {
+	c.addRoute(method, path, h)
+	c.handle(method, path, h)
+}
+
+// addRoute will add the route to the route table, along with the HEAD
+// route AutoHEAD adds for it.
+func (c *ChiRouter) addRoute(method, path string, h http.Handler) {
+	c.routes.add(method, c.prefix+path, h)
+	if c.autoHEAD && method == http.MethodGet {
+		c.routes.add(http.MethodHead, c.prefix+path, h)
+	}
+}
+
+func (c *ChiRouter) handle(method, path string, h http.Handler) {
+	switch {
+	case method == http.MethodHead:
+		c.heads[c.prefix+path] = true
+	case c.autoHEAD && method == http.MethodGet && !c.heads[c.prefix+path]:
+		// routes registered for HEAD requests take precedence over
+		// AutoHEAD, even if they were registered first.
+		c.register(http.MethodHead, path, headHandler(h))
+	}
+	c.register(method, path, h)
+}
+
+func (c *ChiRouter) register(method, path string, h http.Handler) {
+	h = chainMiddleware(limitBody(c.maxBodyBytes, withTimeout(c.timeout, h)), c.middleware...)
+	tmpl := c.prefix + path
+	c.mux.Method(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recoverWith(c.panicHandler, w, r)
+		setRouteTemplate(r, tmpl)
+		// copy the route params into a shared location
+		// so they are accessible via Vars(r).
+		if vars := chiVars(r); vars != nil {
+			SetRouteVars(r, vars)
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
+
+// HandleFunc will call the chi Mux.Method() method.
+func (c *ChiRouter) HandleFunc(method, path string, h func(http.ResponseWriter, *http.Request)) {
+	c.Handle(method, path, http.HandlerFunc(h))
+}
+
+// HandleMethods will call the chi Mux.Method() method for each of the given methods.
+func (c *ChiRouter) HandleMethods(methods []string, path string, h http.Handler) {
+	for _, method := range methods {
+		c.Handle(method, path, h)
+	}
+}
+
+// HandleAliases will call Handle for each of the given paths.
+func (c *ChiRouter) HandleAliases(method string, paths []string, h http.Handler) {
+	for _, path := range paths {
+		c.Handle(method, path, h)
+	}
+}
+
+// Use will add middleware that wraps every handler registered afterwards.
+func (c *ChiRouter) Use(mw ...func(http.Handler) http.Handler) {
+	c.middleware = append(c.middleware[:len(c.middleware):len(c.middleware)], mw...)
+}
+
+// HandleWithMiddleware will compose the middleware around the handler and call Handle.
+func (c *ChiRouter) HandleWithMiddleware(method, path string, h http.Handler, mw ...func(http.Handler) http.Handler) {
+	c.Handle(method, path, chainMiddleware(h, mw...))
+}
+
+// HandleWithLimit will call Handle with the handler's own request body size limit.
+func (c *ChiRouter) HandleWithLimit(method, path string, h http.Handler, limit int64) {
+	c.Handle(method, path, limitedHandler{limit: limit, h: h})
+}
+
+// HandleWithTimeout will call Handle with the handler's own timeout.
+func (c *ChiRouter) HandleWithTimeout(method, path string, h http.Handler, timeout time.Duration) {
+	c.Handle(method, path, timeoutHandler{timeout: timeout, h: h})
+}
+
+// HandleWithCache will call Handle with the handler's own cache policy.
+func (c *ChiRouter) HandleWithCache(method, path string, h http.Handler, policy CachePolicy) {
+	c.Handle(method, path, cacheHandler{policy: policy, h: h})
+}
+
+// HandleNamed will call Handle and keep track of the route's path template.
+func (c *ChiRouter) HandleNamed(name, method, path string, h http.Handler) {
+	c.Handle(method, path, h)
+	c.names[name] = c.prefix + path
+}
+
+// HandleHost will register the handler on a separate chi Mux that is
+// selected by ServeHTTP when the request is for the given host. The host
+// is compared to the request's Host without its port.
+func (c *ChiRouter) HandleHost(host, method, path string, h http.Handler) {
+	hr, ok := c.hosts[host]
+	if !ok {
+		hr = &ChiRouter{
+			mux:          chi.NewRouter(),
+			autoHEAD:     c.autoHEAD,
+			maxBodyBytes: c.maxBodyBytes,
+			timeout:      c.timeout,
+			panicHandler: c.panicHandler,
+			autoOptions:  c.autoOptions,
+			routes:       c.routes,
+			names:        c.names,
+			heads:        map[string]bool{},
+		}
+		c.hosts[host] = hr
+	}
+	// the host router is shared with groups, so use the middleware of
+	// the router the route is registered on.
+	hr.middleware = c.middleware
+	hr.Handle(method, c.prefix+path, h)
+}
+
+// HandleQueries will register a handler that checks the request query
+// before calling the handler registered with matching queryPairs. The
+// values of `{name}` queries are added to Vars(r).
+func (c *ChiRouter) HandleQueries(method, path string, h http.Handler, queryPairs ...string) {
+	c.handleCondition(method, path, h, queryMatcher(queryPairs), queryVars(queryPairs))
+}
+
+// HandleHeaders will register a handler that checks the request headers
+// before calling the handler registered with matching headerPairs.
+func (c *ChiRouter) HandleHeaders(method, path string, h http.Handler, headerPairs ...string) {
+	c.handleCondition(method, path, h, headerMatcher(headerPairs), nil)
+}
+
+func (c *ChiRouter) handleCondition(method, path string, h http.Handler, match func(*http.Request) bool, vars func(*http.Request) map[string]string) {
+	if c.conditions == nil {
+		c.conditions = map[string]*conditionalHandler{}
+	}
+	key := method + " " + path
+	ch, ok := c.conditions[key]
+	if !ok {
+		ch = &conditionalHandler{notFound: func() http.Handler { return c.mux.NotFoundHandler() }}
+		c.conditions[key] = ch
+		c.handle(method, path, ch)
+	}
+	ch.add(match, vars, h)
+	c.addRoute(method, path, h)
+}
+
+// HandleCatchAll will call the chi Mux.Method() method with a `/*`
+// wildcard after the prefix.
+func (c *ChiRouter) HandleCatchAll(method, prefix string, h http.Handler) {
+	path := strings.TrimSuffix(prefix, "/") + "/*"
+	c.addRoute(method, path, h)
+	c.handle(method, path, catchAllHandler("*", h))
+}
+
+// Mount will call the chi Mux.Handle() method for the prefix and with a
+// `/*` wildcard after it so every path under it reaches the handler with
+// the prefix stripped.
+func (c *ChiRouter) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	tmpl := c.prefix + prefix
+	c.routes.add("*", tmpl, h)
+	h = chainMiddleware(limitBody(c.maxBodyBytes, withTimeout(c.timeout, mountHandler(tmpl, h))), c.middleware...)
+	mounted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recoverWith(c.panicHandler, w, r)
+		setRouteTemplate(r, tmpl+"/*")
+		h.ServeHTTP(w, r)
+	})
+	c.mux.Handle(prefix, mounted)
+	c.mux.Handle(prefix+"/*", mounted)
+}
+
+// URL will build the path of the named route by substituting
+// the given route variables into its path template.
+func (c *ChiRouter) URL(name string, pairs ...string) (string, error) {
+	tmpl, ok := c.names[name]
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+	return expandPathTemplate(tmpl, pairs...)
+}
+
+// SetNotFoundHandler will call the chi Mux.NotFound() method.
+func (c *ChiRouter) SetNotFoundHandler(h http.Handler) {
+	c.mux.NotFound(h.ServeHTTP)
+}
+
+// SetMethodNotAllowedHandler will call the chi Mux.MethodNotAllowed() method.
+func (c *ChiRouter) SetMethodNotAllowedHandler(h http.Handler) {
+	c.mux.MethodNotAllowed(h.ServeHTTP)
+}
+
+// SetPanicHandler will set a func to be called whenever a registered
+// handler panics.
+func (c *ChiRouter) SetPanicHandler(h func(http.ResponseWriter, *http.Request, interface{})) {
+	c.panicHandler = h
+}
+
+// Group will return a ChiRouter wrapping a new chi Mux mounted at the given
+// prefix, or the one mounted by an earlier call with the same prefix.
+func (c *ChiRouter) Group(prefix string) Router {
+	if c.groups == nil {
+		c.groups = map[string]*ChiRouter{}
+	}
+	g, ok := c.groups[prefix]
+	if !ok {
+		g = &ChiRouter{
+			mux:        chi.NewRouter(),
+			conditions: map[string]*conditionalHandler{},
+			groups:     map[string]*ChiRouter{},
+		}
+		c.mux.Mount(prefix, g.mux)
+		c.groups[prefix] = g
+	}
+	return &ChiRouter{
+		mux:          g.mux,
+		autoHEAD:     c.autoHEAD,
+		maxBodyBytes: c.maxBodyBytes,
+		timeout:      c.timeout,
+		panicHandler: c.panicHandler,
+		autoOptions:  c.autoOptions,
+		middleware:   c.middleware,
+		routes:       c.routes,
+		prefix:       c.prefix + prefix,
+		names:        c.names,
+		hosts:        c.hosts,
+		conditions:   g.conditions,
+		heads:        c.heads,
+		groups:       g.groups,
+	}
+}
+
+// Routes will return the routes registered on the ChiRouter and its groups.
+func (c *ChiRouter) Routes() []RouteInfo {
+	return append([]RouteInfo(nil), c.routes.routes...)
+}
+
+// Unwrap will return the underlying *chi.Mux.
+func (c *ChiRouter) Unwrap() interface{} {
+	return c.mux
+}
+
+// ServeHTTP will call chi Mux.ServeHTTP directly unless a route
+// registered via HandleHost matches the request or the request is
+// a CORS preflight, an OPTIONS request handled by Config.AutoOptions or is
+// handled by the trailing slash policy or Config.CaseInsensitivePaths.
+func (c *ChiRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if serveCORS(c.cors, w, r, c.matches) {
+		return
+	}
+	if serveAutoOptions(c.autoOptions, c.routes, w, r, c.matches) {
+		return
+	}
+	if serveTrailingSlash(c.trailingSlash, w, r, c.matches, http.HandlerFunc(c.serveMux)) {
+		return
+	}
+	if serveCaseFold(c.caseFold, w, r, c.matches) {
+		return
+	}
+	c.serveMux(w, r)
+}
+
+// serveMux will serve the request with the router of its host, if one of
+// the routes registered via HandleHost matches it, or the chi Mux.
+func (c *ChiRouter) serveMux(w http.ResponseWriter, r *http.Request) {
+	if hr := c.hostRouter(r); hr != nil {
+		hr.mux.ServeHTTP(w, r)
+		return
+	}
+	c.mux.ServeHTTP(w, r)
+}
+
+// hostRouter returns the router of the request's host if one of its
+// routes matches the request, or nil.
+func (c *ChiRouter) hostRouter(r *http.Request) *ChiRouter {
+	if hr, ok := c.hosts[requestHost(r)]; ok && hr.mux.Match(chi.NewRouteContext(), r.Method, r.URL.Path) {
+		return hr
+	}
+	return nil
+}
+
+// matches returns true if a route, including the ones registered via
+// HandleHost, matches the request.
+func (c *ChiRouter) matches(r *http.Request) bool {
+	return c.hostRouter(r) != nil || c.mux.Match(chi.NewRouteContext(), r.Method, r.URL.Path)
+}
+
+// chiVars converts the URL params of the current chi route into a map.
+// It returns nil if the route has no params.
+func chiVars(r *http.Request) map[string]string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || len(rctx.URLParams.Keys) == 0 {
+		return nil
+	}
+	vars := make(map[string]string, len(rctx.URLParams.Keys))
+	for i, k := range rctx.URLParams.Keys {
+		vars[k] = rctx.URLParams.Values[i]
+	}
+	return vars
+}
+
+// RouteInfo describes a route registered on a Router.
+type RouteInfo struct {
+	Method string `json:"method"`
+	// Path is the path template of the route, including any group prefix.
+	Path string `json:"path"`
+	// Handler is the name of the func or the type of the handler.
+	Handler string `json:"handler"`
+}
+
+// RoutesHandler will return a handler that responds with the routes
+// registered on the given Router as JSON. It can be used to mount a
+// debug endpoint like `/_routes`.
+func RoutesHandler(rt Router) http.Handler {
+	return JSONToHTTP(func(r *http.Request) (int, interface{}, error) {
+		return http.StatusOK, rt.Routes(), nil
+	})
+}
+
+// routeTable keeps track of the routes registered on a Router and its groups.
+type routeTable struct {
+	routes []RouteInfo
+}
+
+func (t *routeTable) add(method, path string, h http.Handler) {
+	t.routes = append(t.routes, RouteInfo{Method: method, Path: path, Handler: handlerName(h)})
+}
+
+// methods returns the distinct methods of the registered routes.
+func (t *routeTable) methods() []string {
+	var methods []string
+	for _, route := range t.routes {
+		if !containsString(methods, route.Method) {
+			methods = append(methods, route.Method)
+		}
+	}
+	return methods
+}
+
+// handlerName returns the name of the func behind an http.HandlerFunc
+// or the type of any other http.Handler.
+func handlerName(h http.Handler) string {
+	h = unwrapRouteHandler(h, func(http.Handler) bool { return false })
+	if hf, ok := h.(http.HandlerFunc); ok {
+		if fn := runtime.FuncForPC(reflect.ValueOf(hf).Pointer()); fn != nil {
+			return fn.Name()
+		}
+	}
+	return fmt.Sprintf("%T", h)
+}
+
+// serveAutoOptions will respond to OPTIONS requests without a route of their
+// own with an Allow header listing the registered methods that match the
+// request. It returns true if the request was handled.
+func serveAutoOptions(enabled bool, t *routeTable, w http.ResponseWriter, r *http.Request, matches func(*http.Request) bool) bool {
+	if !enabled || r.Method != http.MethodOptions || matches(r) {
+		return false
+	}
+	var allow []string
+	for _, method := range t.methods() {
+		mr := r.WithContext(r.Context())
+		mr.Method = method
+		if matches(mr) {
+			allow = append(allow, method)
+		}
+	}
+	if len(allow) == 0 {
+		return false
+	}
+	w.Header().Set("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// serveTrailingSlash will handle requests that do not match any route but
+// would if a trailing slash was added or removed, according to the given
+// policy. It returns true if the request was handled.
+func serveTrailingSlash(policy string, w http.ResponseWriter, r *http.Request, matches func(*http.Request) bool, next http.Handler) bool {
+	if policy != TrailingSlashRedirect && policy != TrailingSlashIgnore {
+		return false
+	}
+	if r.URL.Path == "/" || matches(r) {
+		return false
+	}
+
+	path := r.URL.Path + "/"
+	if strings.HasSuffix(r.URL.Path, "/") {
+		path = strings.TrimSuffix(r.URL.Path, "/")
+	}
+	r2 := withPath(r, path)
+	if !matches(r2) {
+		return false
+	}
+
+	if policy == TrailingSlashRedirect {
+		redirectPath(w, r, path)
+		return true
+	}
+	next.ServeHTTP(w, r2)
+	return true
+}
+
+// serveCaseFold will redirect requests that do not match any route to the
+// lowercase version of their path if it matches a route. It returns true if
+// the request was handled.
+func serveCaseFold(enabled bool, w http.ResponseWriter, r *http.Request, matches func(*http.Request) bool) bool {
+	if !enabled || matches(r) {
+		return false
+	}
+	path := strings.ToLower(r.URL.Path)
+	if path == r.URL.Path || !matches(withPath(r, path)) {
+		return false
+	}
+	redirectPath(w, r, path)
+	return true
+}
+
+// withPath returns a shallow copy of the request with the given URL path.
+func withPath(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	r2.URL = &u
+	return r2
+}
+
+// redirectPath will redirect the client to the given path, keeping the
+// query intact. As with httprouter, GET requests get a 301 and other
+// methods get a 307 so the method and body are preserved.
+func redirectPath(w http.ResponseWriter, r *http.Request, path string) {
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		code = http.StatusTemporaryRedirect
+	}
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	http.Redirect(w, r, u.RequestURI(), code)
+}
+
+// expandPathTemplate will replace each `{name}`, `{name...}` or `{name:pattern}`
+// segment of the given path template with the matching value from pairs,
+// escaped for use in a path. Values must match the pattern of their variable.
+func expandPathTemplate(tmpl string, pairs ...string) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", errors.New("route variables must be given as name/value pairs")
+	}
+	vals := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		vals[pairs[i]] = pairs[i+1]
+	}
+
+	var out strings.Builder
+	for {
+		start := strings.Index(tmpl, "{")
+		if start < 0 {
+			out.WriteString(tmpl)
+			return out.String(), nil
+		}
+		end := closingBrace(tmpl, start)
+		if end < 0 {
+			return "", fmt.Errorf("unbalanced braces in path template %q", tmpl)
+		}
+
+		name, pattern := tmpl[start+1:end], ""
+		if i := strings.Index(name, ":"); i >= 0 {
+			name, pattern = name[:i], name[i+1:]
+		}
+		wildcard := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+		val, ok := vals[name]
+		if !ok && name != "$" {
+			return "", fmt.Errorf("missing route variable %q", name)
+		}
+		if pattern != "" {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return "", fmt.Errorf("invalid pattern for route variable %q: %s", name, err)
+			}
+			if !re.MatchString(val) {
+				return "", fmt.Errorf("route variable %q must match %q, got %q", name, pattern, val)
+			}
+		}
+		out.WriteString(tmpl[:start])
+		if wildcard {
+			// keep the slashes between the segments of a wildcard
+			segs := strings.Split(val, "/")
+			for i, seg := range segs {
+				segs[i] = url.PathEscape(seg)
+			}
+			out.WriteString(strings.Join(segs, "/"))
+		} else {
+			out.WriteString(url.PathEscape(val))
+		}
+		tmpl = tmpl[end+1:]
+	}
+}
+
+// closingBrace returns the index of the brace closing the one at start,
+// skipping over nested braces like those of a `{id:[0-9]{3}}` pattern, or
+// -1 if it is not closed.
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// conditionalHandler will call the first handler whose match func
+// returns true for the request, with the route variables of its vars func
+// added to Vars(r), or a not found handler if none do.
+type conditionalHandler struct {
+	matchers []func(*http.Request) bool
+	vars     []func(*http.Request) map[string]string
+	handlers []http.Handler
+	notFound func() http.Handler
+}
+
+func (c *conditionalHandler) add(match func(*http.Request) bool, vars func(*http.Request) map[string]string, h http.Handler) {
+	c.matchers = append(c.matchers, match)
+	c.vars = append(c.vars, vars)
+	c.handlers = append(c.handlers, h)
+}
+
+func (c *conditionalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for i, match := range c.matchers {
+		if match(r) {
+			if c.vars[i] != nil {
+				vars := c.vars[i](r)
+				for k, v := range Vars(r) {
+					if _, ok := vars[k]; !ok {
+						vars[k] = v
+					}
+				}
+				SetRouteVars(r, vars)
+			}
+			c.handlers[i].ServeHTTP(w, r)
+			return
+		}
+	}
+	c.notFound().ServeHTTP(w, r)
+}
+
+// queryMatcher returns a func that will check the request's query for the
+// given key/value pairs. A `{name}` value will match any value for the key.
+func queryMatcher(pairs []string) func(*http.Request) bool {
+	if len(pairs)%2 != 0 {
+		panic(fmt.Sprintf("query pairs must be given as key/value pairs, got %q", pairs))
+	}
+	return func(r *http.Request) bool {
+		query := r.URL.Query()
+		for i := 0; i < len(pairs); i += 2 {
+			vals, ok := query[pairs[i]]
+			if !ok {
+				return false
+			}
+			if !isVariable(pairs[i+1]) && !containsString(vals, pairs[i+1]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// queryVars returns a func that will return the values of the request's
+// query for the keys of the given pairs with a `{name}` value, keyed by
+// name, or nil if there are none.
+func queryVars(pairs []string) func(*http.Request) map[string]string {
+	names := map[string]string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if isVariable(pairs[i+1]) {
+			name := pairs[i+1][1 : len(pairs[i+1])-1]
+			if j := strings.Index(name, ":"); j >= 0 {
+				name = name[:j]
+			}
+			names[pairs[i]] = name
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return func(r *http.Request) map[string]string {
+		query := r.URL.Query()
+		vars := make(map[string]string, len(names))
+		for key, name := range names {
+			vars[name] = query.Get(key)
+		}
+		return vars
+	}
+}
+
+// headerMatcher returns a func that will check the request's headers for the
+// given key/value pairs. An empty value will match any value for the key.
+func headerMatcher(pairs []string) func(*http.Request) bool {
+	if len(pairs)%2 != 0 {
+		panic(fmt.Sprintf("header pairs must be given as key/value pairs, got %q", pairs))
+	}
+	return func(r *http.Request) bool {
+		for i := 0; i < len(pairs); i += 2 {
+			vals, ok := r.Header[http.CanonicalHeaderKey(pairs[i])]
+			if !ok {
+				return false
+			}
+			if pairs[i+1] != "" && !containsString(vals, pairs[i+1]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// isVariable returns true if the value is a `{name}` style route variable.
+func isVariable(val string) bool {
+	return strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}")
+}
+
+// limitedHandler will limit the size of the request body to limit bytes,
+// responding with a 413 if the Content-Length is already too large.
+type limitedHandler struct {
+	limit int64
+	h     http.Handler
+}
+
+func (l limitedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if l.limit > 0 {
+		if r.ContentLength > l.limit {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, l.limit)
+		}
+	}
+	l.h.ServeHTTP(w, r)
+}
+
+// limitBody will wrap the handler with the given request body size limit
+// unless it was registered with HandleWithLimit.
+func limitBody(limit int64, h http.Handler) http.Handler {
+	if limit <= 0 || isRouteHandler(h, func(h http.Handler) bool {
+		_, ok := h.(limitedHandler)
+		return ok
+	}) {
+		return h
+	}
+	return limitedHandler{limit: limit, h: h}
+}
+
+// timeoutHandler will respond with a 503 and cancel the request context
+// if the handler takes longer than timeout.
+type timeoutHandler struct {
+	timeout time.Duration
+	h       http.Handler
+}
+
+func (t timeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t.timeout <= 0 {
+		t.h.ServeHTTP(w, r)
+		return
+	}
+	http.TimeoutHandler(t.h, t.timeout, http.StatusText(http.StatusServiceUnavailable)).ServeHTTP(w, r)
+}
+
+// withTimeout will wrap the handler with the given timeout
+// unless it was registered with HandleWithTimeout.
+func withTimeout(timeout time.Duration, h http.Handler) http.Handler {
+	if timeout <= 0 || isRouteHandler(h, func(h http.Handler) bool {
+		_, ok := h.(timeoutHandler)
+		return ok
+	}) {
+		return h
+	}
+	return timeoutHandler{timeout: timeout, h: h}
+}
+
+// isRouteHandler reports whether the handler, or any handler wrapped by
+// the per route limits, matches.
+func isRouteHandler(h http.Handler, match func(http.Handler) bool) bool {
+	return match(unwrapRouteHandler(h, match))
+}
+
+// unwrapRouteHandler will unwrap the per route limits around the handler
+// until the given func matches or the registered handler is reached.
+func unwrapRouteHandler(h http.Handler, match func(http.Handler) bool) http.Handler {
+	for !match(h) {
+		switch rh := h.(type) {
+		case limitedHandler:
+			h = rh.h
+		case timeoutHandler:
+			h = rh.h
+		case cacheHandler:
+			h = rh.h
+		default:
+			return h
+		}
+	}
+	return h
+}
+
+type routeTemplateKey struct{}
+
+// trackRouteTemplate returns a copy of the request that the Router will
+// record the path template of the matched route on. The returned func
+// returns the template after the request has been served, or an empty
+// string if no route matched.
+func trackRouteTemplate(r *http.Request) (*http.Request, func() string) {
+	tmpl := new(string)
+	r = r.WithContext(context.WithValue(r.Context(), routeTemplateKey{}, tmpl))
+	return r, func() string { return *tmpl }
+}
+
+func setRouteTemplate(r *http.Request, tmpl string) {
+	if p, ok := r.Context().Value(routeTemplateKey{}).(*string); ok {
+		*p = tmpl
+	}
+}
+
+// catchAllHandler will set the `filepath` route variable from the
+// backend's own catch-all variable before calling the handler.
+func catchAllHandler(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := Vars(r)
+		vars["filepath"] = "/" + vars[name]
+		SetRouteVars(r, vars)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// mountHandler will strip the prefix from the request path before calling
+// the handler. The prefix itself is passed on as "/".
+func mountHandler(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		if path == "" {
+			path = "/"
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		u.Path, u.RawPath = path, ""
+		r2.URL = &u
+		h.ServeHTTP(w, r2)
+	})
+}
+
+// requestHost returns the host of the request without any port.
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+// headHandler will run the given handler while discarding anything
+// written to the response body. It is used to serve HEAD requests from GET
+// handlers when Config.AutoHEAD is enabled.
+func headHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(headResponseWriter{w}, r)
+	})
+}
+
+// headResponseWriter is an http.ResponseWriter that drops all body writes.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// recoverWith is meant to be deferred by router handlers. If a panic handler
+// has been set, it will recover from any panic and pass the value along.
+// Otherwise, the panic is left alone.
+func recoverWith(ph func(http.ResponseWriter, *http.Request, interface{}), w http.ResponseWriter, r *http.Request) {
+	if ph == nil {
+		return
+	}
+	if x := recover(); x != nil {
+		ph(w, r, x)
+	}
+}
+
+func containsString(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StdlibRouter is a Router implementation for the Stdlib's `http.ServeMux`.
+// It relies on the method and wildcard patterns added to the ServeMux in
+// Go 1.22, which are only enabled for modules declaring `go 1.22` or later
+// (or with GODEBUG=httpmuxgo121=0). NewRouterStrict returns an error when
+// they are disabled.
+//
+// The ServeMux always serves HEAD requests with the GET handler of a route,
+// so Config.AutoHEAD is effectively always enabled. Setting it only makes
+// Routes list the HEAD routes.
+type StdlibRouter struct {
+	mux           *http.ServeMux
+	autoHEAD      bool
+	maxBodyBytes  int64
+	timeout       time.Duration
+	trailingSlash string
+	caseFold      bool
+	cors          CORSConfig
+	panicHandler  func(http.ResponseWriter, *http.Request, interface{})
+	autoOptions   bool
+	middleware    []func(http.Handler) http.Handler
+	// routes is shared with groups.
+	routes *routeTable
+
+	// prefix is the full path prefix of a group's routes.
+	prefix string
+	// names maps route names to path templates and is shared with groups.
+	names map[string]string
+	// fallbacks are shared with groups as they all share the same ServeMux.
+	fallbacks *stdlibFallbacks
+	// conditions holds the handlers registered for each method and path
+	// via HandleQueries and HandleHeaders.
+	conditions map[string]*conditionalHandler
+}
+
+type stdlibFallbacks struct {
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+}
+
+// Handle will call the Stdlib's ServeMux.Handle() method with a
+// "METHOD /path" pattern. Gorilla-style `{name:pattern}` variables are
+// converted to `{name}` wildcards, so their patterns are not enforced.
+// Use a `{name...}` wildcard to match the rest of a path.
+func (s *StdlibRouter) Handle(method, path string, h http.Handler) {
+	s.handle(method, "", path, h)
+}
+
+// HandleHost will call the Stdlib's ServeMux.Handle() method
+// with a "METHOD host/path" pattern. The ServeMux compares the host to
+// the request's Host without its port.
+func (s *StdlibRouter) HandleHost(host, method, path string, h http.Handler) {
+	s.handle(method, host, path, h)
+}
+
+// HandleQueries will register a handler that checks the request query
+// before calling the handler registered with matching queryPairs. The
+// values of `{name}` queries are added to Vars(r).
+func (s *StdlibRouter) HandleQueries(method, path string, h http.Handler, queryPairs ...string) {
+	s.handleCondition(method, path, h, queryMatcher(queryPairs), queryVars(queryPairs))
+}
+
+// HandleHeaders will register a handler that checks the request headers
+// before calling the handler registered with matching headerPairs.
+func (s *StdlibRouter) HandleHeaders(method, path string, h http.Handler, headerPairs ...string) {
+	s.handleCondition(method, path, h, headerMatcher(headerPairs), nil)
+}
+
+func (s *StdlibRouter) handleCondition(method, path string, h http.Handler, match func(*http.Request) bool, vars func(*http.Request) map[string]string) {
+	if s.conditions == nil {
+		s.conditions = map[string]*conditionalHandler{}
+	}
+	key := method + " " + s.prefix + path
+	ch, ok := s.conditions[key]
+	if !ok {
+		ch = &conditionalHandler{notFound: s.notFoundHandler}
+		s.conditions[key] = ch
+		s.register(method, "", path, ch)
+	}
+	ch.add(match, vars, h)
+	s.addRoute(method, stdlibPath(s.prefix+path), h)
+}
+
+// addRoute will add the route to the route table, along with a HEAD route
+// for GET routes, which the ServeMux serves HEAD requests with, if
+// Config.AutoHEAD is set.
+func (s *StdlibRouter) addRoute(method, path string, h http.Handler) {
+	s.routes.add(method, path, h)
+	if s.autoHEAD && method == http.MethodGet {
+		s.routes.add(http.MethodHead, path, h)
+	}
+}
+
+// errStdlibPatterns is returned by NewRouterStrict for the 'stdlib'
+// RouterType when the ServeMux patterns are disabled.
+var errStdlibPatterns = errors.New("the stdlib router requires the Go 1.22 ServeMux patterns, " +
+	"which are disabled by GODEBUG=httpmuxgo121=1 or a go directive before 1.22")
+
+// stdlibPatternsEnabled returns true if the ServeMux supports method and
+// wildcard patterns. Without them, a pattern like `GET /{id}` is registered
+// as a literal path and never matches.
+func stdlibPatternsEnabled() (enabled bool) {
+	defer func() {
+		if recover() != nil {
+			enabled = false
+		}
+	}()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{id}", func(http.ResponseWriter, *http.Request) {})
+	_, pattern := mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/probe"}})
+	return pattern == "GET /{id}"
+}
+
+func (s *StdlibRouter) notFoundHandler() http.Handler {
+	if s.fallbacks.notFound != nil {
+		return s.fallbacks.notFound
+	}
+	return http.NotFoundHandler()
+}
+
+func (s *StdlibRouter) handle(method, host, path string, h http.Handler) {
+	s.addRoute(method, stdlibPath(s.prefix+path), h)
+	s.register(method, host, path, h)
+}
+
+func (s *StdlibRouter) register(method, host, path string, h http.Handler) {
+	h = chainMiddleware(limitBody(s.maxBodyBytes, withTimeout(s.timeout, h)), s.middleware...)
+	path = stdlibPath(s.prefix + path)
+	names := stdlibWildcards(path)
+	s.mux.Handle(method+" "+host+path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recoverWith(s.panicHandler, w, r)
+		setRouteTemplate(r, path)
+		// copy the route params into a shared location
+		// so they are accessible via Vars(r).
+		if len(names) > 0 {
+			vars := make(map[string]string, len(names))
+			for _, name := range names {
+				vars[name] = r.PathValue(name)
+			}
+			SetRouteVars(r, vars)
+		}
+		if method == http.MethodGet && r.Method == http.MethodHead {
+			w = headResponseWriter{w}
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
+
+// HandleFunc will call Handle with the given func.
+func (s *StdlibRouter) HandleFunc(method, path string, h func(http.ResponseWriter, *http.Request)) {
+	s.Handle(method, path, http.HandlerFunc(h))
+}
+
+// HandleMethods will call Handle for each of the given methods.
+func (s *StdlibRouter) HandleMethods(methods []string, path string, h http.Handler) {
+	for _, method := range methods {
+		s.Handle(method, path, h)
+	}
+}
+
+// HandleAliases will call Handle for each of the given paths.
+func (s *StdlibRouter) HandleAliases(method string, paths []string, h http.Handler) {
+	for _, path := range paths {
+		s.Handle(method, path, h)
+	}
+}
+
+// Use will add middleware that wraps every handler registered afterwards.
+func (s *StdlibRouter) Use(mw ...func(http.Handler) http.Handler) {
+	s.middleware = append(s.middleware[:len(s.middleware):len(s.middleware)], mw...)
+}
+
+// HandleWithMiddleware will compose the middleware around the handler and call Handle.
+func (s *StdlibRouter) HandleWithMiddleware(method, path string, h http.Handler, mw ...func(http.Handler) http.Handler) {
+	s.Handle(method, path, chainMiddleware(h, mw...))
+}
+
+// HandleCatchAll will call the Stdlib's ServeMux.Handle() method with a
+// `{filepath...}` wildcard after the prefix.
+func (s *StdlibRouter) HandleCatchAll(method, prefix string, h http.Handler) {
+	path := strings.TrimSuffix(prefix, "/") + "/{filepath...}"
+	s.addRoute(method, s.prefix+path, h)
+	s.register(method, "", path, catchAllHandler("filepath", h))
+}
+
+// Mount will call the Stdlib's ServeMux.Handle() method for the prefix and
+// with a `{mountpath...}` wildcard after it, for any method, so every path
+// under it reaches the handler with the prefix stripped.
+func (s *StdlibRouter) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(s.prefix+prefix, "/")
+	s.routes.add("*", prefix, h)
+	h = chainMiddleware(limitBody(s.maxBodyBytes, withTimeout(s.timeout, mountHandler(prefix, h))), s.middleware...)
+	mounted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recoverWith(s.panicHandler, w, r)
+		setRouteTemplate(r, prefix+"/*")
+		h.ServeHTTP(w, r)
+	})
+	s.mux.Handle(prefix, mounted)
+	s.mux.Handle(prefix+"/{mountpath...}", mounted)
+}
+
+// HandleWithLimit will call Handle with the handler's own request body size limit.
+func (s *StdlibRouter) HandleWithLimit(method, path string, h http.Handler, limit int64) {
+	s.Handle(method, path, limitedHandler{limit: limit, h: h})
+}
+
+// HandleWithTimeout will call Handle with the handler's own timeout.
+func (s *StdlibRouter) HandleWithTimeout(method, path string, h http.Handler, timeout time.Duration) {
+	s.Handle(method, path, timeoutHandler{timeout: timeout, h: h})
+}
+
+// HandleWithCache will call Handle with the handler's own cache policy.
+func (s *StdlibRouter) HandleWithCache(method, path string, h http.Handler, policy CachePolicy) {
+	s.Handle(method, path, cacheHandler{policy: policy, h: h})
+}
+
+// HandleNamed will call Handle and keep track of the route's path template.
+func (s *StdlibRouter) HandleNamed(name, method, path string, h http.Handler) {
+	s.Handle(method, path, h)
+	s.names[name] = s.prefix + path
+}
+
+// URL will build the path of the named route by substituting
+// the given route variables into its path template.
+func (s *StdlibRouter) URL(name string, pairs ...string) (string, error) {
+	tmpl, ok := s.names[name]
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+	return expandPathTemplate(tmpl, pairs...)
+}
+
+// SetNotFoundHandler will set the handler used when no pattern matches.
+// Groups share this handler with their parent.
+func (s *StdlibRouter) SetNotFoundHandler(h http.Handler) {
+	s.fallbacks.notFound = h
+}
+
+// SetMethodNotAllowedHandler will set the handler used when a pattern
+// matches the path but not the method. Groups share this handler with
+// their parent.
+func (s *StdlibRouter) SetMethodNotAllowedHandler(h http.Handler) {
+	s.fallbacks.methodNotAllowed = h
+}
+
+// SetPanicHandler will set a func to be called whenever a registered
+// handler panics.
+func (s *StdlibRouter) SetPanicHandler(h func(http.ResponseWriter, *http.Request, interface{})) {
+	s.panicHandler = h
+}
+
+// Group will return a StdlibRouter that registers its routes on the same
+// ServeMux with the given prefix.
+func (s *StdlibRouter) Group(prefix string) Router {
+	return &StdlibRouter{
+		mux:           s.mux,
+		autoHEAD:      s.autoHEAD,
+		maxBodyBytes:  s.maxBodyBytes,
+		timeout:       s.timeout,
+		trailingSlash: s.trailingSlash,
+		caseFold:      s.caseFold,
+		cors:          s.cors,
+		panicHandler:  s.panicHandler,
+		autoOptions:   s.autoOptions,
+		middleware:    s.middleware,
+		routes:        s.routes,
+		prefix:        s.prefix + prefix,
+		names:         s.names,
+		fallbacks:     s.fallbacks,
+	}
+}
+
+// Routes will return the routes registered on the StdlibRouter and its groups.
+func (s *StdlibRouter) Routes() []RouteInfo {
+	return append([]RouteInfo(nil), s.routes.routes...)
+}
+
+// Unwrap will return the underlying *http.ServeMux.
+func (s *StdlibRouter) Unwrap() interface{} {
+	return s.mux
+}
+
+// ServeHTTP will call the Stdlib's ServeMux.ServeHTTP directly unless
+// the request is a CORS preflight, an OPTIONS request handled by
+// Config.AutoOptions, is handled by the trailing slash policy or
+// Config.CaseInsensitivePaths, or no pattern matched and a custom not found
+// or method not allowed handler has been set.
+func (s *StdlibRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if serveCORS(s.cors, w, r, s.matches) {
+		return
+	}
+	if serveAutoOptions(s.autoOptions, s.routes, w, r, s.matches) {
+		return
+	}
+	if serveTrailingSlash(s.trailingSlash, w, r, s.matches, http.HandlerFunc(s.serveHTTP)) {
+		return
+	}
+	if serveCaseFold(s.caseFold, w, r, s.matches) {
+		return
+	}
+	s.serveHTTP(w, r)
+}
+
+func (s *StdlibRouter) matches(r *http.Request) bool {
+	_, pattern := s.mux.Handler(r)
+	return pattern != "" && !slashRedirect(r, pattern)
+}
+
+// slashRedirect reports whether the ServeMux would redirect the request
+// to the matched pattern by appending a trailing slash to its path.
+func slashRedirect(r *http.Request, pattern string) bool {
+	pattern = strings.TrimSuffix(pattern, "{$}")
+	return strings.HasSuffix(pattern, "/") && !strings.HasSuffix(r.URL.Path, "/")
+}
+
+func (s *StdlibRouter) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern := s.mux.Handler(r)
+	if slashRedirect(r, pattern) {
+		// routes only match their exact path, like in the other
+		// Router implementations.
+		s.notFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	if pattern != "" || (s.fallbacks.notFound == nil && s.fallbacks.methodNotAllowed == nil) {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// the ServeMux does not expose why nothing was matched, so capture
+	// the status of its own fallback handler.
+	cw := &statusCaptureWriter{header: http.Header{}}
+	h.ServeHTTP(cw, r)
+
+	switch {
+	case cw.status == http.StatusNotFound && s.fallbacks.notFound != nil:
+		s.fallbacks.notFound.ServeHTTP(w, r)
+	case cw.status == http.StatusMethodNotAllowed && s.fallbacks.methodNotAllowed != nil:
+		w.Header().Set("Allow", cw.header.Get("Allow"))
+		s.fallbacks.methodNotAllowed.ServeHTTP(w, r)
+	default:
+		s.mux.ServeHTTP(w, r)
+	}
+}
+
+// statusCaptureWriter is an http.ResponseWriter that only keeps
+// track of the headers and status code written to it.
+type statusCaptureWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *statusCaptureWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *statusCaptureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *statusCaptureWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+// stdlibPath will convert any Gorilla-style `{name:pattern}`
+// variables in the path into ServeMux `{name}` wildcards. Paths ending
+// in a slash get a `{$}` suffix so they match exactly, like in the other
+// Router implementations, instead of matching the entire subtree.
+func stdlibPath(path string) string {
+	if strings.HasSuffix(path, "/") {
+		path += "{$}"
+	}
+	var out strings.Builder
+	for {
+		start := strings.Index(path, "{")
+		end := -1
+		if start >= 0 {
+			end = closingBrace(path, start)
+		}
+		if end < 0 {
+			out.WriteString(path)
+			return out.String()
+		}
+		name := path[start+1 : end]
+		if i := strings.Index(name, ":"); i >= 0 {
+			name = name[:i]
+		}
+		out.WriteString(path[:start] + "{" + name + "}")
+		path = path[end+1:]
+	}
+}
+
+// stdlibWildcards returns the names of the wildcards in a ServeMux path.
+func stdlibWildcards(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(seg[1:len(seg)-1], "...")
+		if name == "$" {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
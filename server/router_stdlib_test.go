@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestStdlibRouterLegacyMux(t *testing.T) {
+	if os.Getenv("GIZMO_TEST_LEGACY_MUX") == "1" {
+		if _, err := NewRouterStrict(&Config{RouterType: "stdlib"}); err != errStdlibPatterns {
+			t.Fatalf("expected errStdlibPatterns, got %v", err)
+		}
+		if rt := NewRouter(&Config{RouterType: "stdlib"}); rt == nil {
+			t.Fatal("expected NewRouter to fall back to another router")
+		} else if _, ok := rt.(*GorillaRouter); !ok {
+			t.Fatalf("expected NewRouter to fall back to a *GorillaRouter, got %T", rt)
+		}
+		return
+	}
+
+	// the ServeMux mode is fixed at start up, so run the test in a
+	// process with the legacy patterns.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdlibRouterLegacyMux$")
+	cmd.Env = append(os.Environ(), "GIZMO_TEST_LEGACY_MUX=1", "GODEBUG=httpmuxgo121=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("legacy mux test failed: %s\n%s", err, out)
+	}
+}
+
+func TestStdlibRouter(t *testing.T) {
+	rt := NewRouter(&Config{RouterType: "stdlib"})
+	if _, ok := rt.(*StdlibRouter); !ok {
+		t.Fatalf("expected RouterType 'stdlib' to produce a *StdlibRouter, got %T", rt)
+	}
+
+	rt.HandleFunc("GET", "/users/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user-" + Vars(r)["id"]))
+	})
+	rt.HandleFunc("PUT", "/users/{id}/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Vars(r)["id"] + ":" + Vars(r)["path"]))
+	})
+	rt.SetNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("nope"))
+	}))
+
+	tests := []struct {
+		givenMethod string
+		givenPath   string
+
+		wantCode  int
+		wantBody  string
+		wantAllow string
+	}{
+		{http.MethodGet, "/users/42", http.StatusOK, "user-42", ""},
+		{http.MethodHead, "/users/42", http.StatusOK, "", ""},
+		{http.MethodPut, "/users/42/files/a/b.txt", http.StatusOK, "42:a/b.txt", ""},
+		{http.MethodPost, "/users/42", http.StatusMethodNotAllowed, "", "GET, HEAD"},
+		{http.MethodGet, "/people/42", http.StatusNotFound, "nope", ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(test.givenMethod, test.givenPath, nil))
+
+		if w.Code != test.wantCode {
+			t.Errorf("%s %s: expected status code %d, got %d", test.givenMethod, test.givenPath, test.wantCode, w.Code)
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("%s %s: expected response body to be %q, got %q", test.givenMethod, test.givenPath, test.wantBody, w.Body.String())
+		}
+		if test.givenMethod == http.MethodHead && w.Body.Len() != 0 {
+			t.Errorf("%s %s: expected empty response body, got %q", test.givenMethod, test.givenPath, w.Body.String())
+		}
+		if got := w.Header().Get("Allow"); got != test.wantAllow {
+			t.Errorf("%s %s: expected Allow header to be %q, got %q", test.givenMethod, test.givenPath, test.wantAllow, got)
+		}
+	}
+}
@@ -1,11 +1,23 @@
 package server
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/mux"
 )
 
+// routerTypes are all of the RouterType values supported by NewRouter.
+var routerTypes = []string{"gorilla", "chi", "stdlib"}
+
 func TestGorillaRoute(t *testing.T) {
 	cfg := &Config{HealthCheckType: "simple", HealthCheckPath: "/status"}
 	srvr := NewSimpleServer(cfg)
@@ -27,3 +39,1172 @@ func TestGorillaRoute(t *testing.T) {
 		t.Errorf("Fast route expected response body to be %q, got %q", wantBody, gotBody)
 	}
 }
+
+func TestVars(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Vars(r)["id"]))
+	}
+
+	gr := NewRouter(&Config{})
+	gr.HandleFunc("GET", "/users/{id}", handler)
+
+	mr := mux.NewRouter()
+	mr.HandleFunc("/users/{id}", handler)
+
+	tests := []struct {
+		name  string
+		given http.Handler
+	}{
+		{"gizmo router", gr},
+		{"gorilla router", mr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			test.given.ServeHTTP(w, r)
+
+			if gotBody := w.Body.String(); gotBody != "42" {
+				t.Errorf("expected response body to be %q, got %q", "42", gotBody)
+			}
+		})
+	}
+
+	if got := Vars(httptest.NewRequest(http.MethodGet, "/", nil)); got == nil || len(got) != 0 {
+		t.Errorf("expected empty vars for an unrouted request, got %#v", got)
+	}
+}
+
+func TestChiRouter(t *testing.T) {
+	cfg := &Config{RouterType: "chi"}
+	rt := NewRouter(cfg)
+	if _, ok := rt.(*ChiRouter); !ok {
+		t.Fatalf("expected RouterType 'chi' to produce a *ChiRouter, got %T", rt)
+	}
+
+	rt.HandleFunc("GET", "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Vars(r)["id"]))
+	})
+	rt.SetNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("nope"))
+	}))
+
+	tests := []struct {
+		givenPath string
+
+		wantCode int
+		wantBody string
+	}{
+		{"/users/42", http.StatusOK, "42"},
+		{"/users", http.StatusNotFound, "nope"},
+		{"/users/42/blah", http.StatusNotFound, "nope"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, test.givenPath, nil)
+		rt.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Errorf("%s: expected status code %d, got %d", test.givenPath, test.wantCode, w.Code)
+		}
+		if gotBody := w.Body.String(); gotBody != test.wantBody {
+			t.Errorf("%s: expected response body to be %q, got %q", test.givenPath, test.wantBody, gotBody)
+		}
+	}
+}
+
+func TestRouterUnwrap(t *testing.T) {
+	if got, ok := NewRouter(&Config{RouterType: "gorilla"}).Unwrap().(*mux.Router); !ok || got == nil {
+		t.Errorf("expected gorilla router to unwrap into a *mux.Router, got %T", got)
+	}
+	if got, ok := NewRouter(&Config{RouterType: "chi"}).Unwrap().(*chi.Mux); !ok || got == nil {
+		t.Errorf("expected chi router to unwrap into a *chi.Mux, got %T", got)
+	}
+	if got, ok := NewRouter(&Config{RouterType: "stdlib"}).Unwrap().(*http.ServeMux); !ok || got == nil {
+		t.Errorf("expected stdlib router to unwrap into a *http.ServeMux, got %T", got)
+	}
+}
+
+func TestHandleMethods(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.HandleMethods([]string{"GET", "POST"}, "/multi", http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(r.Method))
+				}))
+
+			tests := []struct {
+				givenMethod string
+
+				wantCode int
+				wantBody string
+			}{
+				{http.MethodGet, http.StatusOK, "GET"},
+				{http.MethodPost, http.StatusOK, "POST"},
+				{http.MethodDelete, http.StatusMethodNotAllowed, ""},
+			}
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(test.givenMethod, "/multi", nil)
+				rt.ServeHTTP(w, r)
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s: expected status code %d, got %d", test.givenMethod, test.wantCode, w.Code)
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%s: expected response body to be %q, got %q", test.givenMethod, test.wantBody, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAutoHEAD(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType, AutoHEAD: true})
+			rt.HandleFunc("GET", "/thing", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Thing", "yup")
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte("a body"))
+			})
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/thing", nil))
+
+			if w.Code != http.StatusAccepted {
+				t.Errorf("expected status code %d, got %d", http.StatusAccepted, w.Code)
+			}
+			if got := w.Header().Get("X-Thing"); got != "yup" {
+				t.Errorf("expected X-Thing header to be %q, got %q", "yup", got)
+			}
+			if got := w.Body.String(); got != "" {
+				t.Errorf("expected empty response body, got %q", got)
+			}
+
+			w = httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/thing", nil))
+			if got := w.Body.String(); got != "a body" {
+				t.Errorf("expected GET response body to be %q, got %q", "a body", got)
+			}
+		})
+	}
+
+	rt := NewRouter(&Config{})
+	rt.HandleFunc("GET", "/thing", func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/thing", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected HEAD without AutoHEAD to return %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestAutoHEADExplicitRoute(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType, AutoHEAD: true, AutoOptions: true})
+			respond := func(header string) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Handler", header)
+				})
+			}
+			rt.Handle("GET", "/before", respond("get"))
+			rt.Handle("HEAD", "/before", respond("head"))
+			rt.Handle("HEAD", "/after", respond("head"))
+			rt.Handle("GET", "/after", respond("get"))
+			rt.Handle("GET", "/implicit", respond("get"))
+
+			tests := []struct {
+				givenPath string
+
+				wantHandler string
+			}{
+				{"/before", "head"},
+				{"/after", "head"},
+				{"/implicit", "get"},
+			}
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, test.givenPath, nil))
+				if got := w.Header().Get("X-Handler"); got != test.wantHandler {
+					t.Errorf("%s: expected the %q handler, got %q", test.givenPath, test.wantHandler, got)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/implicit", nil))
+			if got, want := w.Header().Get("Allow"), "GET, HEAD, OPTIONS"; got != want {
+				t.Errorf("expected an Allow header of %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestSetMethodNotAllowedHandler(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.HandleFunc("GET", "/thing", func(w http.ResponseWriter, r *http.Request) {})
+			rt.SetMethodNotAllowedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", JSONContentType)
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				w.Write([]byte(`{"error":"method not allowed"}`))
+			}))
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/thing", nil))
+
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("expected status code %d, got %d", http.StatusMethodNotAllowed, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != JSONContentType {
+				t.Errorf("expected Content-Type header to be %q, got %q", JSONContentType, got)
+			}
+			if got, want := w.Body.String(), `{"error":"method not allowed"}`; got != want {
+				t.Errorf("expected response body to be %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestSetPanicHandler(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.HandleFunc("GET", "/boom", func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			})
+
+			var gotPanic interface{}
+			rt.SetPanicHandler(func(w http.ResponseWriter, r *http.Request, x interface{}) {
+				gotPanic = x
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+			}
+			if gotPanic != "boom" {
+				t.Errorf("expected panic handler to receive %q, got %#v", "boom", gotPanic)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			v1 := rt.Group("/v1")
+			v1.HandleFunc("GET", "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("v1-" + Vars(r)["id"]))
+			})
+			rt.HandleFunc("GET", "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("root-" + Vars(r)["id"]))
+			})
+
+			tests := []struct {
+				givenPath string
+
+				wantCode int
+				wantBody string
+			}{
+				{"/v1/users/1", http.StatusOK, "v1-1"},
+				{"/users/2", http.StatusOK, "root-2"},
+				{"/v2/users/3", http.StatusNotFound, ""},
+			}
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.givenPath, nil))
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s: expected status code %d, got %d", test.givenPath, test.wantCode, w.Code)
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%s: expected response body to be %q, got %q", test.givenPath, test.wantBody, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestGroupSamePrefix(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.Group("/v1").HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("users"))
+			})
+			rt.Group("/v1").HandleFunc("GET", "/posts", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("posts"))
+			})
+
+			for _, path := range []string{"/v1/users", "/v1/posts"} {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+				if w.Code != http.StatusOK {
+					t.Errorf("%s: expected status code %d, got %d", path, http.StatusOK, w.Code)
+				}
+				if want := strings.TrimPrefix(path, "/v1/"); w.Body.String() != want {
+					t.Errorf("%s: expected response body to be %q, got %q", path, want, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestHandleWithMiddleware(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			var got []string
+			record := func(name string) func(http.Handler) http.Handler {
+				return func(h http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						got = append(got, name)
+						h.ServeHTTP(w, r)
+					})
+				}
+			}
+			deny := func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("Authorization") == "" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.HandleWithMiddleware("GET", "/thing", http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					got = append(got, "handler")
+				}), record("first"), record("second"), deny)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/thing", nil)
+			r.Header.Set("Authorization", "yes")
+			rt.ServeHTTP(w, r)
+
+			want := []string{"first", "second", "handler"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected execution order %v, got %v", want, got)
+			}
+
+			got = nil
+			w = httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/thing", nil))
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+			}
+			want = []string{"first", "second"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected execution order %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestNamedRoutes(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			rt.HandleNamed("user", "GET", "/users/{id}", noop)
+			rt.HandleNamed("item", "GET", "/users/{id}/items/{item:[0-9]+}", noop)
+			rt.Group("/v1").HandleNamed("v1-user", "GET", "/users/{id}", noop)
+			rt.HandleNamed("code", "GET", "/codes/{code:[0-9]{3}}", noop)
+
+			tests := []struct {
+				givenName  string
+				givenPairs []string
+
+				want    string
+				wantErr bool
+			}{
+				{"user", []string{"id", "42"}, "/users/42", false},
+				{"item", []string{"id", "42", "item", "7"}, "/users/42/items/7", false},
+				{"v1-user", []string{"id", "42"}, "/v1/users/42", false},
+				{"user", []string{"id", "a b?"}, "/users/a%20b%3F", false},
+				{"code", []string{"code", "404"}, "/codes/404", false},
+				{"code", []string{"code", "4040"}, "", true},
+				{"item", []string{"id", "42", "item", "seven"}, "", true},
+				{"user", nil, "", true},
+				{"nope", []string{"id", "42"}, "", true},
+			}
+
+			for _, test := range tests {
+				got, err := rt.URL(test.givenName, test.givenPairs...)
+				if test.wantErr {
+					if err == nil {
+						t.Errorf("%s: expected an error, got %q", test.givenName, got)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("%s: unexpected error: %s", test.givenName, err)
+				}
+				if got != test.want {
+					t.Errorf("%s: expected URL %q, got %q", test.givenName, test.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHost(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			respond := func(body string) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(body))
+				})
+			}
+			rt.HandleHost("api.example.com", "GET", "/thing", respond("api"))
+			rt.HandleHost("admin.example.com", "GET", "/thing", respond("admin"))
+			rt.Handle("GET", "/thing", respond("any"))
+
+			tests := []struct {
+				givenHost string
+
+				wantBody string
+			}{
+				{"api.example.com", "api"},
+				{"admin.example.com", "admin"},
+				{"www.example.com", "any"},
+			}
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/thing", nil)
+				r.Host = test.givenHost
+				rt.ServeHTTP(w, r)
+
+				if got := w.Body.String(); got != test.wantBody {
+					t.Errorf("%s: expected response body to be %q, got %q", test.givenHost, test.wantBody, got)
+				}
+			}
+
+			// only the GorillaRouter needs the port in the host
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/thing", nil)
+			r.Host = "api.example.com:8080"
+			rt.ServeHTTP(w, r)
+			want := "api"
+			if routerType == "gorilla" {
+				want = "any"
+			}
+			if got := w.Body.String(); got != want {
+				t.Errorf("%s: expected response body to be %q, got %q", r.Host, want, got)
+			}
+		})
+	}
+}
+
+func TestHandleHostOnlyRoutes(t *testing.T) {
+	tests := []struct {
+		givenMethod string
+		givenHost   string
+		givenPath   string
+
+		wantCode     int
+		wantLocation string
+		wantAllow    string
+	}{
+		{http.MethodGet, "api.example.com", "/widgets/", http.StatusMovedPermanently, "/widgets", ""},
+		{http.MethodOptions, "api.example.com", "/widgets", http.StatusNoContent, "", "GET, OPTIONS"},
+		{http.MethodGet, "www.example.com", "/widgets/", http.StatusNotFound, "", ""},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType, TrailingSlashPolicy: TrailingSlashRedirect, AutoOptions: true})
+			rt.HandleHost("api.example.com", "GET", "/widgets", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("widgets"))
+			}))
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(test.givenMethod, test.givenPath, nil)
+				r.Host = test.givenHost
+				rt.ServeHTTP(w, r)
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s %s%s: expected status code %d, got %d", test.givenMethod, test.givenHost, test.givenPath, test.wantCode, w.Code)
+				}
+				if got := w.Header().Get("Location"); got != test.wantLocation {
+					t.Errorf("%s %s%s: expected Location header to be %q, got %q", test.givenMethod, test.givenHost, test.givenPath, test.wantLocation, got)
+				}
+				if got := w.Header().Get("Allow"); got != test.wantAllow {
+					t.Errorf("%s %s%s: expected Allow header to be %q, got %q", test.givenMethod, test.givenHost, test.givenPath, test.wantAllow, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleQueries(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			respond := func(body string) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(body))
+				})
+			}
+			rt.HandleQueries("GET", "/report", respond("export"), "action", "export")
+			rt.HandleQueries("GET", "/report", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("preview-" + Vars(r)["page"]))
+			}), "action", "preview", "page", "{page}")
+			rt.HandleQueries("GET", "/reports/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(Vars(r)["id"] + "-" + Vars(r)["format"]))
+			}), "format", "{format}")
+
+			tests := []struct {
+				givenURL string
+
+				wantCode int
+				wantBody string
+			}{
+				{"/report?action=export", http.StatusOK, "export"},
+				{"/report?action=preview&page=2", http.StatusOK, "preview-2"},
+				{"/reports/7?format=csv", http.StatusOK, "7-csv"},
+				{"/report?action=preview", http.StatusNotFound, ""},
+				{"/report?action=delete", http.StatusNotFound, ""},
+				{"/report", http.StatusNotFound, ""},
+			}
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.givenURL, nil))
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s: expected status code %d, got %d", test.givenURL, test.wantCode, w.Code)
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%s: expected response body to be %q, got %q", test.givenURL, test.wantBody, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHeaders(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			respond := func(body string) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(body))
+				})
+			}
+			rt.HandleHeaders("GET", "/thing", respond("v2"), "Accept", "application/vnd.v2+json")
+			rt.HandleHeaders("GET", "/thing", respond("v1"))
+			rt.HandleHeaders("GET", "/other", respond("versioned"), "X-Api-Version", "")
+
+			tests := []struct {
+				givenPath    string
+				givenHeaders map[string]string
+
+				wantCode int
+				wantBody string
+			}{
+				{"/thing", map[string]string{"Accept": "application/vnd.v2+json"}, http.StatusOK, "v2"},
+				{"/thing", map[string]string{"Accept": "application/json"}, http.StatusOK, "v1"},
+				{"/thing", nil, http.StatusOK, "v1"},
+				{"/other", map[string]string{"X-Api-Version": "3"}, http.StatusOK, "versioned"},
+				{"/other", nil, http.StatusNotFound, ""},
+			}
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, test.givenPath, nil)
+				for k, v := range test.givenHeaders {
+					r.Header.Set(k, v)
+				}
+				rt.ServeHTTP(w, r)
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s %v: expected status code %d, got %d", test.givenPath, test.givenHeaders, test.wantCode, w.Code)
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%s %v: expected response body to be %q, got %q", test.givenPath, test.givenHeaders, test.wantBody, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	tests := []struct {
+		givenPolicy string
+		givenMethod string
+		givenURL    string
+
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{"", http.MethodGet, "/users/", http.StatusNotFound, "", ""},
+		{TrailingSlashStrict, http.MethodGet, "/users/", http.StatusNotFound, "", ""},
+		{TrailingSlashStrict, http.MethodGet, "/users", http.StatusOK, "users", ""},
+		{TrailingSlashRedirect, http.MethodGet, "/users/?page=2", http.StatusMovedPermanently, "", "/users?page=2"},
+		{TrailingSlashRedirect, http.MethodPost, "/users/", http.StatusTemporaryRedirect, "", "/users"},
+		{TrailingSlashRedirect, http.MethodGet, "/items", http.StatusMovedPermanently, "", "/items/"},
+		{TrailingSlashRedirect, http.MethodGet, "/nope/", http.StatusNotFound, "", ""},
+		{TrailingSlashIgnore, http.MethodGet, "/users/", http.StatusOK, "users", ""},
+		{TrailingSlashIgnore, http.MethodGet, "/items", http.StatusOK, "items", ""},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			for _, test := range tests {
+				rt := NewRouter(&Config{RouterType: routerType, TrailingSlashPolicy: test.givenPolicy})
+				rt.HandleMethods([]string{"GET", "POST"}, "/users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("users"))
+				}))
+				rt.HandleFunc("GET", "/items/", func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("items"))
+				})
+
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(test.givenMethod, test.givenURL, nil))
+
+				if w.Code != test.wantCode {
+					t.Errorf("%q %s %s: expected status code %d, got %d", test.givenPolicy, test.givenMethod, test.givenURL, test.wantCode, w.Code)
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%q %s %s: expected response body to be %q, got %q", test.givenPolicy, test.givenMethod, test.givenURL, test.wantBody, w.Body.String())
+				}
+				if got := w.Header().Get("Location"); got != test.wantLocation {
+					t.Errorf("%q %s %s: expected Location header to be %q, got %q", test.givenPolicy, test.givenMethod, test.givenURL, test.wantLocation, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	cors := CORSConfig{
+		AllowedOrigins:   []string{"https://www.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type"},
+		MaxAge:           600,
+		AllowCredentials: true,
+	}
+	tests := []struct {
+		name           string
+		givenMethod    string
+		givenURL       string
+		givenOrigin    string
+		givenReqMethod string
+
+		wantCode    int
+		wantBody    string
+		wantOrigin  string
+		wantMethods string
+		wantMaxAge  string
+	}{
+		{
+			"preflight",
+			http.MethodOptions, "/users", "https://www.example.com", "PUT",
+			http.StatusNoContent, "", "https://www.example.com", "GET, PUT", "600",
+		},
+		{
+			"preflight without route",
+			http.MethodOptions, "/nope", "https://www.example.com", "PUT",
+			http.StatusNotFound, "", "", "", "",
+		},
+		{
+			"simple request",
+			http.MethodGet, "/users", "https://www.example.com", "",
+			http.StatusOK, "users", "https://www.example.com", "", "",
+		},
+		{
+			"disallowed origin preflight",
+			http.MethodOptions, "/users", "https://evil.example.com", "PUT",
+			http.StatusMethodNotAllowed, "", "", "", "",
+		},
+		{
+			"disallowed origin request",
+			http.MethodGet, "/users", "https://evil.example.com", "",
+			http.StatusOK, "users", "", "", "",
+		},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType, CORS: cors})
+			rt.HandleMethods([]string{"GET", "PUT"}, "/users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("users"))
+			}))
+
+			for _, test := range tests {
+				r := httptest.NewRequest(test.givenMethod, test.givenURL, nil)
+				r.Header.Set("Origin", test.givenOrigin)
+				if test.givenReqMethod != "" {
+					r.Header.Set("Access-Control-Request-Method", test.givenReqMethod)
+				}
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, r)
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s: expected status code %d, got %d", test.name, test.wantCode, w.Code)
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%s: expected response body to be %q, got %q", test.name, test.wantBody, w.Body.String())
+				}
+				if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.wantOrigin {
+					t.Errorf("%s: expected CORS origin header to be %q, got %q", test.name, test.wantOrigin, got)
+				}
+				if got := w.Header().Get("Access-Control-Allow-Methods"); got != test.wantMethods {
+					t.Errorf("%s: expected CORS methods header to be %q, got %q", test.name, test.wantMethods, got)
+				}
+				if got := w.Header().Get("Access-Control-Max-Age"); got != test.wantMaxAge {
+					t.Errorf("%s: expected CORS max age header to be %q, got %q", test.name, test.wantMaxAge, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRouterStrict(t *testing.T) {
+	tests := []struct {
+		givenType string
+
+		wantType string
+		wantErr  bool
+	}{
+		{"", "*server.GorillaRouter", false},
+		{"gorilla", "*server.GorillaRouter", false},
+		{"chi", "*server.ChiRouter", false},
+		{"stdlib", "*server.StdlibRouter", false},
+		{"gorrilla", "", true},
+	}
+
+	for _, test := range tests {
+		rt, err := NewRouterStrict(&Config{RouterType: test.givenType})
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got router %T", test.givenType, rt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.givenType, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", rt); got != test.wantType {
+			t.Errorf("%q: expected router type %s, got %s", test.givenType, test.wantType, got)
+		}
+	}
+
+	if got, ok := NewRouter(&Config{RouterType: "gorrilla"}).(*GorillaRouter); !ok || got == nil {
+		t.Errorf("expected NewRouter to default to a *GorillaRouter for an unknown type, got %T", got)
+	}
+}
+
+func TestAutoOptions(t *testing.T) {
+	tests := []struct {
+		givenAutoOptions bool
+		givenURL         string
+
+		wantCode  int
+		wantAllow string
+		wantBody  string
+	}{
+		{true, "/users/123", http.StatusNoContent, "GET, POST, OPTIONS", ""},
+		{true, "/users", http.StatusOK, "", "custom options"},
+		{true, "/nope", http.StatusNotFound, "", ""},
+		{false, "/users/123", http.StatusMethodNotAllowed, "", ""},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			for _, test := range tests {
+				rt := NewRouter(&Config{RouterType: routerType, AutoOptions: test.givenAutoOptions})
+				rt.HandleMethods([]string{"GET", "POST"}, "/users/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+				rt.HandleFunc("DELETE", "/users", func(w http.ResponseWriter, r *http.Request) {})
+				rt.HandleFunc("OPTIONS", "/users", func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("custom options"))
+				})
+
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, test.givenURL, nil))
+
+				if w.Code != test.wantCode {
+					t.Errorf("%v %s: expected status code %d, got %d", test.givenAutoOptions, test.givenURL, test.wantCode, w.Code)
+				}
+				if test.wantAllow != "" && w.Header().Get("Allow") != test.wantAllow {
+					t.Errorf("%v %s: expected Allow header to be %q, got %q", test.givenAutoOptions, test.givenURL, test.wantAllow, w.Header().Get("Allow"))
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%v %s: expected response body to be %q, got %q", test.givenAutoOptions, test.givenURL, test.wantBody, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func routesTestHandler(w http.ResponseWriter, r *http.Request) {}
+
+func TestRoutes(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType, AutoHEAD: true})
+			rt.HandleFunc("GET", "/users", routesTestHandler)
+			rt.HandleMethods([]string{"PUT", "DELETE"}, "/users/{id}", http.NotFoundHandler())
+			rt.HandleQueries("GET", "/search", http.RedirectHandler("/", http.StatusFound), "q", "{q}")
+			rt.Group("/api").Handle("POST", "/items", http.HandlerFunc(routesTestHandler))
+
+			want := []RouteInfo{
+				{"GET", "/users", "github.com/NYTimes/gizmo/server.routesTestHandler"},
+				{"HEAD", "/users", "github.com/NYTimes/gizmo/server.routesTestHandler"},
+				{"PUT", "/users/{id}", "net/http.NotFound"},
+				{"DELETE", "/users/{id}", "net/http.NotFound"},
+				{"GET", "/search", "*http.redirectHandler"},
+				{"HEAD", "/search", "*http.redirectHandler"},
+				{"POST", "/api/items", "github.com/NYTimes/gizmo/server.routesTestHandler"},
+			}
+			if got := rt.Routes(); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected routes %+v, got %+v", want, got)
+			}
+
+			w := httptest.NewRecorder()
+			RoutesHandler(rt).ServeHTTP(w, httptest.NewRequest("GET", "/_routes", nil))
+			var got []RouteInfo
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("unable to decode routes response: %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected routes response %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	tests := []struct {
+		givenCaseInsensitive bool
+		givenMethod          string
+		givenURL             string
+
+		wantCode     int
+		wantLocation string
+	}{
+		{true, http.MethodGet, "/Users?page=2", http.StatusMovedPermanently, "/users?page=2"},
+		{true, http.MethodPost, "/USERS", http.StatusTemporaryRedirect, "/users"},
+		{true, http.MethodGet, "/users", http.StatusOK, ""},
+		{true, http.MethodGet, "/Nope", http.StatusNotFound, ""},
+		{false, http.MethodGet, "/Users", http.StatusNotFound, ""},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			for _, test := range tests {
+				rt := NewRouter(&Config{RouterType: routerType, CaseInsensitivePaths: test.givenCaseInsensitive})
+				rt.HandleMethods([]string{"GET", "POST"}, "/users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(test.givenMethod, test.givenURL, nil))
+
+				if w.Code != test.wantCode {
+					t.Errorf("%v %s %s: expected status code %d, got %d", test.givenCaseInsensitive, test.givenMethod, test.givenURL, test.wantCode, w.Code)
+				}
+				if got := w.Header().Get("Location"); got != test.wantLocation {
+					t.Errorf("%v %s %s: expected Location header to be %q, got %q", test.givenCaseInsensitive, test.givenMethod, test.givenURL, test.wantLocation, got)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkRouter(b *testing.B, routerType, path string) {
+	rt := NewRouter(&Config{RouterType: routerType})
+	rt.HandleFunc("GET", "/svc/v1/{something}/blah", func(w http.ResponseWriter, r *http.Request) {
+		_ = Vars(r)["something"]
+	})
+	rt.HandleFunc("GET", "/svc/v1/noparam", func(w http.ResponseWriter, r *http.Request) {
+		_ = Vars(r)["something"]
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", path, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkChiRouter_NoParam(b *testing.B) {
+	benchmarkRouter(b, "chi", "/svc/v1/noparam")
+}
+
+func BenchmarkChiRouter_WithParam(b *testing.B) {
+	benchmarkRouter(b, "chi", "/svc/v1/1/blah")
+}
+
+func BenchmarkStdlibRouter_NoParam(b *testing.B) {
+	benchmarkRouter(b, "stdlib", "/svc/v1/noparam")
+}
+
+func BenchmarkStdlibRouter_WithParam(b *testing.B) {
+	benchmarkRouter(b, "stdlib", "/svc/v1/1/blah")
+}
+
+func TestHandleCatchAll(t *testing.T) {
+	tests := []struct {
+		givenURL string
+
+		wantCode     int
+		wantFilepath string
+	}{
+		{"/static/css/app.css", http.StatusOK, "/css/app.css"},
+		{"/static/index.html", http.StatusOK, "/index.html"},
+		{"/static/", http.StatusOK, "/"},
+		{"/api/static/js/app.js", http.StatusOK, "/js/app.js"},
+		{"/other/app.css", http.StatusNotFound, ""},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(Vars(r)["filepath"]))
+			})
+			rt.HandleCatchAll("GET", "/static/", h)
+			rt.Group("/api").HandleCatchAll("GET", "/static", h)
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest("GET", test.givenURL, nil))
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s: expected status code %d, got %d", test.givenURL, test.wantCode, w.Code)
+				}
+				if test.wantFilepath != "" && w.Body.String() != test.wantFilepath {
+					t.Errorf("%s: expected filepath to be %q, got %q", test.givenURL, test.wantFilepath, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestMaxRequestBodyBytes(t *testing.T) {
+	tests := []struct {
+		givenPath string
+		givenBody string
+
+		wantCode int
+	}{
+		{"/default", "tiny", http.StatusOK},
+		{"/default", "too large", http.StatusRequestEntityTooLarge},
+		{"/limited", "too large", http.StatusOK},
+		{"/limited", "way too large for this one", http.StatusRequestEntityTooLarge},
+		{"/unlimited", "way too large for this one", http.StatusOK},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType, MaxRequestBodyBytes: 5})
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := ioutil.ReadAll(r.Body); err != nil {
+					http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				}
+			})
+			rt.Handle("POST", "/default", h)
+			rt.HandleWithLimit("POST", "/limited", h, 10)
+			rt.HandleWithLimit("POST", "/unlimited", h, 0)
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest("POST", test.givenPath, strings.NewReader(test.givenBody)))
+				if w.Code != test.wantCode {
+					t.Errorf("%s %q: expected status code %d, got %d", test.givenPath, test.givenBody, test.wantCode, w.Code)
+				}
+
+				// without a Content-Length the handler sees the limit while reading.
+				r := httptest.NewRequest("POST", test.givenPath, strings.NewReader(test.givenBody))
+				r.ContentLength = -1
+				w = httptest.NewRecorder()
+				rt.ServeHTTP(w, r)
+				if w.Code != test.wantCode {
+					t.Errorf("%s %q without length: expected status code %d, got %d", test.givenPath, test.givenBody, test.wantCode, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	tests := []struct {
+		givenPath string
+
+		wantCode     int
+		wantCanceled bool
+	}{
+		{"/default", http.StatusServiceUnavailable, true},
+		{"/longer", http.StatusOK, false},
+		{"/unlimited", http.StatusOK, false},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType, HandlerTimeout: 20 * time.Millisecond})
+			canceled := make(chan bool, 1)
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+					canceled <- true
+				case <-time.After(100 * time.Millisecond):
+					canceled <- false
+				}
+			})
+			rt.Handle("GET", "/default", h)
+			rt.HandleWithTimeout("GET", "/longer", h, time.Second)
+			rt.HandleWithTimeout("GET", "/unlimited", h, 0)
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest("GET", test.givenPath, nil))
+				if w.Code != test.wantCode {
+					t.Errorf("%s: expected status code %d, got %d", test.givenPath, test.wantCode, w.Code)
+				}
+				if got := <-canceled; got != test.wantCanceled {
+					t.Errorf("%s: expected request context canceled? %t, got %t", test.givenPath, test.wantCanceled, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterUse(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			var calls []string
+			mw := func(name string) func(http.Handler) http.Handler {
+				return func(h http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						calls = append(calls, name)
+						h.ServeHTTP(w, r)
+					})
+				}
+			}
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, "handler")
+			})
+
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.Use(mw("first"), mw("second"))
+			rt.Handle("GET", "/plain", handler)
+			rt.HandleWithMiddleware("GET", "/route", handler, mw("route"))
+			grp := rt.Group("/grp")
+			grp.Use(mw("group"))
+			grp.Handle("GET", "/thing", handler)
+			rt.Handle("GET", "/after", handler)
+
+			tests := []struct {
+				givenPath string
+				wantCalls []string
+			}{
+				{"/plain", []string{"first", "second", "handler"}},
+				{"/route", []string{"first", "second", "route", "handler"}},
+				{"/grp/thing", []string{"first", "second", "group", "handler"}},
+				// middleware added to a group does not leak into its parent
+				{"/after", []string{"first", "second", "handler"}},
+			}
+			for _, test := range tests {
+				calls = nil
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest("GET", test.givenPath, nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("%s: expected status 200, got %d", test.givenPath, w.Code)
+				}
+				if !reflect.DeepEqual(calls, test.wantCalls) {
+					t.Errorf("%s: expected calls %v, got %v", test.givenPath, test.wantCalls, calls)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterMount(t *testing.T) {
+	graphql := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, r.URL.RawQuery)
+	})
+
+	tests := []struct {
+		givenMethod string
+		givenPath   string
+
+		wantCode int
+		wantBody string
+	}{
+		{"GET", "/graphql", http.StatusOK, "GET / "},
+		{"POST", "/graphql/", http.StatusOK, "POST / "},
+		{"GET", "/graphql/schema/types?pretty=1", http.StatusOK, "GET /schema/types pretty=1"},
+		{"DELETE", "/api/graphql/query", http.StatusOK, "DELETE /query "},
+		{"GET", "/graphqlx", http.StatusNotFound, ""},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.Mount("/graphql", graphql)
+			rt.Group("/api").Mount("/graphql/", graphql)
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(test.givenMethod, test.givenPath, nil))
+				if w.Code != test.wantCode {
+					t.Errorf("%s %s: expected status %d, got %d", test.givenMethod, test.givenPath, test.wantCode, w.Code)
+					continue
+				}
+				if test.wantBody != "" && w.Body.String() != test.wantBody {
+					t.Errorf("%s %s: expected body %q, got %q", test.givenMethod, test.givenPath, test.wantBody, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRouterHandleAliases(t *testing.T) {
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			rt := NewRouter(&Config{RouterType: routerType})
+			rt.HandleAliases("GET", []string{"/health", "/healthz", "/v1/health"},
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("ok"))
+				}))
+
+			for _, path := range []string{"/health", "/healthz", "/v1/health"} {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+				if w.Code != http.StatusOK || w.Body.String() != "ok" {
+					t.Errorf("expected %s to respond with a 200 and %q, got a %d and %q",
+						path, "ok", w.Code, w.Body.String())
+				}
+			}
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest("GET", "/healthy", nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected an unregistered path to 404, got %d", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+
+		givenConfig    SecurityHeadersConfig
+		givenTLS       bool
+		givenForwarded bool
+
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "defaults over plain HTTP",
+			givenConfig: SecurityHeadersConfig{},
+
+			wantHeaders: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "no-referrer",
+				"Content-Security-Policy":   "",
+				"Strict-Transport-Security": "",
+			},
+		},
+		{
+			name:        "defaults over TLS",
+			givenConfig: SecurityHeadersConfig{},
+			givenTLS:    true,
+
+			wantHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=15552000",
+			},
+		},
+		{
+			name: "custom values",
+			givenConfig: SecurityHeadersConfig{
+				HSTSMaxAge:            60,
+				HSTSIncludeSubdomains: true,
+				HSTSPreload:           true,
+				FrameOptions:          "SAMEORIGIN",
+				ReferrerPolicy:        "same-origin",
+				ContentSecurityPolicy: "default-src 'self'",
+			},
+			givenTLS: true,
+
+			wantHeaders: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "SAMEORIGIN",
+				"Referrer-Policy":           "same-origin",
+				"Content-Security-Policy":   "default-src 'self'",
+				"Strict-Transport-Security": "max-age=60; includeSubDomains; preload",
+			},
+		},
+		{
+			name:           "forwarded proto not trusted",
+			givenConfig:    SecurityHeadersConfig{},
+			givenForwarded: true,
+
+			wantHeaders: map[string]string{
+				"Strict-Transport-Security": "",
+			},
+		},
+		{
+			name:           "forwarded proto trusted",
+			givenConfig:    SecurityHeadersConfig{HSTSTrustForwardedProto: true},
+			givenForwarded: true,
+
+			wantHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=15552000",
+			},
+		},
+		{
+			name:        "HSTS disabled",
+			givenConfig: SecurityHeadersConfig{DisableHSTS: true},
+			givenTLS:    true,
+
+			wantHeaders: map[string]string{
+				"Strict-Transport-Security": "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := SecurityHeadersMiddleware(test.givenConfig)(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("ok"))
+				}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.givenTLS {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if test.givenForwarded {
+				r.Header.Set("X-Forwarded-Proto", "https")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			for name, want := range test.wantHeaders {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("expected %s header to be %q, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/pprof"
@@ -43,15 +44,29 @@ var (
 	// readTimeout is used by the http server to set a maximum duration before
 	// timing out read of the request. The default timeout is 10 seconds.
 	readTimeout = 10 * time.Second
+	// readHeaderTimeout is used by the http server to set a maximum duration
+	// for reading the request headers so slow clients can not hold
+	// connections open. The default timeout is 5 seconds.
+	readHeaderTimeout = 5 * time.Second
 	// writeTimeout is used by the http server to set a maximum duration before
 	// timing out write of the response. The default timeout is 10 seconds.
 	writeTimeout = 10 * time.Second
 	// jsonContentType is the content type that will be used for JSONEndpoints.
 	// It will default to the JSONContentType value.
 	jsonContentType = JSONContentType
+	// strictAccept will make Encode respond with a 406 to requests that
+	// accept neither JSON nor XML.
+	strictAccept = false
+	// contentLengthThreshold is the largest response JSONToHTTP and Encode
+	// set the Content-Length header of.
+	// It will default to the DefaultContentLengthThreshold value.
+	contentLengthThreshold = DefaultContentLengthThreshold
 	// idleTimeout is used by the http server to set a maximum duration for
 	// keep-alive connections.
 	idleTimeout = 120 * time.Second
+	// defaultShutdownTimeout is how long a server will wait for in-flight
+	// requests to finish when stopping, unless Config.ShutdownTimeout is set.
+	defaultShutdownTimeout = 10 * time.Second
 )
 
 // Init will set up our name, logging, healthchecks and parse flags. If DefaultServer isn't set,
@@ -79,32 +94,14 @@ func Init(name string, scfg *Config) {
 		jsonContentType = *scfg.JSONContentType
 	}
 
-	if scfg.MaxHeaderBytes != nil {
-		maxHeaderBytes = *scfg.MaxHeaderBytes
-	}
+	strictAccept = scfg.StrictAccept
 
-	if scfg.ReadTimeout != nil {
-		tReadTimeout, err := time.ParseDuration(*scfg.ReadTimeout)
-		if err != nil {
-			Log.Fatal("invalid server ReadTimeout: ", err)
-		}
-		readTimeout = tReadTimeout
-	}
-
-	if scfg.IdleTimeout != nil {
-		tIdleTimeout, err := time.ParseDuration(*scfg.IdleTimeout)
-		if err != nil {
-			Log.Fatal("invalid server IdleTimeout: ", err)
-		}
-		idleTimeout = tIdleTimeout
+	if scfg.ContentLengthThreshold != nil {
+		contentLengthThreshold = *scfg.ContentLengthThreshold
 	}
 
-	if scfg.WriteTimeout != nil {
-		tWriteTimeout, err := time.ParseDuration(*scfg.WriteTimeout)
-		if err != nil {
-			Log.Fatal("invalid server WriteTimeout: ", err)
-		}
-		writeTimeout = tWriteTimeout
+	if scfg.MaxHeaderBytes != nil {
+		maxHeaderBytes = *scfg.MaxHeaderBytes
 	}
 
 	// setup app logging
@@ -160,6 +157,18 @@ func Stop() error {
 	return server.Stop()
 }
 
+// Shutdown will gracefully stop the default server, waiting for in-flight
+// requests until the context is done if the server supports it.
+func Shutdown(ctx context.Context) error {
+	Log.Infof("Shutting down %s server", Name)
+	if s, ok := server.(interface {
+		Shutdown(context.Context) error
+	}); ok {
+		return s.Shutdown(ctx)
+	}
+	return server.Stop()
+}
+
 // LogWithFields will feed any request context into a logrus Entry.
 func LogWithFields(r *http.Request) *logrus.Entry {
 	return Log.WithFields(ContextFields(r))
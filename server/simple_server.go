@@ -1,17 +1,23 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	netContext "golang.org/x/net/context"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // SimpleServer is a basic http Server implementation for
@@ -23,7 +29,9 @@ type SimpleServer struct {
 	cfg *Config
 
 	// exit chan for graceful shutdown
-	exit chan chan error
+	exit chan shutdownRequest
+	// how long Stop will wait for in-flight requests, set by Start
+	shutdownTimeout time.Duration
 
 	// mux for routing
 	mux Router
@@ -33,6 +41,11 @@ type SimpleServer struct {
 
 	// tracks active requests
 	monitor *ActivityMonitor
+	// set to 1 once shutdown has begun
+	draining uint32
+
+	// the listener accepting connections once started
+	listener net.Listener
 }
 
 // NewSimpleServer will init the mux, exit channel and
@@ -45,20 +58,35 @@ func NewSimpleServer(cfg *Config) *SimpleServer {
 	mx := NewRouter(cfg)
 	if cfg.NotFoundHandler != nil {
 		mx.SetNotFoundHandler(cfg.NotFoundHandler)
+	} else if cfg.JSONErrors {
+		mx.SetNotFoundHandler(JSONNotFoundHandler)
+	}
+	if cfg.MethodNotAllowedHandler != nil {
+		mx.SetMethodNotAllowedHandler(cfg.MethodNotAllowedHandler)
+	} else if cfg.JSONErrors {
+		mx.SetMethodNotAllowedHandler(JSONMethodNotAllowedHandler)
 	}
 
 	return &SimpleServer{
 		mux:     mx,
 		cfg:     cfg,
-		exit:    make(chan chan error),
+		exit:    make(chan shutdownRequest),
 		monitor: NewActivityMonitor(),
 	}
 }
 
 // ServeHTTP is SimpleServer's hook for metrics and safely executing each request.
 func (s *SimpleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.EnableRequestID {
+		r = withRequestID(w, r)
+	}
 	AddIPToContext(r)
 
+	// ask clients to stop reusing their connections while draining
+	if atomic.LoadUint32(&s.draining) == 1 && r.ProtoMajor == 1 {
+		w.Header().Set("Connection", "close")
+	}
+
 	// only count non-LB requests
 	if r.URL.Path != s.cfg.HealthCheckPath {
 		s.monitor.CountRequest()
@@ -69,13 +97,16 @@ func (s *SimpleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // UnexpectedServerError is returned with a 500 status code when SimpleServer recovers
-// from a panic in a request.
+// from a panic in a request outside of the RecoveryMiddleware.
 var UnexpectedServerError = []byte("unexpected server error")
 
 // executeRequestSafely will prevent a panic in a request from bringing the server down.
 func (s *SimpleServer) safelyExecuteRequest(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if x := recover(); x != nil {
+			if x == http.ErrAbortHandler {
+				panic(x)
+			}
 			// log the panic for all the details later
 			LogWithFields(r).Errorf("simple server recovered from a panic\n%v: %v", x, string(debug.Stack()))
 
@@ -112,7 +143,20 @@ func (s *SimpleServer) safelyExecuteRequest(w http.ResponseWriter, r *http.Reque
 
 // Start will start the SimpleServer at it's configured address.
 // If they are configured, this will start health checks and access logging.
+// The funcs registered with OnStart run first and, if one fails, the server
+// is not started and its error is returned.
 func (s *SimpleServer) Start() error {
+	var err error
+	s.shutdownTimeout, err = parseTimeout("ShutdownTimeout", s.cfg.ShutdownTimeout, defaultShutdownTimeout)
+	if err != nil {
+		return err
+	}
+
+	// let the components registered with OnStart initialize
+	if err := runStartHooks(context.Background()); err != nil {
+		return err
+	}
+
 	healthHandler := RegisterHealthHandler(s.cfg, s.monitor, s.mux)
 	s.cfg.HealthCheckPath = healthHandler.Path()
 
@@ -127,58 +171,187 @@ func (s *SimpleServer) Start() error {
 		Log.Fatalf("unable to create http access log: %s", err)
 	}
 
-	srv := httpServer(wrappedHandler)
+	if s.cfg.EnableH2C {
+		wrappedHandler = h2c.NewHandler(wrappedHandler, &http2.Server{})
+	}
 
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.HTTPPort))
+	srv, err := httpServer(s.cfg, wrappedHandler)
 	if err != nil {
 		return err
 	}
 
-	l = net.Listener(TCPKeepAliveListener{l.(*net.TCPListener)})
-
-	// add TLS if in the configs
-	if s.cfg.TLSCertFile != nil && s.cfg.TLSKeyFile != nil {
-		cert, err := tls.LoadX509KeyPair(*s.cfg.TLSCertFile, *s.cfg.TLSKeyFile)
+	l := s.cfg.Listener
+	if l == nil {
+		tl, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.HTTPPort))
 		if err != nil {
 			return err
 		}
-		srv.TLSConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			NextProtos:   []string{"http/1.1"},
-		}
+		l = net.Listener(TCPKeepAliveListener{tl.(*net.TCPListener)})
+	}
 
+	// add TLS if in the configs
+	var redirectSrv *http.Server
+	if tlsCfg, err := s.tlsConfig(); err != nil {
+		l.Close()
+		return err
+	} else if tlsCfg != nil {
+		srv.TLSConfig = tlsCfg
 		l = tls.NewListener(l, srv.TLSConfig)
+
+		if s.cfg.TLSRedirectPort != 0 {
+			port, err := listenerPort(l)
+			if err != nil {
+				l.Close()
+				return err
+			}
+			rl, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.TLSRedirectPort))
+			if err != nil {
+				l.Close()
+				return err
+			}
+			redirectSrv, err = httpServer(s.cfg, httpsRedirectHandler(port))
+			if err != nil {
+				rl.Close()
+				l.Close()
+				return err
+			}
+			go func() {
+				if err := redirectSrv.Serve(rl); err != nil && err != http.ErrServerClosed {
+					Log.Error("encountered an error while serving redirect listener: ", err)
+				}
+			}()
+			Log.Infof("Redirecting HTTP requests on %s to HTTPS", rl.Addr().String())
+		}
 	}
 
 	go func() {
-		if err := srv.Serve(l); err != nil {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
 			Log.Error("encountered an error while serving listener: ", err)
 		}
 	}()
+	s.listener = l
 	Log.Infof("Listening on %s", l.Addr().String())
 
 	// join the LB
 	go func() {
 		exit := <-s.exit
+		ctx := exit.ctx
+
+		// stop keeping connections alive so clients and load balancers
+		// move to other instances while requests are drained
+		atomic.StoreUint32(&s.draining, 1)
+		srv.SetKeepAlivesEnabled(false)
+		if redirectSrv != nil {
+			redirectSrv.SetKeepAlivesEnabled(false)
+		}
 
 		// let the health check clean up if it needs to
 		if err := healthHandler.Stop(); err != nil {
 			Log.Warn("health check Stop returned with error: ", err)
 		}
 
-		// stop the listener
-		exit <- l.Close()
+		// stop accepting connections and let in-flight requests finish
+		if n := s.monitor.NumActiveRequests(); n > 0 {
+			Log.Infof("draining %d in-flight requests", n)
+		}
+		if redirectSrv != nil {
+			if err := redirectSrv.Shutdown(ctx); err != nil {
+				Log.Warn("unable to shut down redirect server: ", err)
+			}
+		}
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			Log.Warnf("shutdown timed out with %d requests still in flight", s.monitor.NumActiveRequests())
+		}
+
+		// let the components registered with OnShutdown clean up
+		if hookErr := runShutdownHooks(ctx); err == nil {
+			err = hookErr
+		}
+		exit.errs <- err
 	}()
 
 	return nil
 }
 
+// tlsConfig will return the TLS config for the server or nil if neither a
+// cert and key file or a TLSGetCertificate func are configured.
+func (s *SimpleServer) tlsConfig() (*tls.Config, error) {
+	if s.cfg.TLSGetCertificate != nil {
+		return &tls.Config{
+			GetCertificate: s.cfg.TLSGetCertificate,
+			NextProtos:     []string{"http/1.1"},
+		}, nil
+	}
+	if s.cfg.TLSCertFile == nil || s.cfg.TLSKeyFile == nil {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(*s.cfg.TLSCertFile, *s.cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"http/1.1"},
+	}, nil
+}
+
+// listenerPort returns the port the listener accepts connections on, or an
+// error if it does not listen on a TCP port, like a unix socket.
+func listenerPort(l net.Listener) (int, error) {
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	if err == nil {
+		var p int
+		if p, err = strconv.Atoi(port); err == nil {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("TLSRedirectPort requires a TCP listener, got %s address %q",
+		l.Addr().Network(), l.Addr().String())
+}
+
+// httpsRedirectHandler will permanently redirect every request
+// to the same URL on HTTPS at the given port.
+func httpsRedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := requestHost(r)
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// shutdownRequest asks a started SimpleServer to shut down and
+// wait for in-flight requests until ctx is done.
+type shutdownRequest struct {
+	ctx  context.Context
+	errs chan error
+}
+
 // Stop initiates the shutdown process and returns when
-// the server completes.
+// the server completes or the Config.ShutdownTimeout is hit.
 func (s *SimpleServer) Stop() error {
-	ch := make(chan error)
-	s.exit <- ch
-	return <-ch
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown will gracefully shut down a started server on demand: it stops
+// accepting connections and waits for in-flight requests to finish until the
+// given context is done, in which case the context's error is returned.
+// Once shutdown has begun, keep-alives are disabled and responses get a
+// 'Connection: close' header so clients stop reusing their connections.
+// Then the funcs registered with OnShutdown run and, if the requests were
+// drained in time, their ShutdownErrors are returned.
+func (s *SimpleServer) Shutdown(ctx context.Context) error {
+	errs := make(chan error, 1)
+	select {
+	case s.exit <- shutdownRequest{ctx: ctx, errs: errs}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return <-errs
 }
 
 // Register will accept and register SimpleServer, JSONService or MixedService implementations.
@@ -191,6 +364,47 @@ func (s *SimpleServer) Register(svcI Service) error {
 	s.registered = true
 
 	s.h = svcI.Middleware(s.mux)
+	if len(s.cfg.Redirects) > 0 {
+		redirect, err := RedirectMiddleware(s.cfg.Redirects)
+		if err != nil {
+			return err
+		}
+		s.h = redirect(s.h)
+	}
+	s.h = maintenanceMiddleware(func(path string) bool {
+		return path == s.cfg.HealthCheckPath || path == s.cfg.MetricsPath ||
+			path == LivenessPath || path == ReadinessPath ||
+			containsString(s.cfg.MaintenanceAllowList, path)
+	})(s.h)
+	s.h = RecoveryMiddleware(Log)(s.h)
+	if s.cfg.SecurityHeaders.Enabled {
+		s.h = SecurityHeadersMiddleware(s.cfg.SecurityHeaders)(s.h)
+	}
+	if s.cfg.CacheControl != (CachePolicy{}) {
+		s.h = CacheControlMiddleware(s.cfg.CacheControl)(s.h)
+	}
+	if s.cfg.Compression {
+		s.h = CompressionMiddleware(s.cfg.CompressionMinSize)(s.h)
+	}
+	if len(s.cfg.IPAllowList) > 0 || len(s.cfg.IPDenyList) > 0 {
+		filter, err := IPFilterMiddleware(s.cfg.IPAllowList, s.cfg.IPDenyList, s.cfg.TrustedProxies)
+		if err != nil {
+			return err
+		}
+		s.h = filter(s.h)
+	}
+	if s.cfg.MaxURLPathLength > 0 || s.cfg.MaxURLQueryLength > 0 {
+		s.h = MaxURLLengthMiddleware(s.cfg.MaxURLPathLength, s.cfg.MaxURLQueryLength)(s.h)
+	}
+	if s.cfg.MaxConcurrentRequests > 0 {
+		s.h = ConcurrencyLimitMiddleware(s.cfg.MaxConcurrentRequests, s.cfg.ConcurrentRequestsWait)(s.h)
+	}
+	if s.cfg.Metrics {
+		s.h = PrometheusMiddleware(nil, s.cfg.MetricsNamespace, s.cfg.MetricsSubsystem)(s.h)
+	}
+	if s.cfg.AccessLog {
+		s.h = AccessLogMiddleware(Log, s.accessLogFields)(s.h)
+	}
 	s.svc = svcI
 	prefix := svcI.Prefix()
 	// quick fix for backwards compatibility
@@ -263,6 +477,20 @@ func (s *SimpleServer) Register(svcI Service) error {
 	return nil
 }
 
+// accessLogFields adds the client IP to a copy of the Config.AccessLogFields.
+func (s *SimpleServer) accessLogFields(r *http.Request) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if s.cfg.AccessLogFields != nil {
+		for k, v := range s.cfg.AccessLogFields(r) {
+			fields[k] = v
+		}
+	}
+	if _, ok := fields["client-ip"]; !ok {
+		fields["client-ip"] = ClientIP(r, s.cfg.TrustedProxies)
+	}
+	return fields
+}
+
 // GetForwardedIP returns the "X-Forwarded-For" header value.
 func GetForwardedIP(r *http.Request) string {
 	return r.Header.Get("X-Forwarded-For")
@@ -3,12 +3,29 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
 )
 
 type benchmarkContextService struct {
@@ -494,3 +511,504 @@ func TestNotFoundHandler(t *testing.T) {
 		t.Errorf("expected response body to be \"\", got %q", gotBody)
 	}
 }
+
+func TestSimpleServerJSONErrors(t *testing.T) {
+	tests := []struct {
+		givenMethod string
+		givenPath   string
+
+		wantCode int
+		wantBody string
+	}{
+		{http.MethodGet, "/svc/v1/nope", http.StatusNotFound, `{"error":{"status":404,"message":"not found"}}` + "\n"},
+		{http.MethodPost, "/svc/v1/2", http.StatusMethodNotAllowed, `{"error":{"status":405,"message":"method not allowed"}}` + "\n"},
+	}
+
+	for _, routerType := range routerTypes {
+		t.Run(routerType, func(t *testing.T) {
+			cfg := &Config{HealthCheckType: "simple", HealthCheckPath: "/status",
+				RouterType: routerType, JSONErrors: true}
+			srvr := NewSimpleServer(cfg)
+			srvr.Register(&benchmarkSimpleService{false})
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(test.givenMethod, test.givenPath, nil)
+				r.RemoteAddr = "0.0.0.0:8080"
+				srvr.ServeHTTP(w, r)
+
+				if w.Code != test.wantCode {
+					t.Errorf("%s %s: expected status %d, got %d", test.givenMethod, test.givenPath, test.wantCode, w.Code)
+				}
+				if got := w.Header().Get("Content-Type"); got != JSONContentType {
+					t.Errorf("%s %s: expected Content-Type %q, got %q", test.givenMethod, test.givenPath, JSONContentType, got)
+				}
+				if got := w.Body.String(); got != test.wantBody {
+					t.Errorf("%s %s: expected body %q, got %q", test.givenMethod, test.givenPath, test.wantBody, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSimpleServerEnableRequestID(t *testing.T) {
+	cfg := &Config{HealthCheckType: "simple", HealthCheckPath: "/status", EnableRequestID: true}
+	srvr := NewSimpleServer(cfg)
+	RegisterHealthHandler(cfg, srvr.monitor, srvr.mux)
+	srvr.Register(&benchmarkSimpleService{false})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/svc/v1/2", nil)
+	r.RemoteAddr = "0.0.0.0:8080"
+	r.Header.Set(RequestIDHeader, "abc-123")
+
+	srvr.ServeHTTP(w, r)
+
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("expected response %q header to be \"abc-123\", got %q", RequestIDHeader, got)
+	}
+}
+
+type slowSimpleService struct {
+	started chan struct{}
+	delay   time.Duration
+}
+
+func (s *slowSimpleService) Prefix() string {
+	return "/svc"
+}
+
+func (s *slowSimpleService) Endpoints() map[string]map[string]http.HandlerFunc {
+	return map[string]map[string]http.HandlerFunc{
+		"/slow": map[string]http.HandlerFunc{
+			"GET": func(w http.ResponseWriter, r *http.Request) {
+				close(s.started)
+				time.Sleep(s.delay)
+				fmt.Fprint(w, "done")
+			},
+		},
+	}
+}
+
+func (s *slowSimpleService) Middleware(h http.Handler) http.Handler {
+	return h
+}
+
+func TestSimpleServerGracefulShutdown(t *testing.T) {
+	tests := []struct {
+		givenTimeout string
+
+		wantBody    string
+		wantStopErr bool
+	}{
+		{"1s", "done", false},
+		{"10ms", "", true},
+	}
+
+	for _, test := range tests {
+		timeout := test.givenTimeout
+		cfg := &Config{HealthCheckType: "simple", HealthCheckPath: "/status", ShutdownTimeout: &timeout}
+		srvr := NewSimpleServer(cfg)
+		svc := &slowSimpleService{started: make(chan struct{}), delay: 300 * time.Millisecond}
+		srvr.Register(svc)
+		if err := srvr.Start(); err != nil {
+			t.Fatalf("unable to start server: %s", err)
+		}
+		url := "http://" + srvr.listener.Addr().String() + "/svc/slow"
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+		bodies := make(chan string, 1)
+		go func() {
+			resp, err := client.Get(url)
+			if err != nil {
+				bodies <- ""
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			bodies <- string(b)
+		}()
+		<-svc.started
+
+		stopErrs := make(chan error, 1)
+		go func() { stopErrs <- srvr.Stop() }()
+
+		// wait for the listener to close before trying a new request
+		time.Sleep(50 * time.Millisecond)
+		if resp, err := client.Get(url); err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: expected new requests to be rejected while shutting down", test.givenTimeout)
+		}
+
+		err := <-stopErrs
+		if test.wantStopErr && err == nil {
+			t.Errorf("%s: expected Stop to return an error when the timeout is hit", test.givenTimeout)
+		}
+		if !test.wantStopErr && err != nil {
+			t.Errorf("%s: unexpected error from Stop: %s", test.givenTimeout, err)
+		}
+		if test.wantBody != "" {
+			if got := <-bodies; got != test.wantBody {
+				t.Errorf("%s: expected in-flight response body to be %q, got %q", test.givenTimeout, test.wantBody, got)
+			}
+		}
+	}
+}
+
+func TestSimpleServerShutdown(t *testing.T) {
+	srvr := NewSimpleServer(&Config{HealthCheckType: "simple", HealthCheckPath: "/status"})
+	svc := &slowSimpleService{started: make(chan struct{}), delay: 100 * time.Millisecond}
+	srvr.Register(svc)
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	addr := srvr.listener.Addr().String()
+
+	bodies := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/svc/slow")
+		if err != nil {
+			bodies <- ""
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		bodies <- string(b)
+	}()
+	<-svc.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srvr.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error from Shutdown: %s", err)
+	}
+
+	if got := <-bodies; got != "done" {
+		t.Errorf("expected in-flight response body to be \"done\", got %q", got)
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("expected the listener to be closed")
+	}
+}
+
+func TestSimpleServerShutdownConnectionClose(t *testing.T) {
+	srvr := NewSimpleServer(&Config{HealthCheckType: "simple", HealthCheckPath: "/status"})
+	svc := &slowSimpleService{started: make(chan struct{}), delay: 100 * time.Millisecond}
+	srvr.Register(svc)
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	addr := srvr.listener.Addr().String()
+
+	resps := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/svc/slow")
+		if err != nil {
+			resps <- nil
+			return
+		}
+		resp.Body.Close()
+		resps <- resp
+	}()
+	<-svc.started
+
+	if err := srvr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error from Shutdown: %s", err)
+	}
+
+	resp := <-resps
+	if resp == nil {
+		t.Fatal("expected the in-flight request to complete")
+	}
+	if !resp.Close {
+		t.Errorf("expected the draining response to close the connection, got %v", resp.Header)
+	}
+
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, httptest.NewRequest("GET", "/svc/other", nil))
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("expected responses after shutdown began to have Connection %q, got %q", "close", got)
+	}
+}
+
+func TestSimpleServerListener(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("unable to listen: %s", err)
+	}
+	srvr := NewSimpleServer(&Config{HealthCheckType: "simple", HealthCheckPath: "/status", Listener: l})
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	defer srvr.Stop()
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/svc/v1/2", l.Addr().(*net.TCPAddr).Port))
+	if err != nil {
+		t.Fatalf("unable to make request: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestSimpleServerTLSRedirect(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	defer os.RemoveAll(filepath.Dir(certFile))
+	cfg := &Config{
+		HealthCheckType: "simple",
+		HealthCheckPath: "/status",
+		TLSCertFile:     &certFile,
+		TLSKeyFile:      &keyFile,
+		TLSRedirectPort: freePort(t),
+	}
+	srvr := NewSimpleServer(cfg)
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	defer srvr.Stop()
+
+	tlsPort := srvr.listener.Addr().(*net.TCPAddr).Port
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/svc/v1/2?a=b", cfg.TLSRedirectPort))
+	if err != nil {
+		t.Fatalf("unable to make plain HTTP request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("expected plain HTTP status code %d, got %d", http.StatusMovedPermanently, resp.StatusCode)
+	}
+	wantLocation := fmt.Sprintf("https://localhost:%d/svc/v1/2?a=b", tlsPort)
+	if got := resp.Header.Get("Location"); got != wantLocation {
+		t.Errorf("expected Location header to be %q, got %q", wantLocation, got)
+	}
+
+	resp, err = client.Get(wantLocation)
+	if err != nil {
+		t.Fatalf("unable to make HTTPS request: %s", err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(b) != "ok" {
+		t.Errorf("expected HTTPS response to be 200 \"ok\", got %d %q", resp.StatusCode, string(b))
+	}
+}
+
+func TestSimpleServerTLSGetCertificate(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		cert tls.Certificate
+	)
+	setCert := func(serial int64) {
+		certPEM, keyPEM := newTestCert(t, serial)
+		c, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatalf("unable to load certificate: %s", err)
+		}
+		mu.Lock()
+		cert = c
+		mu.Unlock()
+	}
+	setCert(1)
+
+	cfg := &Config{
+		HealthCheckType: "simple",
+		HealthCheckPath: "/status",
+		TLSGetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return &cert, nil
+		},
+	}
+	srvr := NewSimpleServer(cfg)
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	defer srvr.Stop()
+
+	url := fmt.Sprintf("https://localhost:%d/svc/v1/2", srvr.listener.Addr().(*net.TCPAddr).Port)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}}
+
+	for _, serial := range []int64{1, 2} {
+		setCert(serial)
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("unable to make HTTPS request: %s", err)
+		}
+		resp.Body.Close()
+		if got := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); got != serial {
+			t.Errorf("expected certificate serial number %d, got %d", serial, got)
+		}
+	}
+}
+
+func TestSimpleServerH2C(t *testing.T) {
+	srvr := NewSimpleServer(&Config{EnableH2C: true})
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	defer srvr.Stop()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	port := srvr.listener.Addr().(*net.TCPAddr).Port
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/svc/v1/2", port))
+	if err != nil {
+		t.Fatalf("unable to make h2c request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected an HTTP/2 response, got %s", resp.Proto)
+	}
+}
+
+// freePort returns a TCP port that is currently free.
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("unable to find a free port: %s", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// writeTestCert writes a self-signed certificate for localhost
+// and its key to temporary files.
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	certPEM, keyPEM := newTestCert(t, 1)
+	dir, err := ioutil.TempDir("", "gizmo-tls")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("unable to write certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("unable to write key: %s", err)
+	}
+	return certFile, keyFile
+}
+
+// newTestCert returns a PEM encoded self-signed certificate for
+// localhost with the given serial number and its key.
+func newTestCert(t *testing.T, serial int64) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestSimpleServerAccessLogFields(t *testing.T) {
+	given := map[string]interface{}{"team": "gizmo"}
+	tests := []struct {
+		name   string
+		fields func(*http.Request) map[string]interface{}
+
+		want map[string]interface{}
+	}{
+		{
+			name:   "nil fields",
+			fields: func(*http.Request) map[string]interface{} { return nil },
+			want:   map[string]interface{}{"client-ip": "192.0.2.1"},
+		},
+		{
+			name:   "user fields",
+			fields: func(*http.Request) map[string]interface{} { return given },
+			want:   map[string]interface{}{"team": "gizmo", "client-ip": "192.0.2.1"},
+		},
+		{
+			name: "user client ip",
+			fields: func(*http.Request) map[string]interface{} {
+				return map[string]interface{}{"client-ip": "10.0.0.1"}
+			},
+			want: map[string]interface{}{"client-ip": "10.0.0.1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srvr := NewSimpleServer(&Config{AccessLogFields: test.fields})
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+
+			if got := srvr.accessLogFields(r); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected fields %v, got %v", test.want, got)
+			}
+		})
+	}
+	if want := map[string]interface{}{"team": "gizmo"}; !reflect.DeepEqual(given, want) {
+		t.Errorf("expected the user fields not to be modified, got %v", given)
+	}
+}
+
+func TestSimpleServerTLSRedirectUnixListener(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	defer os.RemoveAll(filepath.Dir(certFile))
+	l, err := net.Listen("unix", filepath.Join(filepath.Dir(certFile), "gizmo.sock"))
+	if err != nil {
+		t.Fatalf("unable to listen: %s", err)
+	}
+	defer l.Close()
+
+	srvr := NewSimpleServer(&Config{
+		HealthCheckType: "simple",
+		HealthCheckPath: "/status",
+		TLSCertFile:     &certFile,
+		TLSKeyFile:      &keyFile,
+		TLSRedirectPort: freePort(t),
+		Listener:        l,
+	})
+	srvr.Register(&benchmarkSimpleService{})
+	if err := srvr.Start(); err == nil {
+		srvr.Stop()
+		t.Fatal("expected an error starting a TLS redirect for a unix listener")
+	}
+	// fail instead of blocking if the listener was left open
+	l.(*net.UnixListener).SetDeadline(time.Now().Add(time.Second))
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the listener to be closed, got %v", err)
+	}
+}
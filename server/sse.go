@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamingUnsupported is returned by SSEWriter when the ResponseWriter
+// cannot be flushed, like the one of an http.TimeoutHandler.
+var ErrStreamingUnsupported = errors.New("streaming unsupported: ResponseWriter is not an http.Flusher")
+
+// SSE writes server-sent events to a client. It is safe for concurrent use.
+type SSE struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// SSEWriter will set the server-sent event headers, disable any proxy
+// buffering and write the response headers to start an event stream.
+// It returns ErrStreamingUnsupported if the ResponseWriter can not be
+// flushed, after the headers have been written.
+func SSEWriter(w http.ResponseWriter) (*SSE, error) {
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil, ErrStreamingUnsupported
+		}
+		return nil, err
+	}
+	return &SSE{w: w, rc: rc}, nil
+}
+
+// Send will write an event and flush it to the client. The event line is
+// left out if event is empty and multi-line data is split across data lines.
+func (s *SSE) Send(event, data string) error {
+	var b strings.Builder
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Heartbeat will write a comment to the stream every interval to keep
+// idle connections from being closed. The heartbeat runs until the
+// returned func is called or a write fails.
+func (s *SSE) Heartbeat(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.write(": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (s *SSE) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprint(s.w, msg); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse, err := SSEWriter(w)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !w.Flushed {
+		t.Error("expected the headers to be flushed")
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("expected Content-Type %q, got %q", "text/event-stream", got)
+	}
+	if got := w.Header().Get("X-Accel-Buffering"); got != "no" {
+		t.Errorf("expected X-Accel-Buffering %q, got %q", "no", got)
+	}
+
+	if err := sse.Send("update", `{"id":1}`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := sse.Send("", "line 1\nline 2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "event: update\ndata: {\"id\":1}\n\n" +
+		"data: line 1\ndata: line 2\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("expected events %q, got %q", want, got)
+	}
+
+	if _, err := SSEWriter(struct{ http.ResponseWriter }{w}); err != ErrStreamingUnsupported {
+		t.Errorf("expected ErrStreamingUnsupported, got %v", err)
+	}
+}
+
+func TestSSEWriterMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		givenTimeout  time.Duration
+		givenAutoHEAD bool
+		givenMethod   string
+
+		wantErr error
+	}{
+		{name: "wrapped writer", givenMethod: http.MethodGet},
+		{name: "timeout", givenTimeout: time.Second, givenMethod: http.MethodGet, wantErr: ErrStreamingUnsupported},
+		{name: "auto HEAD", givenAutoHEAD: true, givenMethod: http.MethodHead, wantErr: ErrStreamingUnsupported},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := NewRouter(&Config{HandlerTimeout: test.givenTimeout, AutoHEAD: test.givenAutoHEAD})
+			var gotErr error
+			rt.HandleWithCache("GET", "/events", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var sse *SSE
+				if sse, gotErr = SSEWriter(w); gotErr == nil {
+					gotErr = sse.Send("update", "1")
+				}
+			}), CachePolicy{NoStore: true})
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(test.givenMethod, "/events", nil))
+
+			if gotErr != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, gotErr)
+			}
+			if test.wantErr == nil && !w.Flushed {
+				t.Error("expected the events to be flushed")
+			}
+		})
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sse, err := SSEWriter(w)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			return
+		}
+		stop := sse.Heartbeat(10 * time.Millisecond)
+		defer stop()
+		time.Sleep(35 * time.Millisecond)
+		sse.Send("done", "bye")
+	}))
+	defer srvr.Close()
+
+	resp, err := http.Get(srvr.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var events []string
+	var event strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			event.WriteString(line + "\n")
+			continue
+		}
+		events = append(events, event.String())
+		event.Reset()
+	}
+	if len(events) < 2 {
+		t.Fatalf("expected heartbeats and an event, got %q", events)
+	}
+	if events[0] != ": heartbeat\n" {
+		t.Errorf("expected a heartbeat comment, got %q", events[0])
+	}
+	if got := events[len(events)-1]; got != "event: done\ndata: bye\n" {
+		t.Errorf("expected the done event last, got %q", got)
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStaticMaxAge is the max-age used in the Cache-Control header of files
+// served by ServeStatic if no other max age is given.
+const DefaultStaticMaxAge = time.Hour
+
+// StaticOption will alter how ServeStatic serves files.
+type StaticOption func(*staticHandler)
+
+// StaticNoListing will make ServeStatic respond with a 404 to requests for
+// directories without an index.html instead of listing their contents.
+func StaticNoListing() StaticOption {
+	return func(s *staticHandler) {
+		s.listing = false
+	}
+}
+
+// StaticIndexFallback will make ServeStatic respond with the root index.html
+// to requests for missing files so client side routing of single page apps
+// can take over.
+func StaticIndexFallback() StaticOption {
+	return func(s *staticHandler) {
+		s.indexFallback = true
+	}
+}
+
+// StaticMaxAge will set the max-age of the Cache-Control header of the served
+// files. index.html files are always served with `Cache-Control: no-cache` so
+// new deployments are picked up.
+func StaticMaxAge(maxAge time.Duration) StaticOption {
+	return func(s *staticHandler) {
+		s.maxAge = maxAge
+	}
+}
+
+// ServeStatic will register a catch-all GET route on the router that serves
+// the files in dir under urlPrefix. Content types are set based on the file
+// extension or contents and conditional requests are handled with the files'
+// modification times.
+func ServeStatic(router Router, urlPrefix, dir string, opts ...StaticOption) {
+	s := &staticHandler{
+		root:    http.Dir(dir),
+		listing: true,
+		maxAge:  DefaultStaticMaxAge,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	router.HandleCatchAll(http.MethodGet, urlPrefix, s)
+}
+
+type staticHandler struct {
+	root          http.FileSystem
+	listing       bool
+	indexFallback bool
+	maxAge        time.Duration
+}
+
+func (s *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + Vars(r)["filepath"])
+
+	f, err := s.root.Open(name)
+	if os.IsNotExist(err) && s.indexFallback {
+		name = "/index.html"
+		f, err = s.root.Open(name)
+	}
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// redirect so relative links in the index or listing resolve.
+			http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+			return
+		}
+		index, err := s.root.Open(path.Join(name, "index.html"))
+		if err != nil {
+			if !s.listing || !os.IsNotExist(err) {
+				s.error(w, os.ErrNotExist)
+				return
+			}
+			// let the FileServer list the directory
+			w.Header().Set("Cache-Control", "no-cache")
+			r.URL.Path = strings.TrimSuffix(name, "/") + "/"
+			http.FileServer(s.root).ServeHTTP(w, r)
+			return
+		}
+		defer index.Close()
+		if info, err = index.Stat(); err != nil {
+			s.error(w, err)
+			return
+		}
+		f = index
+	}
+
+	if info.Name() == "index.html" || s.maxAge <= 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.maxAge/time.Second)))
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (s *staticHandler) error(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case os.IsPermission(err):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// RegisterStaticDefaults will register GET routes for `/favicon.ico` and
+// `/robots.txt`, which browsers and crawlers request from every site, so
+// they do not end up as 404s in the logs. Both are cached for
+// DefaultStaticMaxAge and an empty favicon is served as a 204.
+func RegisterStaticDefaults(router Router, favicon []byte, robots string) {
+	cache := CachePolicy{Public: true, MaxAge: DefaultStaticMaxAge}.String()
+	router.HandleFunc(http.MethodGet, "/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cache)
+		if len(favicon) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "image/x-icon")
+		w.Write(favicon)
+	})
+	router.HandleFunc(http.MethodGet, "/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cache)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(robots))
+	})
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeStatic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizmo-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"index.html":      "<html>index</html>",
+		"css/app.css":     "body {}",
+		"docs/readme.txt": "readme",
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []StaticOption
+		path string
+
+		wantCode         int
+		wantBody         string
+		wantContentType  string
+		wantCacheControl string
+	}{
+		{
+			name:             "existing file",
+			path:             "/static/css/app.css",
+			wantCode:         http.StatusOK,
+			wantBody:         "body {}",
+			wantContentType:  "text/css; charset=utf-8",
+			wantCacheControl: "public, max-age=3600",
+		},
+		{
+			name:             "index",
+			path:             "/static/",
+			wantCode:         http.StatusOK,
+			wantBody:         "<html>index</html>",
+			wantContentType:  "text/html; charset=utf-8",
+			wantCacheControl: "no-cache",
+		},
+		{
+			name:     "missing file",
+			path:     "/static/missing.js",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "directory redirect",
+			path:     "/static/docs",
+			wantCode: http.StatusMovedPermanently,
+		},
+		{
+			name:     "directory listing",
+			path:     "/static/docs/",
+			wantCode: http.StatusOK,
+			wantBody: `<a href="readme.txt">readme.txt</a>`,
+		},
+		{
+			name:     "directory listing disabled",
+			opts:     []StaticOption{StaticNoListing()},
+			path:     "/static/docs/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:             "index fallback",
+			opts:             []StaticOption{StaticIndexFallback()},
+			path:             "/static/app/users/1",
+			wantCode:         http.StatusOK,
+			wantBody:         "<html>index</html>",
+			wantContentType:  "text/html; charset=utf-8",
+			wantCacheControl: "no-cache",
+		},
+	}
+
+	for _, routerType := range routerTypes {
+		for _, test := range tests {
+			t.Run(routerType+" "+test.name, func(t *testing.T) {
+				router := NewRouter(&Config{RouterType: routerType})
+				ServeStatic(router, "/static", dir, test.opts...)
+
+				r, _ := http.NewRequest("GET", test.path, nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+
+				if w.Code != test.wantCode {
+					t.Fatalf("expected status %d, got %d", test.wantCode, w.Code)
+				}
+				if !strings.Contains(w.Body.String(), test.wantBody) {
+					t.Errorf("expected body to contain %q, got %q", test.wantBody, w.Body.String())
+				}
+				if got := w.Header().Get("Content-Type"); test.wantContentType != "" && got != test.wantContentType {
+					t.Errorf("expected Content-Type %q, got %q", test.wantContentType, got)
+				}
+				if got := w.Header().Get("Cache-Control"); test.wantCacheControl != "" && got != test.wantCacheControl {
+					t.Errorf("expected Cache-Control %q, got %q", test.wantCacheControl, got)
+				}
+			})
+		}
+	}
+}
+
+func TestRegisterStaticDefaults(t *testing.T) {
+	tests := []struct {
+		name         string
+		givenFavicon []byte
+		givenPath    string
+
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "favicon",
+			givenFavicon:    []byte("icon"),
+			givenPath:       "/favicon.ico",
+			wantCode:        http.StatusOK,
+			wantContentType: "image/x-icon",
+			wantBody:        "icon",
+		},
+		{
+			name:      "empty favicon",
+			givenPath: "/favicon.ico",
+			wantCode:  http.StatusNoContent,
+		},
+		{
+			name:            "robots",
+			givenPath:       "/robots.txt",
+			wantCode:        http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "User-agent: *\nDisallow: /admin\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := NewRouter(&Config{})
+			RegisterStaticDefaults(router, test.givenFavicon, "User-agent: *\nDisallow: /admin\n")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", test.givenPath, nil))
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("expected Content-Type %q, got %q", test.wantContentType, got)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+				t.Errorf("expected Cache-Control %q, got %q", "public, max-age=3600", got)
+			}
+			if got := w.Body.String(); got != test.wantBody {
+				t.Errorf("expected body %q, got %q", test.wantBody, got)
+			}
+		})
+	}
+}
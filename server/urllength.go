@@ -0,0 +1,26 @@
+package server
+
+import "net/http"
+
+// MaxURLLengthMiddleware returns a middleware func that responds with a 414
+// to requests whose escaped URL path is longer than maxPath bytes or whose
+// raw query string is longer than maxQuery bytes, before they reach the
+// Router. A limit of 0 or less disables it.
+//
+// SimpleServer applies it to every request if Config.MaxURLPathLength or
+// Config.MaxURLQueryLength is set.
+func MaxURLLengthMiddleware(maxPath, maxQuery int) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if maxPath <= 0 && maxQuery <= 0 {
+			return h
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (maxPath > 0 && len(r.URL.EscapedPath()) > maxPath) ||
+				(maxQuery > 0 && len(r.URL.RawQuery) > maxQuery) {
+				http.Error(w, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
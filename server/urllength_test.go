@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxURLLengthMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		givenPath  string
+		givenQuery string
+
+		wantCode int
+	}{
+		{
+			name:       "normal request",
+			givenPath:  "/users/42",
+			givenQuery: "fields=name",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "limits are inclusive",
+			givenPath:  "/" + strings.Repeat("a", 31),
+			givenQuery: strings.Repeat("q", 16),
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:      "long path",
+			givenPath: "/" + strings.Repeat("a", 32),
+			wantCode:  http.StatusRequestURITooLong,
+		},
+		{
+			name:      "long escaped path",
+			givenPath: "/" + strings.Repeat("%20", 11),
+			wantCode:  http.StatusRequestURITooLong,
+		},
+		{
+			name:       "long query",
+			givenPath:  "/users",
+			givenQuery: "q=" + strings.Repeat("a", 15),
+			wantCode:   http.StatusRequestURITooLong,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := MaxURLLengthMiddleware(32, 16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			target := test.givenPath
+			if test.givenQuery != "" {
+				target += "?" + test.givenQuery
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+		})
+	}
+}
@@ -6,17 +6,19 @@ import (
 	"net/http"
 
 	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
 )
 
 // Vars is a helper function for accessing route
 // parameters from any server.Router implementation. This is the equivalent
-// of using `mux.Vars(r)` with the Gorilla mux.Router.
+// of using `mux.Vars(r)` with the Gorilla mux.Router. If the shared storage
+// has not been populated, this will fall back to the Gorilla route params.
 func Vars(r *http.Request) map[string]string {
 	if rv := context.Get(r, varsKey); rv != nil {
 		vars, _ := rv.(map[string]string)
 		return vars
 	}
-	return nil
+	return mux.Vars(r)
 }
 
 // SetRouteVars will set the given value into into the request context
@@ -5,15 +5,22 @@ package server
 import (
 	"context"
 	"net/http"
+
+	"github.com/gorilla/mux"
 )
 
 // Vars is a helper function for accessing route
 // parameters from any server.Router implementation. This is the equivalent
-// of using `mux.Vars(r)` with the Gorilla mux.Router.
+// of using `mux.Vars(r)` with the Gorilla mux.Router. If the shared storage
+// has not been populated, this will fall back to the Gorilla route params.
 func Vars(r *http.Request) map[string]string {
-	// vars doesnt exist yet, return empty map
 	rawVars := r.Context().Value(varsKey)
 	if rawVars == nil {
+		// vars may have been set by a plain mux.Router
+		if vars := mux.Vars(r); vars != nil {
+			return vars
+		}
+		// vars doesnt exist yet, return empty map
 		return map[string]string{}
 	}
 